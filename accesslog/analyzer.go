@@ -0,0 +1,140 @@
+package accesslog
+
+import (
+	"errors"
+	"fmt"
+	hh "heavy-hitters"
+	"heavy-hitters/ingest"
+	"io"
+	"slices"
+	"strconv"
+)
+
+// Stats reports the number of lines processed from an access log.
+type Stats struct {
+	// Lines is the number of lines counted by the summaries.
+	Lines int64
+	// Skipped is the number of lines that did not match the format, or had an invalid weight.
+	Skipped int64
+}
+
+// Add accumulates the stats of another access log.
+func (s *Stats) Add(other Stats) {
+	s.Lines += other.Lines
+	s.Skipped += other.Skipped
+}
+
+// Analyzer summarizes the entries of an access log along several dimensions, each of which is a field of the entries.
+// Every dimension is summarized by its own StreamSummary.
+type Analyzer struct {
+	format     *Format
+	dimensions []string
+	weight     string
+	summaries  []*hh.StreamSummary[string]
+}
+
+// NewAnalyzer creates an analyzer of the given fields of an access log, each summarized by a StreamSummary created with the options.
+// Each line is counted once, unless weight names a numeric field such as body_bytes_sent to weight each line by its value.
+// A weight of "-", as written by Apache for an empty response, counts as zero.
+func NewAnalyzer(format *Format, dimensions []string, weight string, opts ...hh.Option[string]) (*Analyzer, error) {
+	if len(dimensions) == 0 {
+		return nil, errors.New("at least one dimension is required")
+	}
+
+	fields := format.Fields()
+
+	for _, field := range append(slices.Clone(dimensions), weight) {
+		if field != "" && !slices.Contains(fields, field) {
+			return nil, fmt.Errorf("the log format has no field %q", field)
+		}
+	}
+
+	a := &Analyzer{
+		format:     format,
+		dimensions: dimensions,
+		weight:     weight,
+		summaries:  make([]*hh.StreamSummary[string], len(dimensions)),
+	}
+
+	for i := range a.summaries {
+		summary, err := hh.New(opts...)
+		if err != nil {
+			return nil, err
+		}
+
+		a.summaries[i] = summary
+	}
+
+	return a, nil
+}
+
+// Dimensions lists the fields summarized by the analyzer.
+func (a *Analyzer) Dimensions() []string {
+	return a.dimensions
+}
+
+// Summary returns the summary of a dimension, or nil if the analyzer does not summarize the field.
+func (a *Analyzer) Summary(dimension string) *hh.StreamSummary[string] {
+	if i := slices.Index(a.dimensions, dimension); i >= 0 {
+		return a.summaries[i]
+	}
+
+	return nil
+}
+
+// Analyze reads the access log line by line and adds every line to the summaries.
+// The stats cover everything processed up to the point an error occurred, if any.
+func (a *Analyzer) Analyze(r io.Reader) (Stats, error) {
+	var stats Stats
+
+	err := ingest.Lines().Tokenize(r, func(line string) {
+		if a.Add(line) {
+			stats.Lines++
+		} else {
+			stats.Skipped++
+		}
+	})
+
+	return stats, err
+}
+
+// Add parses a line of the access log and hits the summary of every dimension with the value of its field.
+// Dimensions derived from a malformed request line are not counted.
+// The boolean is false iff the line does not match the format or has an invalid weight, in which case nothing is counted.
+func (a *Analyzer) Add(line string) bool {
+	entry, ok := a.format.Parse(line)
+	if !ok {
+		return false
+	}
+
+	weight := 1
+
+	if a.weight != "" {
+		if weight, ok = a.parseWeight(entry); !ok {
+			return false
+		}
+	}
+
+	for i, dimension := range a.dimensions {
+		if value, ok := entry.Field(dimension); ok {
+			a.summaries[i].HitN(value, weight)
+		}
+	}
+
+	return true
+}
+
+// parseWeight parses the weight field of the entry.
+func (a *Analyzer) parseWeight(entry Entry) (int, bool) {
+	value, _ := entry.Field(a.weight)
+	if value == "-" {
+		return 0, true
+	}
+
+	weight, err := strconv.Atoi(value)
+	if err != nil || weight < 0 {
+		return 0, false
+	}
+
+	return weight, true
+}
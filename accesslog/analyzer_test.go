@@ -0,0 +1,82 @@
+package accesslog
+
+import (
+	"github.com/stretchr/testify/require"
+	hh "heavy-hitters"
+	"os"
+	"testing"
+)
+
+func analyze(t *testing.T, dimensions []string, weight string) (*Analyzer, Stats) {
+	t.Helper()
+
+	format, err := ParseFormat(Combined)
+	require.NoError(t, err)
+
+	analyzer, err := NewAnalyzer(format, dimensions, weight, hh.WithCapacity[string](10), hh.WithTieBreak[string](hh.TieBreakKey))
+	require.NoError(t, err)
+
+	f, err := os.Open("testdata/access.log")
+	require.NoError(t, err)
+
+	defer f.Close()
+
+	stats, err := analyzer.Analyze(f)
+	require.NoError(t, err)
+
+	return analyzer, stats
+}
+
+func TestAnalyzer(t *testing.T) {
+	analyzer, stats := analyze(t, []string{"remote_addr", RequestURI, "status", "http_user_agent"}, "")
+	require.Equal(t, Stats{Lines: 7, Skipped: 1}, stats)
+
+	expected := map[string][]string{
+		"remote_addr":     {"10.0.0.1", "10.0.0.2"},
+		RequestURI:        {"/index.html", "/about"},
+		"status":          {"200", "302"},
+		"http_user_agent": {"Mozilla/5.0 (X11; Linux x86_64)", "curl/8.5.0"},
+	}
+
+	for dimension, top := range expected {
+		actual, _, _ := analyzer.Summary(dimension).Top(2)
+		require.Equal(t, top, actual, dimension)
+	}
+
+	count, _ := analyzer.Summary("status").Get("200")
+	require.Equal(t, hh.Count{Count: 4}, count)
+
+	// the request line of the 408 response is malformed, so it has no URI.
+	require.Equal(t, 6, analyzer.Summary(RequestURI).Hits())
+	require.Nil(t, analyzer.Summary("time_local"))
+}
+
+func TestAnalyzer_Weight(t *testing.T) {
+	analyzer, stats := analyze(t, []string{"remote_addr", RequestURI}, "body_bytes_sent")
+	require.Equal(t, Stats{Lines: 7, Skipped: 1}, stats)
+
+	top, _, _ := analyzer.Summary(RequestURI).Top(3)
+	require.Equal(t, []string{"/about", "/index.html", `/search?q=\"quoted\"`}, top)
+
+	count, _ := analyzer.Summary("remote_addr").Get("10.0.0.1")
+	require.Equal(t, hh.Count{Count: 1152}, count)
+
+	require.False(t, analyzer.Add(`10.0.0.1 - - [18/Oct/2026:10:00:00 +0000] "GET / HTTP/1.1" 200 -1 "-" "-"`))
+}
+
+func TestNewAnalyzer_Invalid(t *testing.T) {
+	format, err := ParseFormat(Common)
+	require.NoError(t, err)
+
+	_, err = NewAnalyzer(format, nil, "", hh.WithCapacity[string](10))
+	require.Error(t, err)
+
+	_, err = NewAnalyzer(format, []string{"http_user_agent"}, "", hh.WithCapacity[string](10))
+	require.Error(t, err)
+
+	_, err = NewAnalyzer(format, []string{"status"}, "bytes_sent", hh.WithCapacity[string](10))
+	require.Error(t, err)
+
+	_, err = NewAnalyzer(format, []string{"status"}, "")
+	require.ErrorIs(t, err, hh.ErrInvalidOption)
+}
@@ -0,0 +1,165 @@
+// Package accesslog summarizes web server access logs, such as the top client addresses, paths, user agents and status codes.
+//
+// Log lines are parsed with the nginx log_format syntax, where each $variable captures a field of the line.
+// The Common and Combined Log Formats of both nginx and Apache are predefined.
+package accesslog
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+const (
+	// Common is the Common Log Format.
+	Common = `$remote_addr - $remote_user [$time_local] "$request" $status $body_bytes_sent`
+	// Combined is the Combined Log Format, the default format of nginx.
+	Combined = Common + ` "$http_referer" "$http_user_agent"`
+)
+
+// The fields derived from the request line of a $request field, such as "GET /index.html HTTP/1.1".
+const (
+	RequestMethod  = "request_method"
+	RequestURI     = "request_uri"
+	ServerProtocol = "server_protocol"
+)
+
+// Format parses the lines of an access log written with an nginx log_format.
+type Format struct {
+	re *regexp.Regexp
+	// The names of the variables, in the order of their capture groups.
+	names []string
+}
+
+var variable = regexp.MustCompile(`\$(?:\{(\w+)\}|(\w+))`)
+
+// ParseFormat compiles an nginx log_format string, where variables are written as $name or ${name}.
+// The names "common" and "combined" select the predefined formats.
+//
+// A variable surrounded by double quotes matches up to the closing quote, skipping quotes escaped with a backslash.
+// A variable at the end of the format matches up to the next space, and any text after it is ignored.
+// This way, the Common format also parses lines of the Combined format.
+func ParseFormat(format string) (*Format, error) {
+	switch format {
+	case "common":
+		format = Common
+	case "combined":
+		format = Combined
+	}
+
+	var pattern strings.Builder
+	var names []string
+
+	pattern.WriteString("^")
+
+	matches := variable.FindAllStringSubmatchIndex(format, -1)
+	literal := 0
+
+	for _, m := range matches {
+		var name string
+
+		if m[2] >= 0 {
+			name = format[m[2]:m[3]]
+		} else {
+			name = format[m[4]:m[5]]
+		}
+
+		if slices.Contains(names, name) {
+			return nil, fmt.Errorf("variable $%s appears more than once in the log format %q", name, format)
+		}
+
+		names = append(names, name)
+
+		before := format[literal:m[0]]
+		pattern.WriteString(regexp.QuoteMeta(before))
+		literal = m[1]
+
+		switch {
+		case strings.HasSuffix(before, `"`) && strings.HasPrefix(format[literal:], `"`):
+			pattern.WriteString(`((?:[^"\\]|\\.)*)`)
+		case literal == len(format):
+			pattern.WriteString(`(\S*)`)
+		default:
+			pattern.WriteString(`(.*?)`)
+		}
+	}
+
+	if len(names) == 0 {
+		return nil, fmt.Errorf("the log format %q has no variables", format)
+	}
+
+	pattern.WriteString(regexp.QuoteMeta(format[literal:]))
+
+	re, err := regexp.Compile(pattern.String())
+	if err != nil {
+		return nil, err
+	}
+
+	return &Format{re: re, names: names}, nil
+}
+
+// Fields lists the names of the fields of an entry, including the fields derived from $request.
+func (f *Format) Fields() []string {
+	fields := slices.Clone(f.names)
+
+	if slices.Contains(f.names, "request") {
+		for _, name := range []string{RequestMethod, RequestURI, ServerProtocol} {
+			if !slices.Contains(fields, name) {
+				fields = append(fields, name)
+			}
+		}
+	}
+
+	return fields
+}
+
+// Entry is a parsed line of an access log.
+type Entry struct {
+	format *Format
+	values []string
+}
+
+// Parse parses a line of the access log.
+// The boolean is false iff the line does not match the format.
+func (f *Format) Parse(line string) (Entry, bool) {
+	m := f.re.FindStringSubmatch(line)
+	if m == nil {
+		return Entry{}, false
+	}
+
+	return Entry{format: f, values: m[1:]}, true
+}
+
+// Field returns the value of the named field of the entry.
+// The boolean is false iff the format has no such field, or a field derived from $request is missing from a malformed request line.
+func (e Entry) Field(name string) (string, bool) {
+	if i := slices.Index(e.format.names, name); i >= 0 {
+		return e.values[i], true
+	}
+
+	var part int
+
+	switch name {
+	case RequestMethod:
+		part = 0
+	case RequestURI:
+		part = 1
+	case ServerProtocol:
+		part = 2
+	default:
+		return "", false
+	}
+
+	request, ok := e.Field("request")
+	if !ok {
+		return "", false
+	}
+
+	parts := strings.Fields(request)
+	if len(parts) != 3 {
+		return "", false
+	}
+
+	return parts[part], true
+}
@@ -0,0 +1,80 @@
+package accesslog
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestParseFormat_Combined(t *testing.T) {
+	format, err := ParseFormat("combined")
+	require.NoError(t, err)
+
+	entry, ok := format.Parse(`10.0.0.1 - alice [18/Oct/2026:10:00:00 +0000] "GET /a?b=\"c\" HTTP/1.1" 200 512 "-" "Mozilla/5.0 (X11; Linux x86_64)" "extra"`)
+	require.True(t, ok)
+
+	expected := map[string]string{
+		"remote_addr":     "10.0.0.1",
+		"remote_user":     "alice",
+		"time_local":      "18/Oct/2026:10:00:00 +0000",
+		"request":         `GET /a?b=\"c\" HTTP/1.1`,
+		"status":          "200",
+		"body_bytes_sent": "512",
+		"http_referer":    "-",
+		"http_user_agent": "Mozilla/5.0 (X11; Linux x86_64)",
+		RequestMethod:     "GET",
+		RequestURI:        `/a?b=\"c\"`,
+		ServerProtocol:    "HTTP/1.1",
+	}
+
+	require.ElementsMatch(t, format.Fields(), []string{
+		"remote_addr", "remote_user", "time_local", "request", "status", "body_bytes_sent", "http_referer", "http_user_agent",
+		RequestMethod, RequestURI, ServerProtocol,
+	})
+
+	for name, value := range expected {
+		actual, ok := entry.Field(name)
+		require.True(t, ok, name)
+		require.Equal(t, value, actual, name)
+	}
+
+	_, ok = entry.Field("upstream_addr")
+	require.False(t, ok)
+
+	_, ok = format.Parse(`10.0.0.1 - - [18/Oct/2026:10:00:00 +0000] "GET / HTTP/1.1"`)
+	require.False(t, ok)
+}
+
+func TestParseFormat_Common(t *testing.T) {
+	format, err := ParseFormat("common")
+	require.NoError(t, err)
+
+	// lines of the combined format are also lines of the common format.
+	entry, ok := format.Parse(`10.0.0.1 - - [18/Oct/2026:10:00:00 +0000] "-" 408 0 "-" "-"`)
+	require.True(t, ok)
+
+	bytes, _ := entry.Field("body_bytes_sent")
+	require.Equal(t, "0", bytes)
+
+	_, ok = entry.Field(RequestURI)
+	require.False(t, ok)
+}
+
+func TestParseFormat_Custom(t *testing.T) {
+	format, err := ParseFormat(`$remote_addr [${time_local}] $host:$server_port "$request" $status $request_time`)
+	require.NoError(t, err)
+
+	entry, ok := format.Parse(`::1 [18/Oct/2026:10:00:00 +0000] example.com:443 "GET / HTTP/2.0" 200 0.005`)
+	require.True(t, ok)
+
+	for name, value := range map[string]string{"remote_addr": "::1", "host": "example.com", "server_port": "443", "request_time": "0.005"} {
+		actual, _ := entry.Field(name)
+		require.Equal(t, value, actual)
+	}
+}
+
+func TestParseFormat_Invalid(t *testing.T) {
+	for _, format := range []string{"", "no variables", "$status $status"} {
+		_, err := ParseFormat(format)
+		require.Error(t, err, format)
+	}
+}
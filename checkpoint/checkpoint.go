@@ -0,0 +1,562 @@
+// Package checkpoint periodically persists a summary to disk and restores it after a restart.
+//
+// A checkpoint directory holds a single snapshot file along with an optional append-only log of the hits since that snapshot.
+// Snapshots are written atomically by writing to a temporary file, syncing it to disk and renaming it over the previous snapshot.
+// Every snapshot has a generation, and the log of hits is named after the generation of the snapshot it follows.
+// This way, a crash between writing a snapshot and starting a new log never replays hits that the snapshot already contains.
+package checkpoint
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"encoding"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	hh "heavy-hitters"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SnapshotFile is the name of the snapshot file in a checkpoint directory.
+const SnapshotFile = "snapshot"
+
+var magic = [4]byte{'H', 'H', 'C', 'K'}
+
+// ErrCorrupt is returned when the snapshot in a checkpoint directory fails its checksum or cannot be decoded.
+var ErrCorrupt = errors.New("corrupt checkpoint")
+
+// Summary is a heavy hitters implementation that can be encoded to and decoded from a binary format.
+type Summary[T cmp.Ordered] interface {
+	hh.HeavyHitters[T]
+	encoding.BinaryMarshaler
+	encoding.BinaryUnmarshaler
+}
+
+// Option configures when a Manager writes snapshots and whether it logs hits between snapshots.
+type Option func(*options)
+
+type options struct {
+	interval time.Duration
+	hits     int
+	log      bool
+	onError  func(error)
+}
+
+// WithInterval writes a snapshot every interval, in addition to any other trigger.
+func WithInterval(interval time.Duration) Option {
+	return func(o *options) {
+		o.interval = interval
+	}
+}
+
+// WithHitCount writes a snapshot once the given number of hits have been counted since the previous snapshot.
+func WithHitCount(hits int) Option {
+	return func(o *options) {
+		o.hits = hits
+	}
+}
+
+// WithLog appends every hit to a log that is replayed on top of the latest snapshot during recovery.
+// Hits are buffered in memory and written to the log by every snapshot attempt and by Sync,
+// so a crash of the process loses the hits counted since then.
+// The log is only synced to disk by Sync, so a crash of the operating system may lose the most recent hits.
+func WithLog() Option {
+	return func(o *options) {
+		o.log = true
+	}
+}
+
+// WithOnError registers a callback invoked with every error encountered while logging hits or writing snapshots,
+// including those of snapshots written in the background. A failed snapshot is retried by the next trigger.
+// The callback is invoked while the manager is locked, so it must not call the manager.
+func WithOnError(fn func(error)) Option {
+	return func(o *options) {
+		o.onError = fn
+	}
+}
+
+// Recovery describes the state restored when opening a checkpoint directory.
+type Recovery struct {
+	// Restored is true iff a snapshot was found and decoded into the summary.
+	Restored bool
+	// Generation is the generation of the restored snapshot, or zero if none was found.
+	Generation uint64
+	// Replayed is the number of hits replayed from the log.
+	Replayed int
+	// Discarded is the number of bytes at the end of the log that were torn or corrupt and have been truncated.
+	Discarded int64
+}
+
+// Manager wraps a summary to write snapshots of it to a checkpoint directory.
+// A Manager is itself a heavy hitters implementation, which is safe for concurrent use.
+type Manager[T cmp.Ordered] struct {
+	mutex      sync.Mutex
+	dir        string
+	summary    Summary[T]
+	options    options
+	generation uint64
+	// The number of hits since the latest snapshot, or since the latest failed attempt so it is retried after as many hits.
+	pending int
+	// The log of the current generation and its buffer, or nil when not logging.
+	log    *os.File
+	writer *bufio.Writer
+	// Set once writing to the log fails, after which hits are only logged again once a snapshot starts a new log.
+	logFailed bool
+	buffer    []byte
+	// The latest error encountered while logging hits or writing snapshots, until a snapshot succeeds.
+	err       error
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+	closeErr  error
+}
+
+// Open restores the summary from the latest snapshot in the directory, replays the log of hits on top of it,
+// and returns a Manager that keeps writing snapshots of the summary to the directory.
+// The directory is created if it does not exist; an empty directory leaves the summary untouched.
+func Open[T cmp.Ordered](dir string, summary Summary[T], opts ...Option) (*Manager[T], Recovery, error) {
+	var o options
+	var recovery Recovery
+
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	if o.interval < 0 || o.hits < 0 {
+		return nil, recovery, fmt.Errorf("checkpoint interval and hit count must not be negative")
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, recovery, err
+	}
+
+	m := &Manager[T]{
+		dir:     dir,
+		summary: summary,
+		options: o,
+	}
+
+	generation, found, err := m.restore()
+	if err != nil {
+		return nil, recovery, err
+	}
+
+	recovery.Restored = found
+	recovery.Generation = generation
+	m.generation = generation
+
+	recovery.Replayed, recovery.Discarded, err = m.replay()
+	if err != nil {
+		return nil, recovery, err
+	}
+
+	m.pending = recovery.Replayed
+
+	if o.log {
+		if m.log, err = os.OpenFile(m.logPath(m.generation), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644); err != nil {
+			return nil, recovery, err
+		}
+
+		m.writer = bufio.NewWriter(m.log)
+	}
+
+	if o.interval > 0 {
+		m.stop = make(chan struct{})
+		m.done = make(chan struct{})
+
+		go m.tick()
+	}
+
+	return m, recovery, nil
+}
+
+// Hit increments the frequency for the given element, logging the hit and writing a snapshot if one is due.
+// Errors from logging or writing snapshots are reported by Err and by the callback of WithOnError.
+func (m *Manager[T]) Hit(e T) hh.Count {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	count := m.summary.Hit(e)
+	m.pending++
+
+	if m.writer != nil && !m.logFailed {
+		m.buffer = appendRecord(m.buffer[:0], hh.AppendKey(nil, e))
+
+		if _, err := m.writer.Write(m.buffer); err != nil {
+			m.logFailed = true
+			m.fail(err)
+		}
+	}
+
+	if m.options.hits > 0 && m.pending >= m.options.hits {
+		_ = m.checkpoint()
+	}
+
+	return count
+}
+
+// Hits counts the total number of hits for all elements.
+func (m *Manager[T]) Hits() int {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return m.summary.Hits()
+}
+
+// Get retrieves the approximated frequency for the given element, with a bounds on the error.
+func (m *Manager[T]) Get(e T) (hh.Count, bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return m.summary.Get(e)
+}
+
+// Frequent finds the set of elements that contribute more than phi * Hits of the total frequency.
+func (m *Manager[T]) Frequent(phi float64) ([]T, bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return m.summary.Frequent(phi)
+}
+
+// Top finds the top-k elements seen in the stream.
+func (m *Manager[T]) Top(k int) ([]T, bool, bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return m.summary.Top(k)
+}
+
+// Checkpoint writes a snapshot of the summary immediately, returning the error of the snapshot.
+func (m *Manager[T]) Checkpoint() error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return m.checkpoint()
+}
+
+// Sync writes the buffered hits to the log and syncs the log to disk.
+func (m *Manager[T]) Sync() error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if err := m.flush(); err != nil {
+		return err
+	}
+
+	if m.log == nil || m.logFailed {
+		return nil
+	}
+
+	if err := m.log.Sync(); err != nil {
+		m.logFailed = true
+		m.fail(err)
+
+		return err
+	}
+
+	return nil
+}
+
+// Err returns the latest error encountered while logging hits or writing snapshots, or nil once a snapshot succeeds after it.
+func (m *Manager[T]) Err() error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return m.err
+}
+
+// Close stops writing snapshots in the background, writes a final snapshot and closes the log.
+// Subsequent calls return the error of the first one.
+func (m *Manager[T]) Close() error {
+	m.closeOnce.Do(func() {
+		if m.stop != nil {
+			close(m.stop)
+			<-m.done
+		}
+
+		m.mutex.Lock()
+		defer m.mutex.Unlock()
+
+		err := m.checkpoint()
+
+		if m.log != nil {
+			err = errors.Join(err, m.log.Close())
+			m.log = nil
+			m.writer = nil
+		}
+
+		m.closeErr = err
+	})
+
+	return m.closeErr
+}
+
+// tick writes snapshots on the configured interval until the manager is closed.
+func (m *Manager[T]) tick() {
+	defer close(m.done)
+
+	ticker := time.NewTicker(m.options.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.stop:
+			return
+		case <-ticker.C:
+			m.mutex.Lock()
+			_ = m.checkpoint()
+			m.mutex.Unlock()
+		}
+	}
+}
+
+// checkpoint writes a snapshot of the next generation, starts a new log and removes the logs of previous generations;
+// the caller must hold the mutex. The buffered hits are written to the log first, so they are replayed if the snapshot fails.
+func (m *Manager[T]) checkpoint() error {
+	m.pending = 0
+	_ = m.flush()
+
+	if err := m.writeSnapshot(m.generation + 1); err != nil {
+		m.fail(err)
+		return err
+	}
+
+	m.generation++
+	m.err = nil
+
+	if m.options.log {
+		// hits are only logged again once the log of the new generation is open, since older logs are not replayed.
+		m.logFailed = true
+
+		if m.log != nil {
+			if err := m.log.Close(); err != nil {
+				m.fail(err)
+			}
+
+			m.log, m.writer = nil, nil
+		}
+
+		log, err := os.OpenFile(m.logPath(m.generation), os.O_CREATE|os.O_WRONLY|os.O_TRUNC|os.O_APPEND, 0o644)
+		if err != nil {
+			m.fail(err)
+			return err
+		}
+
+		m.log, m.writer, m.logFailed = log, bufio.NewWriter(log), false
+	}
+
+	return m.removeLogs()
+}
+
+// flush writes the buffered hits to the log; the caller must hold the mutex.
+func (m *Manager[T]) flush() error {
+	if m.writer == nil || m.logFailed {
+		return nil
+	}
+
+	if err := m.writer.Flush(); err != nil {
+		m.logFailed = true
+		m.fail(err)
+
+		return err
+	}
+
+	return nil
+}
+
+// removeLogs removes the logs of the generations before the current one,
+// including those left behind by earlier snapshots that failed to remove them.
+func (m *Manager[T]) removeLogs() error {
+	paths, err := filepath.Glob(filepath.Join(m.dir, "hits-*.log"))
+	if err != nil {
+		m.fail(err)
+		return err
+	}
+
+	var errs []error
+
+	for _, path := range paths {
+		name := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(path), "hits-"), ".log")
+
+		generation, err := strconv.ParseUint(name, 10, 64)
+		if err != nil || generation >= m.generation {
+			continue
+		}
+
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			m.fail(err)
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// fail records an error and reports it to the callback of WithOnError; the caller must hold the mutex.
+func (m *Manager[T]) fail(err error) {
+	m.err = err
+
+	if m.options.onError != nil {
+		m.options.onError(err)
+	}
+}
+
+// writeSnapshot atomically replaces the snapshot file with a snapshot of the given generation.
+func (m *Manager[T]) writeSnapshot(generation uint64) error {
+	data, err := m.summary.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	b := make([]byte, 0, len(magic)+8+len(data)+4)
+	b = append(b, magic[:]...)
+	b = binary.LittleEndian.AppendUint64(b, generation)
+	b = append(b, data...)
+	b = binary.LittleEndian.AppendUint32(b, crc32.ChecksumIEEE(b))
+
+	tmp, err := os.CreateTemp(m.dir, SnapshotFile+".*.tmp")
+	if err != nil {
+		return err
+	}
+
+	// the temporary file is only left behind if the snapshot could not be written.
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp.Name(), filepath.Join(m.dir, SnapshotFile)); err != nil {
+		return err
+	}
+
+	return syncDir(m.dir)
+}
+
+// restore decodes the snapshot file into the summary, returning its generation and whether it exists.
+func (m *Manager[T]) restore() (uint64, bool, error) {
+	b, err := os.ReadFile(filepath.Join(m.dir, SnapshotFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, false, nil
+	} else if err != nil {
+		return 0, false, err
+	}
+
+	if len(b) < len(magic)+8+4 || !bytes.Equal(b[:len(magic)], magic[:]) {
+		return 0, false, fmt.Errorf("%w: missing header", ErrCorrupt)
+	}
+
+	body, checksum := b[:len(b)-4], binary.LittleEndian.Uint32(b[len(b)-4:])
+	if crc32.ChecksumIEEE(body) != checksum {
+		return 0, false, fmt.Errorf("%w: checksum mismatch", ErrCorrupt)
+	}
+
+	generation := binary.LittleEndian.Uint64(body[len(magic):])
+
+	if err := m.summary.UnmarshalBinary(body[len(magic)+8:]); err != nil {
+		return 0, false, fmt.Errorf("%w: %w", ErrCorrupt, err)
+	}
+
+	return generation, true, nil
+}
+
+// replay counts the hits in the log of the current generation, truncating any torn or corrupt records at its end.
+func (m *Manager[T]) replay() (int, int64, error) {
+	path := m.logPath(m.generation)
+
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, 0, nil
+	} else if err != nil {
+		return 0, 0, err
+	}
+
+	var replayed, offset int
+
+	for offset < len(b) {
+		payload, n, ok := readRecord(b[offset:])
+		if !ok {
+			break
+		}
+
+		key, _, err := hh.DecodeKey[T](payload)
+		if err != nil {
+			break
+		}
+
+		m.summary.Hit(key)
+		replayed++
+		offset += n
+	}
+
+	discarded := int64(len(b) - offset)
+
+	if discarded > 0 {
+		if err := os.Truncate(path, int64(offset)); err != nil {
+			return replayed, discarded, err
+		}
+	}
+
+	return replayed, discarded, nil
+}
+
+// logPath is the path of the log of hits that follow the snapshot of the given generation.
+func (m *Manager[T]) logPath(generation uint64) string {
+	return filepath.Join(m.dir, fmt.Sprintf("hits-%d.log", generation))
+}
+
+// appendRecord frames the payload as a log record: its length as a uvarint, the payload, and its CRC-32 (IEEE).
+func appendRecord(b []byte, payload []byte) []byte {
+	b = binary.AppendUvarint(b, uint64(len(payload)))
+	b = append(b, payload...)
+	return binary.LittleEndian.AppendUint32(b, crc32.ChecksumIEEE(payload))
+}
+
+// readRecord reads a log record from the start of the buffer, returning its payload and size.
+// The boolean is false if the record is torn or fails its checksum.
+func readRecord(b []byte) ([]byte, int, bool) {
+	length, n := binary.Uvarint(b)
+	if n <= 0 || length > uint64(len(b)-n) || len(b)-n-int(length) < 4 {
+		return nil, 0, false
+	}
+
+	payload := b[n : n+int(length)]
+	checksum := binary.LittleEndian.Uint32(b[n+int(length):])
+
+	if crc32.ChecksumIEEE(payload) != checksum {
+		return nil, 0, false
+	}
+
+	return payload, n + int(length) + 4, true
+}
+
+// syncDir flushes the directory entry of a renamed file to disk.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+
+	defer d.Close()
+
+	return d.Sync()
+}
@@ -0,0 +1,231 @@
+package checkpoint
+
+import (
+	"errors"
+	"github.com/stretchr/testify/require"
+	hh "heavy-hitters"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestManager_ReplayLog(t *testing.T) {
+	dir := t.TempDir()
+
+	m, recovery, err := Open[string](dir, hh.NewStreamSummary[string](8), WithLog())
+	require.NoError(t, err)
+	require.Equal(t, Recovery{}, recovery)
+
+	for _, e := range []string{"a", "b", "a", "c", "a"} {
+		m.Hit(e)
+	}
+
+	require.NoError(t, m.Sync())
+
+	// simulate a crash by restoring from the directory without closing the manager.
+	restored := hh.NewStreamSummary[string](8)
+	_, recovery, err = Open[string](dir, restored, WithLog())
+	require.NoError(t, err)
+	require.Equal(t, Recovery{Replayed: 5}, recovery)
+
+	count, found := restored.Get("a")
+	require.True(t, found)
+	require.Equal(t, hh.Count{Count: 3}, count)
+	require.Equal(t, 5, restored.Hits())
+}
+
+func TestManager_HitCount(t *testing.T) {
+	dir := t.TempDir()
+
+	m, _, err := Open[int](dir, hh.NewStreamSummary[int](8), WithLog(), WithHitCount(10))
+	require.NoError(t, err)
+
+	for i := 0; i < 25; i++ {
+		m.Hit(i % 3)
+	}
+
+	require.NoError(t, m.Err())
+	require.NoError(t, m.Sync())
+
+	restored := hh.NewStreamSummary[int](1)
+	_, recovery, err := Open[int](dir, restored, WithLog())
+	require.NoError(t, err)
+	require.Equal(t, Recovery{Restored: true, Generation: 2, Replayed: 5}, recovery)
+	require.Equal(t, 25, restored.Hits())
+
+	for i := 0; i < 3; i++ {
+		expected, _ := m.Get(i)
+		actual, found := restored.Get(i)
+		require.True(t, found)
+		require.Equal(t, expected, actual)
+	}
+
+	// only the log following the latest snapshot is kept.
+	logs, err := filepath.Glob(filepath.Join(dir, "*.log"))
+	require.NoError(t, err)
+	require.Equal(t, []string{filepath.Join(dir, "hits-2.log")}, logs)
+}
+
+func TestManager_Close(t *testing.T) {
+	dir := t.TempDir()
+
+	naive := hh.NewNaive[string]()
+	m, _, err := Open[string](dir, &naive, WithLog())
+	require.NoError(t, err)
+
+	m.Hit("a")
+	m.Hit("b")
+	require.NoError(t, m.Close())
+
+	restored := hh.NewNaive[string]()
+	_, recovery, err := Open[string](dir, &restored)
+	require.NoError(t, err)
+	require.Equal(t, Recovery{Restored: true, Generation: 1}, recovery)
+	require.Equal(t, 2, restored.Hits())
+}
+
+func TestManager_Interval(t *testing.T) {
+	dir := t.TempDir()
+
+	m, _, err := Open[string](dir, hh.NewStreamSummary[string](8), WithInterval(10*time.Millisecond))
+	require.NoError(t, err)
+
+	m.Hit("a")
+
+	require.Eventually(t, func() bool {
+		_, err := os.Stat(filepath.Join(dir, SnapshotFile))
+		return err == nil
+	}, time.Second, 5*time.Millisecond)
+
+	require.NoError(t, m.Close())
+}
+
+func TestManager_TornLog(t *testing.T) {
+	dir := t.TempDir()
+
+	m, _, err := Open[string](dir, hh.NewStreamSummary[string](8), WithLog())
+	require.NoError(t, err)
+
+	m.Hit("a")
+	m.Hit("b")
+	require.NoError(t, m.Sync())
+
+	path := filepath.Join(dir, "hits-0.log")
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+
+	// a partially written record at the end of the log.
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	require.NoError(t, err)
+	_, err = f.Write([]byte{5, 'a', 'b'})
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	restored := hh.NewStreamSummary[string](8)
+	_, recovery, err := Open[string](dir, restored, WithLog())
+	require.NoError(t, err)
+	require.Equal(t, Recovery{Replayed: 2, Discarded: 3}, recovery)
+
+	truncated, err := os.Stat(path)
+	require.NoError(t, err)
+	require.Equal(t, info.Size(), truncated.Size())
+}
+
+func TestManager_CorruptSnapshot(t *testing.T) {
+	dir := t.TempDir()
+
+	m, _, err := Open[string](dir, hh.NewStreamSummary[string](8))
+	require.NoError(t, err)
+
+	m.Hit("a")
+	require.NoError(t, m.Close())
+
+	path := filepath.Join(dir, SnapshotFile)
+	b, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	b[len(b)/2] ^= 0xFF
+	require.NoError(t, os.WriteFile(path, b, 0o644))
+
+	_, _, err = Open[string](dir, hh.NewStreamSummary[string](8))
+	require.ErrorIs(t, err, ErrCorrupt)
+}
+
+// flakySummary fails to encode itself while fail is set.
+type flakySummary struct {
+	*hh.StreamSummary[string]
+	fail bool
+}
+
+func (s *flakySummary) MarshalBinary() ([]byte, error) {
+	if s.fail {
+		return nil, errors.New("flaky")
+	}
+
+	return s.StreamSummary.MarshalBinary()
+}
+
+func TestManager_Retry(t *testing.T) {
+	dir := t.TempDir()
+
+	var errs []error
+
+	summary := &flakySummary{StreamSummary: hh.NewStreamSummary[string](8), fail: true}
+	m, _, err := Open[string](dir, summary, WithLog(), WithHitCount(2), WithOnError(func(err error) {
+		errs = append(errs, err)
+	}))
+	require.NoError(t, err)
+
+	m.Hit("a")
+	m.Hit("b")
+	require.Error(t, m.Err())
+	require.Len(t, errs, 1)
+
+	// the hits of the failed snapshot are still logged, and the snapshot is retried after as many hits.
+	summary.fail = false
+	m.Hit("c")
+	require.Error(t, m.Err())
+	m.Hit("d")
+	require.NoError(t, m.Err())
+	require.Len(t, errs, 1)
+
+	m.Hit("e")
+	require.NoError(t, m.Sync())
+
+	restored := hh.NewStreamSummary[string](8)
+	_, recovery, err := Open[string](dir, restored, WithLog())
+	require.NoError(t, err)
+	require.Equal(t, Recovery{Restored: true, Generation: 1, Replayed: 1}, recovery)
+	require.Equal(t, 5, restored.Hits())
+}
+
+func TestManager_RemoveLogs(t *testing.T) {
+	dir := t.TempDir()
+
+	m, _, err := Open[string](dir, hh.NewStreamSummary[string](8), WithLog())
+	require.NoError(t, err)
+
+	m.Hit("a")
+	require.NoError(t, m.Checkpoint())
+
+	// logs left behind by snapshots that failed to remove them.
+	for _, name := range []string{"hits-0.log", "hits-10.log"} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), nil, 0o644))
+	}
+
+	require.NoError(t, m.Checkpoint())
+
+	logs, err := filepath.Glob(filepath.Join(dir, "*.log"))
+	require.NoError(t, err)
+	require.Equal(t, []string{filepath.Join(dir, "hits-10.log"), filepath.Join(dir, "hits-2.log")}, logs)
+}
+
+func TestManager_CloseTwice(t *testing.T) {
+	m, _, err := Open[string](t.TempDir(), hh.NewStreamSummary[string](8), WithLog(), WithInterval(time.Hour))
+	require.NoError(t, err)
+
+	m.Hit("a")
+	require.NoError(t, m.Close())
+	require.NoError(t, m.Close())
+}
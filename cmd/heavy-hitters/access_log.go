@@ -0,0 +1,106 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	hh "heavy-hitters"
+	"heavy-hitters/accesslog"
+	"heavy-hitters/report"
+	"io"
+	"slices"
+	"strings"
+)
+
+// defaultDimensions are the fields reported by the access-log command when the log format has them.
+var defaultDimensions = []string{"remote_addr", accesslog.RequestURI, "status", "http_user_agent"}
+
+// runAccessLog executes the access-log command with the given arguments, excluding the command name.
+func runAccessLog(args []string, stdin io.Reader, stdout io.Writer) error {
+	flags := flag.NewFlagSet("heavy-hitters access-log", flag.ContinueOnError)
+	flags.SetOutput(stdout)
+
+	k := flags.Int("k", 10, "number of top values to report for each field")
+	capacity := flags.Int("capacity", 1000, "number of counters for each field; the error is bounded by lines / capacity")
+	logFormat := flags.String("log-format", "combined", "common, combined or an nginx log_format string")
+	dimensions := flags.String("fields", strings.Join(defaultDimensions, ","), "comma-separated fields to report the top values of")
+	weight := flags.String("weight", "", "numeric field to weight each line by, such as body_bytes_sent")
+	outputFormat := flags.String("format", "table", "output format: "+strings.Join(report.Formats, ", "))
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	formatter, err := report.ByName(*outputFormat)
+	if err != nil {
+		return err
+	}
+
+	format, err := accesslog.ParseFormat(*logFormat)
+	if err != nil {
+		return err
+	}
+
+	fields := strings.Split(*dimensions, ",")
+
+	// the default fields are reported only when the log format has them.
+	if !isFlagSet(flags, "fields") {
+		fields = slices.DeleteFunc(fields, func(field string) bool {
+			return !slices.Contains(format.Fields(), field)
+		})
+	}
+
+	analyzer, err := accesslog.NewAnalyzer(format, fields, *weight, hh.WithCapacity[string](*capacity), hh.WithTieBreak[string](hh.TieBreakKey))
+	if err != nil {
+		return err
+	}
+
+	paths := flags.Args()
+	if len(paths) == 0 {
+		paths = []string{"-"}
+	}
+
+	var stats accesslog.Stats
+
+	for _, path := range paths {
+		s, err := analyzeFile(path, stdin, analyzer)
+		stats.Add(s)
+
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	}
+
+	results := make([]report.Result, 0, len(fields))
+
+	for _, dimension := range analyzer.Dimensions() {
+		result := report.Top[string](analyzer.Summary(dimension), *k)
+		result.Name = dimension
+		results = append(results, result)
+	}
+
+	description := fmt.Sprintf("Lines: %d, skipped: %d", stats.Lines, stats.Skipped)
+
+	return writeResults(stdout, *outputFormat, formatter, description, results...)
+}
+
+// isFlagSet reports whether the named flag was given on the command line.
+func isFlagSet(flags *flag.FlagSet, name string) bool {
+	set := false
+
+	flags.Visit(func(f *flag.Flag) {
+		set = set || f.Name == name
+	})
+
+	return set
+}
+
+func analyzeFile(path string, stdin io.Reader, analyzer *accesslog.Analyzer) (accesslog.Stats, error) {
+	r, closeInput, err := openInput(path, stdin)
+	if err != nil {
+		return accesslog.Stats{}, err
+	}
+
+	defer closeInput()
+
+	return analyzer.Analyze(r)
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func TestRunAccessLog(t *testing.T) {
+	var stdout bytes.Buffer
+	require.NoError(t, run([]string{"access-log", "-k", "2", "-log-format", "common", "../../accesslog/testdata/access.log"}, strings.NewReader(""), &stdout))
+
+	require.Equal(t, `Lines: 7, skipped: 1
+
+remote_addr (hits: 7, guaranteed: true, ordered: true)
+RANK  KEY       COUNT  ERROR  LOWER BOUND  SHARE
+1     10.0.0.1  4      0      4            57.14%
+2     10.0.0.2  1      0      1            14.29%
+
+request_uri (hits: 6, guaranteed: true, ordered: true)
+RANK  KEY          COUNT  ERROR  LOWER BOUND  SHARE
+1     /index.html  3      0      3            50.00%
+2     /about       1      0      1            16.67%
+
+status (hits: 7, guaranteed: true, ordered: true)
+RANK  KEY  COUNT  ERROR  LOWER BOUND  SHARE
+1     200  4      0      4            57.14%
+2     302  1      0      1            14.29%
+`, stdout.String())
+}
+
+func TestRunAccessLog_Weight(t *testing.T) {
+	input := `10.0.0.1 - - [18/Oct/2026:10:00:00 +0000] "GET /a HTTP/1.1" 200 100 "-" "curl/8.5.0"
+10.0.0.2 - - [18/Oct/2026:10:00:01 +0000] "GET /b HTTP/1.1" 200 300 "-" "curl/8.5.0"
+10.0.0.1 - - [18/Oct/2026:10:00:02 +0000] "GET /a HTTP/1.1" 200 100 "-" "curl/8.5.0"
+`
+
+	var stdout bytes.Buffer
+	require.NoError(t, run([]string{"access-log", "-k", "1", "-fields", "request_uri", "-weight", "body_bytes_sent", "-format", "prom"}, strings.NewReader(input), &stdout))
+	require.Contains(t, stdout.String(), `heavy_hitters_count{summary="request_uri",key="/b"} 300`)
+	require.Contains(t, stdout.String(), `heavy_hitters_hits_total{summary="request_uri"} 500`)
+}
+
+func TestRunAccessLog_Invalid(t *testing.T) {
+	var stdout bytes.Buffer
+	require.Error(t, run([]string{"access-log", "-log-format", "no variables"}, strings.NewReader(""), &stdout))
+	require.Error(t, run([]string{"access-log", "-fields", "upstream_addr"}, strings.NewReader(""), &stdout))
+	require.Error(t, run([]string{"access-log", "missing.log"}, strings.NewReader(""), &stdout))
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	hh "heavy-hitters"
+	"heavy-hitters/evaluate"
+	"heavy-hitters/ingest"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// implementations creates the heavy hitters implementations that can be evaluated, by name.
+var implementations = map[string]func(capacity int) hh.HeavyHitters[string]{
+	"stream": func(capacity int) hh.HeavyHitters[string] {
+		return hh.NewStreamSummary[string](capacity, hh.WithTieBreak[string](hh.TieBreakKey))
+	},
+	"compact": func(capacity int) hh.HeavyHitters[string] {
+		return hh.NewCompactStreamSummary[string](capacity)
+	},
+}
+
+// runEvaluate executes the evaluate command with the given arguments, excluding the command name.
+// It feeds the input to every implementation and capacity along with the exact counts, and reports their accuracy and cost.
+func runEvaluate(args []string, stdin io.Reader, stdout io.Writer) error {
+	flags := flag.NewFlagSet("heavy-hitters evaluate", flag.ContinueOnError)
+	flags.SetOutput(stdout)
+
+	k := flags.Int("k", 10, "evaluate the top-k elements")
+	phi := flags.Float64("phi", 0.01, "evaluate the elements that contribute more than phi of all tokens")
+	summaries := flags.String("summaries", "stream,compact", "comma-separated implementations to evaluate: stream or compact")
+	capacities := flags.String("capacity", "100,1000", "comma-separated numbers of counters to evaluate every implementation with")
+	checkInterval := flags.Int("check-interval", 10_000, "number of tokens between checks of the guarantees")
+	newSource := sourceFlags(flags)
+	format := flags.String("format", "table", "output format: table or json")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *format != "table" && *format != "json" {
+		return fmt.Errorf("unknown format %q, expected table or json", *format)
+	}
+
+	var candidates []evaluate.Candidate[string]
+
+	for _, name := range strings.Split(*summaries, ",") {
+		implementation, ok := implementations[name]
+		if !ok {
+			return fmt.Errorf("unknown implementation %q, expected stream or compact", name)
+		}
+
+		for _, c := range strings.Split(*capacities, ",") {
+			capacity, err := strconv.Atoi(c)
+			if err != nil || capacity < 1 {
+				return fmt.Errorf("invalid capacity %q", c)
+			}
+
+			candidates = append(candidates, evaluate.Candidate[string]{
+				Name:    fmt.Sprintf("%s/%d", name, capacity),
+				Summary: implementation(capacity),
+			})
+		}
+	}
+
+	var stats ingest.Stats
+
+	stream := func(hit func(e string, weight int)) error {
+		source, err := newSource(hitFunc(hit))
+		if err != nil {
+			return err
+		}
+
+		stats, err = ingestFiles(flags.Args(), stdin, source)
+
+		return err
+	}
+
+	results, err := evaluate.Evaluate(stream, candidates, evaluate.WithK(*k), evaluate.WithPhi(*phi), evaluate.WithCheckInterval(*checkInterval))
+	if err != nil {
+		return err
+	}
+
+	if *format == "json" {
+		return writeEvaluationJSON(stdout, results)
+	}
+
+	fmt.Fprintf(stdout, "Bytes: %d, tokens: %d, top-%d, phi: %g\n\n", stats.Bytes, stats.Tokens, *k, *phi)
+
+	table := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "SUMMARY\tFREQUENT PRECISION\tFREQUENT RECALL\tTOP PRECISION\tTOP RECALL\tRELATIVE ERROR\tVIOLATIONS\tMEMORY\tHITS/S")
+
+	for _, r := range results {
+		fmt.Fprintf(table, "%s\t%.3f\t%.3f\t%.3f\t%.3f\t%.4f\t%d/%d\t%d\t%.0f\n",
+			r.Name, r.Frequent.Precision, r.Frequent.Recall, r.Top.Precision, r.Top.Recall, r.MeanRelativeError,
+			r.Violations.Total(), r.Violations.Checks, r.Memory, r.Throughput)
+	}
+
+	return table.Flush()
+}
+
+// jsonEvaluation is the JSON encoding of the evaluation of a summary.
+type jsonEvaluation struct {
+	Name              string         `json:"name"`
+	Frequent          jsonAccuracy   `json:"frequent"`
+	Top               jsonAccuracy   `json:"top"`
+	MeanRelativeError float64        `json:"mean_relative_error"`
+	Violations        map[string]int `json:"violations"`
+	Memory            int            `json:"memory"`
+	Seconds           float64        `json:"seconds"`
+	Throughput        float64        `json:"throughput"`
+}
+
+// jsonAccuracy is the JSON encoding of an accuracy.
+type jsonAccuracy struct {
+	Precision float64 `json:"precision"`
+	Recall    float64 `json:"recall"`
+}
+
+func writeEvaluationJSON(w io.Writer, results []evaluate.Result) error {
+	encoded := make([]jsonEvaluation, len(results))
+
+	for i, r := range results {
+		encoded[i] = jsonEvaluation{
+			Name:              r.Name,
+			Frequent:          jsonAccuracy(r.Frequent),
+			Top:               jsonAccuracy(r.Top),
+			MeanRelativeError: r.MeanRelativeError,
+			Violations: map[string]int{
+				"checks":   r.Violations.Checks,
+				"frequent": r.Violations.Frequent,
+				"top":      r.Violations.Top,
+				"order":    r.Violations.Order,
+			},
+			Memory:     r.Memory,
+			Seconds:    r.Duration.Seconds(),
+			Throughput: r.Throughput,
+		}
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(encoded)
+}
+
+// hitFunc adapts a function to the summary expected by sources, which only hit the summary.
+// Its queries report an empty summary.
+type hitFunc func(e string, weight int)
+
+func (f hitFunc) Hit(e string) hh.Count {
+	return f.HitN(e, 1)
+}
+
+func (f hitFunc) HitN(e string, weight int) hh.Count {
+	f(e, weight)
+	return hh.Count{}
+}
+
+func (f hitFunc) Hits() int {
+	return 0
+}
+
+func (f hitFunc) Get(string) (hh.Count, bool) {
+	return hh.Count{}, false
+}
+
+func (f hitFunc) Frequent(float64) ([]string, bool) {
+	return nil, true
+}
+
+func (f hitFunc) Top(int) ([]string, bool, bool) {
+	return nil, true, true
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func TestRunEvaluate(t *testing.T) {
+	input := strings.Repeat("a a a b b c\n", 100) + "d e f g h\n"
+
+	var stdout bytes.Buffer
+	require.NoError(t, run([]string{"evaluate", "-k", "2", "-capacity", "2,10", "-check-interval", "50"}, strings.NewReader(input), &stdout))
+
+	lines := strings.Split(stdout.String(), "\n")
+	require.Equal(t, "Bytes: 1210, tokens: 605, top-2, phi: 0.01", lines[0])
+	require.Regexp(t, `^SUMMARY\s+FREQUENT PRECISION\s+FREQUENT RECALL\s+TOP PRECISION\s+TOP RECALL\s+RELATIVE ERROR\s+VIOLATIONS\s+MEMORY\s+HITS/S$`, lines[2])
+	require.Regexp(t, `^exact\s+1\.000\s+1\.000\s+1\.000\s+1\.000\s+0\.0000\s+0/0\s+\d+\s+\d+$`, lines[3])
+	require.Regexp(t, `^stream/2\s`, lines[4])
+	require.Regexp(t, `^stream/10\s+1\.000\s+1\.000\s+1\.000\s+1\.000\s+0\.0000\s+0/13\s`, lines[5])
+	require.Regexp(t, `^compact/2\s`, lines[6])
+	require.Regexp(t, `^compact/10\s+1\.000\s+1\.000\s+1\.000\s+1\.000\s+0\.0000\s+0/13\s`, lines[7])
+}
+
+func TestRunEvaluate_JSON(t *testing.T) {
+	var stdout bytes.Buffer
+	require.NoError(t, run([]string{"evaluate", "-summaries", "compact", "-capacity", "4", "-format", "json"}, strings.NewReader("a b a"), &stdout))
+
+	var results []map[string]any
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), &results))
+	require.Len(t, results, 2)
+	require.Equal(t, "compact/4", results[1]["name"])
+	require.Equal(t, map[string]any{"precision": 1.0, "recall": 1.0}, results[1]["top"])
+	require.Equal(t, map[string]any{"checks": 1.0, "frequent": 0.0, "top": 0.0, "order": 0.0}, results[1]["violations"])
+}
+
+func TestRunEvaluate_Invalid(t *testing.T) {
+	var stdout bytes.Buffer
+	require.Error(t, run([]string{"evaluate", "-summaries", "naive"}, strings.NewReader(""), &stdout))
+	require.Error(t, run([]string{"evaluate", "-capacity", "0"}, strings.NewReader(""), &stdout))
+	require.Error(t, run([]string{"evaluate", "-format", "csv"}, strings.NewReader(""), &stdout))
+	require.Error(t, run([]string{"evaluate", "-phi", "2"}, strings.NewReader(""), &stdout))
+	require.Error(t, run([]string{"evaluate", "-tokenizer", "unknown"}, strings.NewReader(""), &stdout))
+	require.Error(t, run([]string{"evaluate", "missing.txt"}, strings.NewReader(""), &stdout))
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"heavy-hitters/ingest"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// followPoll is the interval between checks of a followed file for new data, rotation and truncation.
+const followPoll = 250 * time.Millisecond
+
+// followSignals returns a context cancelled on SIGINT or SIGTERM, which ends following a file or serving,
+// along with a channel receiving the signals requesting a report of the current results, where supported.
+// Calling stop restores the default behavior of the signals.
+// It is a variable so tests can deliver the signals without signalling the process.
+var followSignals = func() (ctx context.Context, requests <-chan os.Signal, stop func()) {
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	c := make(chan os.Signal, 1)
+
+	if len(reportSignals) > 0 {
+		signal.Notify(c, reportSignals...)
+	}
+
+	return ctx, c, func() {
+		signal.Stop(c)
+		cancel()
+	}
+}
+
+// followFile streams the file at path into the source as it grows, until the context is done.
+func followFile(ctx context.Context, path string, source source) (ingest.Stats, error) {
+	follower := ingest.Follow(ctx, path, followPoll)
+	defer follower.Close()
+
+	return source(follower)
+}
+
+// followReports streams the file at path into the source until the context is done,
+// calling report at every interval, unless it is zero, and on every request.
+func followReports(ctx context.Context, path string, source source, interval time.Duration, requests <-chan os.Signal, report func() error) (ingest.Stats, error) {
+	type result struct {
+		stats ingest.Stats
+		err   error
+	}
+
+	done := make(chan result, 1)
+
+	go func() {
+		stats, err := followFile(ctx, path, source)
+		done <- result{stats, err}
+	}()
+
+	var ticks <-chan time.Time
+
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		ticks = ticker.C
+	}
+
+	for {
+		select {
+		case <-ticks:
+		case <-requests:
+		case r := <-done:
+			return r.stats, r.err
+		}
+
+		if err := report(); err != nil {
+			return ingest.Stats{}, err
+		}
+	}
+}
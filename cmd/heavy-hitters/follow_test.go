@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// lockedBuffer is a buffer written by a command while the test reads it.
+type lockedBuffer struct {
+	mutex  sync.Mutex
+	buffer bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	return b.buffer.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	return b.buffer.String()
+}
+
+// fakeSignals replaces the signals of followed files with a cancel function and a channel of requests for reports.
+func fakeSignals(t *testing.T) (context.CancelFunc, chan<- os.Signal) {
+	ctx, cancel := context.WithCancel(context.Background())
+	requests := make(chan os.Signal)
+	original := followSignals
+
+	followSignals = func() (context.Context, <-chan os.Signal, func()) {
+		return ctx, requests, cancel
+	}
+
+	t.Cleanup(func() {
+		followSignals = original
+		cancel()
+	})
+
+	return cancel, requests
+}
+
+func appendFile(t *testing.T, path string, data string) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	require.NoError(t, err)
+	_, err = f.WriteString(data)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+}
+
+func TestRun_Follow(t *testing.T) {
+	cancel, requests := fakeSignals(t)
+
+	path := filepath.Join(t.TempDir(), "app.log")
+	appendFile(t, path, "a\nb\na\n")
+
+	var stdout lockedBuffer
+	done := make(chan error)
+
+	go func() {
+		done <- run([]string{"-follow", "-interval", "0", "-tokenizer", "lines", "-k", "1", "-format", "csv", path}, strings.NewReader(""), &stdout)
+	}()
+
+	require.Eventually(t, func() bool {
+		requests <- os.Interrupt
+		return strings.HasSuffix(stdout.String(), "top,1,a,2,0,2,0.6666666666666666\n")
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// rotating the file keeps the counts of the old file.
+	require.NoError(t, os.Rename(path, path+".1"))
+	appendFile(t, path, "b\nb\nb\n")
+
+	require.Eventually(t, func() bool {
+		requests <- os.Interrupt
+		return strings.HasSuffix(stdout.String(), "top,1,b,4,0,4,0.6666666666666666\n")
+	}, 5*time.Second, 10*time.Millisecond)
+
+	cancel()
+	require.NoError(t, <-done)
+
+	reports := strings.Split(strings.TrimSuffix(stdout.String(), "\n"), "result,rank,key,count,error,lower_bound,share\n")
+	require.Equal(t, `frequent,1,b,4,0,4,0.6666666666666666
+frequent,2,a,2,0,2,0.3333333333333333
+top,1,b,4,0,4,0.6666666666666666`, reports[len(reports)-1])
+}
+
+func TestRun_FollowInterval(t *testing.T) {
+	cancel, _ := fakeSignals(t)
+
+	path := filepath.Join(t.TempDir(), "app.log")
+	appendFile(t, path, "a\n")
+
+	var stdout lockedBuffer
+	done := make(chan error)
+
+	go func() {
+		done <- run([]string{"-follow", "-interval", "10ms", "-tokenizer", "lines", path}, strings.NewReader(""), &stdout)
+	}()
+
+	require.Eventually(t, func() bool {
+		return strings.Contains(stdout.String(), "Following "+path+", hits: 1\n")
+	}, 5*time.Second, time.Millisecond)
+
+	cancel()
+	require.NoError(t, <-done)
+	require.Contains(t, stdout.String(), "Bytes: 2, tokens: 1\n")
+}
+
+func TestRunTop_Follow(t *testing.T) {
+	cancel, _ := fakeSignals(t)
+
+	path := filepath.Join(t.TempDir(), "app.log")
+	appendFile(t, path, "a\n")
+
+	var stdout lockedBuffer
+	done := make(chan error)
+
+	go func() {
+		done <- run([]string{"top", "-follow", "-interval", "10ms", "-tokenizer", "lines", path}, strings.NewReader(""), &stdout)
+	}()
+
+	appendFile(t, path, "b\nb\n")
+	require.Eventually(t, func() bool { return strings.Contains(stdout.String(), "hits: 3") }, 5*time.Second, time.Millisecond)
+
+	cancel()
+	require.NoError(t, <-done)
+	require.Regexp(t, `1\s+\S+\s+b\s+\S+\s+2\s+0\n2\s+\S+\s+a\s+\S+\s+1\s+0\n\n$`, stdout.String())
+}
+
+func TestRun_FollowInvalid(t *testing.T) {
+	var stdout bytes.Buffer
+	require.Error(t, run([]string{"-follow"}, strings.NewReader(""), &stdout))
+	require.Error(t, run([]string{"-follow", "-"}, strings.NewReader(""), &stdout))
+	require.Error(t, run([]string{"-follow", "a.log", "b.log"}, strings.NewReader(""), &stdout))
+	require.Error(t, run([]string{"-interval", "-1s"}, strings.NewReader(""), &stdout))
+	require.Error(t, run([]string{"top", "-follow"}, strings.NewReader(""), &stdout))
+}
@@ -0,0 +1,423 @@
+// Command heavy-hitters approximates the frequent and top-k elements of files or stdin using the SpaceSaving algorithm.
+//
+// Usage:
+//
+//	heavy-hitters [flags] [file ...]
+//	heavy-hitters access-log [flags] [file ...]
+//	heavy-hitters pcap [flags] [file ...]
+//	heavy-hitters top [flags] [file ...]
+//	heavy-hitters evaluate [flags] [file ...]
+//	heavy-hitters merge [flags] summary ...
+//	heavy-hitters inspect [flags] summary
+//	heavy-hitters serve [flags]
+//	heavy-hitters resp [flags]
+//	heavy-hitters statsd [flags]
+//
+// Each file, or stdin when no files are given, is streamed through a tokenizer and every token is counted as one element.
+// With -records, the files are read as CSV, TSV or JSON lines instead, and a key built from the -key fields of each record is counted.
+// Files compressed with gzip or bzip2 are detected and decompressed by every command, and several files are read in parallel.
+// With -follow, a single file is read as it grows, like tail -F, surviving rotation and truncation.
+// The results are written every -interval and on SIGUSR1, and a final summary is written on SIGINT or SIGTERM.
+// The access-log command reports the top values of several fields of web server access logs, such as client addresses and paths.
+// The pcap command reports the heaviest flows and addresses of pcap or pcapng capture files.
+// The evaluate command compares the accuracy, memory and throughput of implementations and capacities against the exact counts of the input.
+// The merge command combines summaries saved in the binary format, such as by separate batch jobs, and inspect dumps one of them.
+// The serve command serves named summaries over HTTP, with endpoints to count hits, query them and save or restore snapshots.
+// The resp command serves the TOPK commands of RedisBloom over the Redis protocol, so Redis clients can use it in place of Redis.
+// The statsd command receives StatsD and DogStatsD metrics over UDP and reports the top metric names, tags and sources.
+// The top command redraws the top elements of a stream while it is read, along with their rates and rank movements.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	hh "heavy-hitters"
+	"heavy-hitters/ingest"
+	"heavy-hitters/report"
+	"io"
+	"os"
+	"regexp"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+func main() {
+	if err := run(os.Args[1:], os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// run executes the command with the given arguments, excluding the program name.
+func run(args []string, stdin io.Reader, stdout io.Writer) error {
+	if len(args) > 0 {
+		switch args[0] {
+		case "access-log":
+			return runAccessLog(args[1:], stdin, stdout)
+		case "pcap":
+			return runPcap(args[1:], stdin, stdout)
+		case "top":
+			return runTop(args[1:], stdin, stdout)
+		case "evaluate":
+			return runEvaluate(args[1:], stdin, stdout)
+		case "merge":
+			return runMerge(args[1:], stdout)
+		case "inspect":
+			return runInspect(args[1:], stdout)
+		case "serve":
+			return runServe(args[1:], stdout)
+		case "resp":
+			return runResp(args[1:], stdout)
+		case "statsd":
+			return runStatsd(args[1:], stdout)
+		}
+	}
+
+	flags := flag.NewFlagSet("heavy-hitters", flag.ContinueOnError)
+	flags.SetOutput(stdout)
+
+	k := flags.Int("k", 6, "number of top elements to report")
+	phi := flags.Float64("phi", 0.01, "report elements that contribute more than phi of all tokens")
+	capacity := flags.Int("capacity", 1000, "number of counters; the error is bounded by tokens / capacity")
+	newSource := sourceFlags(flags)
+	format := flags.String("format", "table", "output format: "+strings.Join(report.Formats, ", "))
+	follow := flags.Bool("follow", false, "keep reading the file as it grows, like tail -F, until SIGINT or SIGTERM")
+	interval := flags.Duration("interval", 10*time.Second, "time between reports while following a file, or 0 to only report on SIGUSR1")
+	parallel := flags.Int("parallel", runtime.GOMAXPROCS(0), "number of files to read in parallel, each into its own summary merged at the end")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	formatter, err := report.ByName(*format)
+	if err != nil {
+		return err
+	}
+
+	if *follow && (flags.NArg() != 1 || flags.Arg(0) == "-") {
+		return errors.New("-follow requires a single file")
+	}
+
+	if *interval < 0 {
+		return fmt.Errorf("invalid interval %s", *interval)
+	}
+
+	newSummary := func() (*hh.StreamSummary[string], error) {
+		return hh.New(hh.WithCapacity[string](*capacity), hh.WithTieBreak[string](hh.TieBreakKey))
+	}
+
+	write := func(summary hh.HeavyHitters[string], description string) error {
+		return writeResults(stdout, *format, formatter, description, report.Frequent(summary, *phi), report.Top(summary, *k))
+	}
+
+	var summary hh.HeavyHitters[string]
+	var stats ingest.Stats
+
+	if *follow {
+		s, err := newSummary()
+		if err != nil {
+			return err
+		}
+
+		// a followed file is reported while it is being read.
+		synchronized := hh.NewSynchronized[string](s)
+		summary = synchronized
+
+		source, err := newSource(synchronized)
+		if err != nil {
+			return err
+		}
+
+		ctx, requests, stop := followSignals()
+		defer stop()
+
+		stats, err = followReports(ctx, flags.Arg(0), source, *interval, requests, func() (err error) {
+			synchronized.Do(func(summary hh.WeightedHeavyHitters[string]) {
+				err = write(summary, fmt.Sprintf("Following %s, hits: %d", flags.Arg(0), summary.Hits()))
+			})
+
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	} else {
+		if *parallel < 1 {
+			return fmt.Errorf("invalid parallelism %d", *parallel)
+		}
+
+		merged, s, err := ingestParallel(flags.Args(), stdin, *parallel, newSummary, newSource)
+		if err != nil {
+			return err
+		}
+
+		summary, stats = merged, s
+	}
+
+	description := fmt.Sprintf("Bytes: %d, tokens: %d", stats.Bytes, stats.Tokens)
+
+	if stats.Skipped > 0 {
+		description += fmt.Sprintf(", skipped: %d", stats.Skipped)
+	}
+
+	return write(summary, description)
+}
+
+// source streams a reader into a summary.
+type source func(r io.Reader) (ingest.Stats, error)
+
+// sourceFlags registers the flags choosing how the input is split into elements, either by a tokenizer or from structured records.
+// The returned function creates the source hitting a summary once the flags are parsed.
+func sourceFlags(flags *flag.FlagSet) func(summary hh.WeightedHeavyHitters[string]) (source, error) {
+	tokenizerName := flags.String("tokenizer", "words", "how to split the input into tokens: lines, words, word-ngrams, byte-ngrams or regexp")
+	n := flags.Int("n", 2, "number of words or bytes in each n-gram")
+	pattern := flags.String("regexp", "", "regular expression to match against each line for the regexp tokenizer")
+	group := flags.Int("group", 1, "capture group of the regular expression to count")
+	records := flags.String("records", "", "read structured records instead of tokens: csv, tsv or jsonl")
+	key := flags.String("key", "1", "comma-separated fields of each record to build the key from: column names or indices, or JSON paths")
+	weight := flags.String("weight", "", "field of each record to weight its hit by")
+	separator := flags.String("separator", "|", "separator between the fields of a key")
+	header := flags.Bool("header", false, "the first CSV or TSV record is a header naming the columns")
+
+	return func(summary hh.WeightedHeavyHitters[string]) (source, error) {
+		if *records != "" {
+			extractor, err := newExtractor(*records, *key, *weight, *separator, *header)
+			if err != nil {
+				return nil, err
+			}
+
+			return func(r io.Reader) (ingest.Stats, error) {
+				return ingest.Extract(r, summary, extractor)
+			}, nil
+		}
+
+		tokenizer, err := newTokenizer(*tokenizerName, *n, *pattern, *group)
+		if err != nil {
+			return nil, err
+		}
+
+		return func(r io.Reader) (ingest.Stats, error) {
+			return ingest.Ingest(r, summary, tokenizer)
+		}, nil
+	}
+}
+
+// newTokenizer creates the tokenizer with the given name.
+func newTokenizer(name string, n int, pattern string, group int) (ingest.Tokenizer, error) {
+	switch name {
+	case "lines":
+		return ingest.Lines(), nil
+	case "words":
+		return ingest.Words(), nil
+	case "word-ngrams":
+		return ingest.WordNGrams(n)
+	case "byte-ngrams":
+		return ingest.ByteNGrams(n)
+	case "regexp":
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, err
+		}
+
+		return ingest.Regexp(re, group)
+	default:
+		return nil, fmt.Errorf("unknown tokenizer %q", name)
+	}
+}
+
+// newExtractor creates the extractor of keys from records in the named format.
+func newExtractor(format string, key string, weight string, separator string, header bool) (*ingest.Extractor, error) {
+	f, err := ingest.ParseFormat(format)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := []ingest.ExtractorOption{ingest.WithSeparator(separator)}
+
+	if weight != "" {
+		opts = append(opts, ingest.WithWeight(weight))
+	}
+
+	if header {
+		opts = append(opts, ingest.WithHeader())
+	}
+
+	return ingest.NewExtractor(f, strings.Split(key, ","), opts...)
+}
+
+// ingestFiles streams every file into the source, or stdin if there are no files or a file is named "-".
+func ingestFiles(paths []string, stdin io.Reader, source source) (ingest.Stats, error) {
+	var total ingest.Stats
+
+	if len(paths) == 0 {
+		paths = []string{"-"}
+	}
+
+	for _, path := range paths {
+		stats, err := ingestFile(path, stdin, source)
+		total.Add(stats)
+
+		if err != nil {
+			return total, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+
+	return total, nil
+}
+
+// ingestParallel streams the files into up to workers summaries in parallel, each file being read by a single worker.
+// The summaries are merged at the end, so the error of the merged summary stays bounded by hits / capacity.
+// The first error stops the workers, abandoning the files being read and those not read yet.
+func ingestParallel(
+	paths []string,
+	stdin io.Reader,
+	workers int,
+	newSummary func() (*hh.StreamSummary[string], error),
+	newSource func(summary hh.WeightedHeavyHitters[string]) (source, error),
+) (*hh.StreamSummary[string], ingest.Stats, error) {
+	if len(paths) == 0 {
+		paths = []string{"-"}
+	}
+
+	// stdin can only be read once, and concurrent readers would split its lines between them.
+	if i := slices.Index(paths, "-"); i >= 0 && slices.Contains(paths[i+1:], "-") {
+		return nil, ingest.Stats{}, errors.New(`stdin ("-") can only be read once`)
+	}
+
+	shards := make([]*hh.StreamSummary[string], min(workers, len(paths)))
+	sources := make([]source, len(shards))
+
+	for i := range shards {
+		shard, err := newSummary()
+		if err != nil {
+			return nil, ingest.Stats{}, err
+		}
+
+		if sources[i], err = newSource(shard); err != nil {
+			return nil, ingest.Stats{}, err
+		}
+
+		shards[i] = shard
+	}
+
+	ctx, cancel := context.WithCancelCause(context.Background())
+	defer cancel(nil)
+
+	stats := make([]ingest.Stats, len(paths))
+	next := make(chan int)
+
+	var wg sync.WaitGroup
+
+	for _, source := range sources {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for i := range next {
+				var err error
+
+				stats[i], err = ingestFile(paths[i], stdin, func(r io.Reader) (ingest.Stats, error) {
+					return source(&cancellableReader{ctx: ctx, reader: r})
+				})
+
+				if err != nil {
+					// only the first cause is kept, so files abandoned because of it do not replace it.
+					cancel(fmt.Errorf("%s: %w", paths[i], err))
+				}
+			}
+		}()
+	}
+
+dispatch:
+	for i := range paths {
+		select {
+		case next <- i:
+		case <-ctx.Done():
+			break dispatch
+		}
+	}
+
+	close(next)
+	wg.Wait()
+
+	var total ingest.Stats
+
+	for _, s := range stats {
+		total.Add(s)
+	}
+
+	if err := context.Cause(ctx); err != nil {
+		return nil, total, err
+	}
+
+	for _, shard := range shards[1:] {
+		shards[0].Merge(shard)
+	}
+
+	return shards[0], total, nil
+}
+
+// cancellableReader stops reading once its context is done.
+type cancellableReader struct {
+	ctx    context.Context
+	reader io.Reader
+}
+
+func (r *cancellableReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	return r.reader.Read(p)
+}
+
+func ingestFile(path string, stdin io.Reader, source source) (ingest.Stats, error) {
+	r, closeInput, err := openInput(path, stdin)
+	if err != nil {
+		return ingest.Stats{}, err
+	}
+
+	defer closeInput()
+
+	return source(r)
+}
+
+// openInput opens the file at path, or stdin if it is named "-", decompressing it on the fly if it is compressed with gzip or bzip2.
+// The returned function closes the file.
+func openInput(path string, stdin io.Reader) (io.Reader, func() error, error) {
+	var r io.Reader = stdin
+	closeInput := func() error { return nil }
+
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		r, closeInput = f, f.Close
+	}
+
+	decompressed, _, err := ingest.Decompress(r)
+	if err != nil {
+		closeInput()
+		return nil, nil, err
+	}
+
+	return decompressed, closeInput, nil
+}
+
+// writeResults writes the results with the formatter of the named format.
+// The table and markdown formats are meant for people, so they start with a description of the input.
+func writeResults(w io.Writer, format string, formatter report.Formatter, description string, results ...report.Result) error {
+	if format == "table" || format == "markdown" {
+		fmt.Fprintf(w, "%s\n\n", description)
+	}
+
+	return formatter.Format(w, results...)
+}
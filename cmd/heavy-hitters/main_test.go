@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "input.txt")
+	require.NoError(t, os.WriteFile(path, []byte("1\n2\n2\n3\n3\n3\n"), 0o644))
+
+	var stdout bytes.Buffer
+	require.NoError(t, run([]string{"-k", "2", path}, strings.NewReader(""), &stdout))
+
+	require.Equal(t, `Bytes: 12, tokens: 6
+
+frequent (hits: 6, guaranteed: true, ordered: false)
+RANK  KEY  COUNT  ERROR  LOWER BOUND  SHARE
+1     3    3      0      3            50.00%
+2     2    2      0      2            33.33%
+
+top (hits: 6, guaranteed: true, ordered: true)
+RANK  KEY  COUNT  ERROR  LOWER BOUND  SHARE
+1     3    3      0      3            50.00%
+2     2    2      0      2            33.33%
+`, stdout.String())
+}
+
+func TestRun_Stdin(t *testing.T) {
+	var stdout bytes.Buffer
+	require.NoError(t, run([]string{"-k", "1", "-tokenizer", "regexp", "-regexp", `id=(\d+)`}, strings.NewReader("id=1 id=2\nid=2\n"), &stdout))
+	require.Contains(t, stdout.String(), "1     2    2      0      2            66.67%")
+}
+
+func TestRun_Records(t *testing.T) {
+	input := `{"path": "/a", "bytes": 10}
+{"path": "/b", "bytes": 30}
+{"path": "/a", "bytes": 15}
+{"bytes": 1}
+`
+
+	var stdout bytes.Buffer
+	require.NoError(t, run([]string{"-k", "2", "-records", "jsonl", "-key", "$.path", "-weight", "$.bytes"}, strings.NewReader(input), &stdout))
+
+	require.Equal(t, fmt.Sprintf(`Bytes: %d, tokens: 3, skipped: 1
+
+frequent (hits: 55, guaranteed: true, ordered: false)
+RANK  KEY  COUNT  ERROR  LOWER BOUND  SHARE
+1     /b   30     0      30           54.55%%
+2     /a   25     0      25           45.45%%
+
+top (hits: 55, guaranteed: false, ordered: true)
+RANK  KEY  COUNT  ERROR  LOWER BOUND  SHARE
+1     /b   30     0      30           54.55%%
+2     /a   25     0      25           45.45%%
+`, len(input)), stdout.String())
+}
+
+func TestRun_Format(t *testing.T) {
+	var stdout bytes.Buffer
+	require.NoError(t, run([]string{"-k", "1", "-phi", "0.3", "-format", "csv"}, strings.NewReader("a b a"), &stdout))
+
+	require.Equal(t, `result,rank,key,count,error,lower_bound,share
+frequent,1,a,2,0,2,0.6666666666666666
+top,1,a,2,0,2,0.6666666666666666
+`, stdout.String())
+}
+
+func TestRun_Compressed(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "words.txt.gz")
+	f, err := os.Create(path)
+	require.NoError(t, err)
+
+	w := gzip.NewWriter(f)
+	_, err = w.Write([]byte("a b a\nc a\n"))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	require.NoError(t, f.Close())
+
+	var stdout bytes.Buffer
+	require.NoError(t, run([]string{"-k", "2", "-format", "csv", path, "../../ingest/testdata/words.txt.bz2"}, strings.NewReader(""), &stdout))
+
+	require.Equal(t, `result,rank,key,count,error,lower_bound,share
+frequent,1,a,6,0,6,0.6
+frequent,2,b,2,0,2,0.2
+frequent,3,c,2,0,2,0.2
+top,1,a,6,0,6,0.6
+top,2,b,2,0,2,0.2
+`, stdout.String())
+}
+
+func TestRun_Parallel(t *testing.T) {
+	dir := t.TempDir()
+	var paths []string
+
+	for i := range 8 {
+		path := filepath.Join(dir, fmt.Sprintf("%d.txt", i))
+		require.NoError(t, os.WriteFile(path, []byte(strings.Repeat(fmt.Sprintf("%d ", i%3), i+1)+"x\n"), 0o644))
+		paths = append(paths, path)
+	}
+
+	var sequential, parallel bytes.Buffer
+	require.NoError(t, run(append([]string{"-parallel", "1", "-k", "3"}, paths...), strings.NewReader(""), &sequential))
+	require.NoError(t, run(append([]string{"-parallel", "4", "-k", "3"}, paths...), strings.NewReader(""), &parallel))
+
+	require.Equal(t, sequential.String(), parallel.String())
+	require.Contains(t, parallel.String(), "Bytes: 88, tokens: 44\n")
+}
+
+// endlessReader repeats a token forever.
+type endlessReader struct{}
+
+func (endlessReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = "a "[i%2]
+	}
+
+	return len(p), nil
+}
+
+func TestRun_ParallelErrors(t *testing.T) {
+	var stdout bytes.Buffer
+
+	// the first error stops the worker reading the endless stdin.
+	err := run([]string{"-parallel", "2", "-", "missing.txt"}, endlessReader{}, &stdout)
+	require.ErrorContains(t, err, "missing.txt")
+
+	err = run([]string{"-parallel", "2", "-", "-"}, strings.NewReader("a\n"), &stdout)
+	require.ErrorContains(t, err, "stdin")
+}
+
+func TestRun_Invalid(t *testing.T) {
+	var stdout bytes.Buffer
+	require.Error(t, run([]string{"-tokenizer", "unknown"}, strings.NewReader(""), &stdout))
+	require.Error(t, run([]string{"-capacity", "0"}, strings.NewReader(""), &stdout))
+	require.Error(t, run([]string{"-format", "xml"}, strings.NewReader(""), &stdout))
+	require.Error(t, run([]string{"-records", "xml"}, strings.NewReader(""), &stdout))
+	require.Error(t, run([]string{"-records", "csv", "-key", "name"}, strings.NewReader(""), &stdout))
+	require.Error(t, run([]string{"missing.txt"}, strings.NewReader(""), &stdout))
+	require.Error(t, run([]string{"-parallel", "0"}, strings.NewReader(""), &stdout))
+	require.Error(t, run([]string{"-parallel", "2", "-"}, strings.NewReader("\x1f\x8bnot gzip"), &stdout))
+}
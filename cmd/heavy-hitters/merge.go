@@ -0,0 +1,287 @@
+package main
+
+import (
+	"cmp"
+	"errors"
+	"flag"
+	"fmt"
+	hh "heavy-hitters"
+	"heavy-hitters/report"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+)
+
+// algorithms names the algorithms of summaries in the binary format.
+var algorithms = map[uint8]string{
+	hh.AlgorithmSpaceSaving: "space-saving",
+	hh.AlgorithmExact:       "exact",
+}
+
+// savedSummaries decodes saved summaries with keys of a particular type.
+type savedSummaries interface {
+	// merge merges the summaries into a summary of the given capacity, returning its top-k and its binary encoding.
+	merge(summaries [][]byte, capacity int, k int) (report.Result, []byte, error)
+	// counters decodes every counter of a summary.
+	counters(header hh.Header, data []byte) (report.Result, error)
+}
+
+type savedSummariesOf[T cmp.Ordered] struct{}
+
+// keyKinds maps the key kinds of the binary format to the key types they are decoded as.
+var keyKinds = map[reflect.Kind]savedSummaries{
+	reflect.String:  savedSummariesOf[string]{},
+	reflect.Int:     savedSummariesOf[int]{},
+	reflect.Int8:    savedSummariesOf[int8]{},
+	reflect.Int16:   savedSummariesOf[int16]{},
+	reflect.Int32:   savedSummariesOf[int32]{},
+	reflect.Int64:   savedSummariesOf[int64]{},
+	reflect.Uint:    savedSummariesOf[uint]{},
+	reflect.Uint8:   savedSummariesOf[uint8]{},
+	reflect.Uint16:  savedSummariesOf[uint16]{},
+	reflect.Uint32:  savedSummariesOf[uint32]{},
+	reflect.Uint64:  savedSummariesOf[uint64]{},
+	reflect.Uintptr: savedSummariesOf[uintptr]{},
+	reflect.Float32: savedSummariesOf[float32]{},
+	reflect.Float64: savedSummariesOf[float64]{},
+}
+
+func (savedSummariesOf[T]) merge(summaries [][]byte, capacity int, k int) (report.Result, []byte, error) {
+	merged := hh.NewStreamSummary[T](capacity, hh.WithTieBreak[T](hh.TieBreakKey))
+
+	for _, data := range summaries {
+		if err := merged.MergeBinary(data); err != nil {
+			return report.Result{}, nil, err
+		}
+	}
+
+	data, err := merged.MarshalBinary()
+
+	return report.Top[T](merged, k), data, err
+}
+
+func (savedSummariesOf[T]) counters(header hh.Header, data []byte) (report.Result, error) {
+	var summary hh.HeavyHitters[T]
+
+	switch header.Algorithm {
+	case hh.AlgorithmSpaceSaving:
+		s := hh.NewStreamSummary[T](1, hh.WithTieBreak[T](hh.TieBreakKey))
+		if err := s.UnmarshalBinary(data); err != nil {
+			return report.Result{}, err
+		}
+
+		summary = s
+	default:
+		n := hh.NewNaive[T](hh.WithTieBreak[T](hh.TieBreakKey))
+		if err := n.UnmarshalBinary(data); err != nil {
+			return report.Result{}, err
+		}
+
+		summary = n
+	}
+
+	return report.Top[T](summary, header.Counters), nil
+}
+
+// runMerge executes the merge command with the given arguments, excluding the command name.
+// It merges SpaceSaving summaries saved in the binary format, writes their combined top-k and optionally saves the merged summary.
+func runMerge(args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("heavy-hitters merge", flag.ContinueOnError)
+	flags.SetOutput(stdout)
+
+	output := flags.String("o", "", "file to save the merged summary to")
+	k := flags.Int("k", 10, "number of top elements to report")
+	capacity := flags.Int("capacity", 0, "number of counters of the merged summary, at most the smallest capacity of the full summaries, which is the default")
+	format := flags.String("format", "table", "output format: "+strings.Join(report.Formats, ", "))
+
+	paths, err := parseInterspersed(flags, args)
+	if err != nil {
+		return err
+	}
+
+	formatter, err := report.ByName(*format)
+	if err != nil {
+		return err
+	}
+
+	if len(paths) == 0 {
+		return errors.New("no summaries to merge")
+	}
+
+	explicit := isFlagSet(flags, "capacity")
+	if explicit && *capacity < 1 {
+		return fmt.Errorf("invalid capacity %d", *capacity)
+	}
+
+	summaries := make([][]byte, len(paths))
+	headers := make([]hh.Header, len(paths))
+	var first hh.Header
+	var hits int
+
+	for i, path := range paths {
+		data, header, err := readSummary(path)
+		if err != nil {
+			return err
+		}
+
+		if i == 0 {
+			first = header
+		}
+
+		// the summaries are checked up front, so the error names the file that does not match the first one.
+		switch {
+		case header.Algorithm != hh.AlgorithmSpaceSaving:
+			return fmt.Errorf("%s: %w: only %s summaries can be merged, not %s", path, hh.ErrIncompatible, algorithms[hh.AlgorithmSpaceSaving], algorithmName(header.Algorithm))
+		case header.KeyKind != first.KeyKind:
+			return fmt.Errorf("%s: %w: key kind %s, expected %s as in %s", path, hh.ErrIncompatible, header.KeyKind, first.KeyKind, paths[0])
+		}
+
+		summaries[i], headers[i] = data, header
+		hits += header.Hits
+	}
+
+	if *capacity, err = mergedCapacity(paths, headers, *capacity, explicit); err != nil {
+		return err
+	}
+
+	result, merged, err := keyKinds[first.KeyKind].merge(summaries, *capacity, *k)
+	if err != nil {
+		return err
+	}
+
+	if *output != "" {
+		if err := os.WriteFile(*output, merged, 0o644); err != nil {
+			return err
+		}
+	}
+
+	description := fmt.Sprintf("Merged %d summaries, hits: %d, capacity: %d", len(paths), hits, *capacity)
+
+	return writeResults(stdout, *format, formatter, description, result)
+}
+
+// mergedCapacity chooses the capacity of the merged summary, which must not exceed the capacity of any full summary.
+// A full summary may not have counted an element up to its minimum count, and a merged summary with unused counters would report such an element as exact.
+// Without a capacity, the merged summary has the smallest capacity of the full summaries, or the largest capacity if all of them are exact.
+func mergedCapacity(paths []string, headers []hh.Header, capacity int, explicit bool) (int, error) {
+	limit, limitPath := 0, ""
+
+	for i, header := range headers {
+		if header.Counters == header.Capacity && (limit == 0 || header.Capacity < limit) {
+			limit, limitPath = header.Capacity, paths[i]
+		}
+	}
+
+	if explicit {
+		if limit > 0 && capacity > limit {
+			return 0, fmt.Errorf("capacity %d exceeds the capacity %d of the full summary %s, which would understate the errors", capacity, limit, limitPath)
+		}
+
+		return capacity, nil
+	}
+
+	if limit > 0 {
+		return limit, nil
+	}
+
+	for _, header := range headers {
+		capacity = max(capacity, header.Capacity)
+	}
+
+	return capacity, nil
+}
+
+// runInspect executes the inspect command with the given arguments, excluding the command name.
+// It writes the header and every counter of a summary saved in the binary format.
+func runInspect(args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("heavy-hitters inspect", flag.ContinueOnError)
+	flags.SetOutput(stdout)
+
+	format := flags.String("format", "table", "output format: "+strings.Join(report.Formats, ", "))
+
+	paths, err := parseInterspersed(flags, args)
+	if err != nil {
+		return err
+	}
+
+	formatter, err := report.ByName(*format)
+	if err != nil {
+		return err
+	}
+
+	if len(paths) != 1 {
+		return errors.New("inspect requires a single summary")
+	}
+
+	data, header, err := readSummary(paths[0])
+	if err != nil {
+		return err
+	}
+
+	if _, ok := algorithms[header.Algorithm]; !ok {
+		return fmt.Errorf("%s: %w: algorithm %s", paths[0], hh.ErrIncompatible, algorithmName(header.Algorithm))
+	}
+
+	result, err := keyKinds[header.KeyKind].counters(header, data)
+	if err != nil {
+		return fmt.Errorf("%s: %w", paths[0], err)
+	}
+
+	result.Name = paths[0]
+	description := fmt.Sprintf("Format version: %d, algorithm: %s, key kind: %s, capacity: %d, hits: %d, counters: %d",
+		header.Version, algorithmName(header.Algorithm), header.KeyKind, header.Capacity, header.Hits, header.Counters)
+
+	return writeResults(stdout, *format, formatter, description, result)
+}
+
+// readSummary reads a summary saved in the binary format along with its header, checking that its key type is supported.
+func readSummary(path string) ([]byte, hh.Header, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, hh.Header{}, err
+	}
+
+	header, err := hh.DecodeHeader(data)
+	if err != nil {
+		return nil, hh.Header{}, fmt.Errorf("%s: %w", path, err)
+	}
+
+	if header.Version != hh.FormatVersion {
+		return nil, hh.Header{}, fmt.Errorf("%s: %w: format version %d, expected %d", path, hh.ErrIncompatible, header.Version, hh.FormatVersion)
+	}
+
+	if keyKinds[header.KeyKind] == nil {
+		return nil, hh.Header{}, fmt.Errorf("%s: %w: unsupported key kind %s", path, hh.ErrIncompatible, header.KeyKind)
+	}
+
+	return data, header, nil
+}
+
+// algorithmName names the algorithm of a summary in the binary format.
+func algorithmName(algorithm uint8) string {
+	if name, ok := algorithms[algorithm]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("unknown (%d)", algorithm)
+}
+
+// parseInterspersed parses the flags wherever they appear among the arguments, such as in "merge a.bin b.bin -o out.bin",
+// returning the arguments that are not flags.
+func parseInterspersed(flags *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+
+	for {
+		if err := flags.Parse(args); err != nil {
+			return nil, err
+		}
+
+		if flags.NArg() == 0 {
+			return positional, nil
+		}
+
+		positional = append(positional, flags.Arg(0))
+		args = flags.Args()[1:]
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/require"
+	hh "heavy-hitters"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// saveSummary writes the binary encoding of a summary to a file in dir.
+func saveSummary(t *testing.T, dir string, name string, summary interface{ MarshalBinary() ([]byte, error) }) string {
+	data, err := summary.MarshalBinary()
+	require.NoError(t, err)
+
+	path := filepath.Join(dir, name)
+	require.NoError(t, os.WriteFile(path, data, 0o644))
+
+	return path
+}
+
+func TestRunMerge(t *testing.T) {
+	dir := t.TempDir()
+
+	a := hh.NewStreamSummary[string](3)
+	a.HitN("x", 5)
+	a.HitN("y", 3)
+	a.HitN("z", 1)
+
+	b := hh.NewStreamSummary[string](4)
+	b.HitN("y", 4)
+	b.HitN("w", 2)
+
+	output := filepath.Join(dir, "out.bin")
+	paths := []string{saveSummary(t, dir, "a.bin", a), saveSummary(t, dir, "b.bin", b)}
+
+	var stdout bytes.Buffer
+	require.NoError(t, run([]string{"merge", paths[0], paths[1], "-o", output, "-k", "3"}, strings.NewReader(""), &stdout))
+
+	// a is full, so w may have been counted up to the minimum count of a.
+	require.Equal(t, `Merged 2 summaries, hits: 15, capacity: 3
+
+top (hits: 15, guaranteed: false, ordered: true)
+RANK  KEY  COUNT  ERROR  LOWER BOUND  SHARE
+1     y    7      0      7            46.67%
+2     x    5      0      5            33.33%
+3     w    3      1      2            20.00%
+`, stdout.String())
+
+	data, err := os.ReadFile(output)
+	require.NoError(t, err)
+
+	merged := hh.NewStreamSummary[string](1)
+	require.NoError(t, merged.UnmarshalBinary(data))
+	require.Equal(t, 15, merged.Hits())
+
+	count, found := merged.Get("w")
+	require.True(t, found)
+	require.Equal(t, hh.Count{Count: 3, Error: 1}, count)
+
+	_, found = merged.Get("z")
+	require.False(t, found)
+
+	stdout.Reset()
+	require.NoError(t, run([]string{"merge", "-capacity", "2", "-format", "csv", paths[0], paths[1]}, strings.NewReader(""), &stdout))
+	require.Contains(t, stdout.String(), "top,2,w,5,3,2,")
+
+	// the capacity of the exact b does not bound the merged summary.
+	stdout.Reset()
+	require.NoError(t, run([]string{"merge", "-capacity", "8", paths[1], paths[1]}, strings.NewReader(""), &stdout))
+	require.Contains(t, stdout.String(), "capacity: 8")
+
+	require.ErrorContains(t, run([]string{"merge", "-capacity", "4", paths[0], paths[1]}, strings.NewReader(""), &stdout), "understate")
+}
+
+func TestRunMerge_Incompatible(t *testing.T) {
+	dir := t.TempDir()
+	strings1 := saveSummary(t, dir, "strings.bin", hh.NewStreamSummary[string](2))
+	ints := saveSummary(t, dir, "ints.bin", hh.NewStreamSummary[int](2))
+	exact := saveSummary(t, dir, "exact.bin", hh.NewNaive[string]())
+
+	corrupt := filepath.Join(dir, "corrupt.bin")
+	require.NoError(t, os.WriteFile(corrupt, []byte("not a summary"), 0o644))
+
+	var stdout bytes.Buffer
+
+	err := run([]string{"merge", strings1, ints}, strings.NewReader(""), &stdout)
+	require.ErrorIs(t, err, hh.ErrIncompatible)
+	require.ErrorContains(t, err, "ints.bin")
+
+	require.ErrorIs(t, run([]string{"merge", strings1, exact}, strings.NewReader(""), &stdout), hh.ErrIncompatible)
+	require.ErrorIs(t, run([]string{"merge", strings1, corrupt}, strings.NewReader(""), &stdout), hh.ErrCorrupt)
+	require.Error(t, run([]string{"merge"}, strings.NewReader(""), &stdout))
+	require.Error(t, run([]string{"merge", "-capacity", "0", strings1}, strings.NewReader(""), &stdout))
+	require.Error(t, run([]string{"merge", "missing.bin"}, strings.NewReader(""), &stdout))
+}
+
+func TestRunInspect(t *testing.T) {
+	dir := t.TempDir()
+
+	summary := hh.NewStreamSummary[int](2)
+	summary.HitN(7, 3)
+	summary.Hit(8)
+	summary.Hit(9)
+
+	var stdout bytes.Buffer
+	require.NoError(t, run([]string{"inspect", saveSummary(t, dir, "ints.bin", summary), "-format", "csv"}, strings.NewReader(""), &stdout))
+
+	require.Equal(t, `result,rank,key,count,error,lower_bound,share
+`+filepath.Join(dir, "ints.bin")+`,1,7,3,0,3,0.6
+`+filepath.Join(dir, "ints.bin")+`,2,9,2,1,1,0.4
+`, stdout.String())
+
+	exact := hh.NewNaive[string]()
+	exact.HitN("a", 2)
+
+	stdout.Reset()
+	require.NoError(t, run([]string{"inspect", saveSummary(t, dir, "exact.bin", exact)}, strings.NewReader(""), &stdout))
+	require.True(t, strings.HasPrefix(stdout.String(), "Format version: 1, algorithm: exact, key kind: string, capacity: 0, hits: 2, counters: 1\n"))
+
+	require.Error(t, run([]string{"inspect"}, strings.NewReader(""), &stdout))
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	hh "heavy-hitters"
+	"heavy-hitters/pcap"
+	"heavy-hitters/report"
+	"io"
+	"strings"
+)
+
+// runPcap executes the pcap command with the given arguments, excluding the command name.
+func runPcap(args []string, stdin io.Reader, stdout io.Writer) error {
+	flags := flag.NewFlagSet("heavy-hitters pcap", flag.ContinueOnError)
+	flags.SetOutput(stdout)
+
+	k := flags.Int("k", 10, "number of top flows and addresses to report")
+	capacity := flags.Int("capacity", 1000, "number of counters for flows and for each kind of address")
+	weightName := flags.String("weight", "packets", "what each packet counts for: packets or bytes")
+	format := flags.String("format", "table", "output format: "+strings.Join(report.Formats, ", "))
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	formatter, err := report.ByName(*format)
+	if err != nil {
+		return err
+	}
+
+	weight, err := pcap.ParseWeight(*weightName)
+	if err != nil {
+		return err
+	}
+
+	analyzer, err := pcap.NewAnalyzer(weight, hh.WithCapacity[string](*capacity), hh.WithTieBreak[string](hh.TieBreakKey))
+	if err != nil {
+		return err
+	}
+
+	paths := flags.Args()
+	if len(paths) == 0 {
+		paths = []string{"-"}
+	}
+
+	var stats pcap.Stats
+
+	for _, path := range paths {
+		s, err := analyzeCapture(path, stdin, analyzer)
+		stats.Add(s)
+
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	}
+
+	results := make([]report.Result, 0, 3)
+
+	for _, summary := range []struct {
+		name    string
+		summary *hh.StreamSummary[string]
+	}{
+		{"flows", analyzer.Flows()},
+		{"sources", analyzer.Sources()},
+		{"destinations", analyzer.Destinations()},
+	} {
+		result := report.Top[string](summary.summary, *k)
+		result.Name = summary.name
+		results = append(results, result)
+	}
+
+	description := fmt.Sprintf("Packets: %d, bytes: %d, skipped: %d, weighted by %s", stats.Packets, stats.Bytes, stats.Skipped, weight)
+
+	return writeResults(stdout, *format, formatter, description, results...)
+}
+
+func analyzeCapture(path string, stdin io.Reader, analyzer *pcap.Analyzer) (pcap.Stats, error) {
+	r, closeInput, err := openInput(path, stdin)
+	if err != nil {
+		return pcap.Stats{}, err
+	}
+
+	defer closeInput()
+
+	reader, err := pcap.NewReader(r)
+	if err != nil {
+		return pcap.Stats{}, err
+	}
+
+	return analyzer.Analyze(reader)
+}
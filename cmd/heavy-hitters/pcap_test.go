@@ -0,0 +1,39 @@
+package main
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func TestRunPcap(t *testing.T) {
+	var stdout bytes.Buffer
+	require.NoError(t, run([]string{"pcap", "-k", "2", "-weight", "bytes", "../../pcap/testdata/flows.pcap"}, strings.NewReader(""), &stdout))
+
+	require.Equal(t, `Packets: 13, bytes: 7724, skipped: 1, weighted by bytes
+
+flows (hits: 7724, guaranteed: true, ordered: true)
+RANK  KEY                                COUNT  ERROR  LOWER BOUND  SHARE
+1     tcp 10.0.0.1:51234 > 10.0.0.2:443  4000   0      4000         51.79%
+2     tcp 10.0.0.2:443 > 10.0.0.1:51234  3028   0      3028         39.20%
+
+sources (hits: 7724, guaranteed: true, ordered: true)
+RANK  KEY       COUNT  ERROR  LOWER BOUND  SHARE
+1     10.0.0.1  4098   0      4098         53.06%
+2     10.0.0.2  3028   0      3028         39.20%
+
+destinations (hits: 7724, guaranteed: true, ordered: true)
+RANK  KEY       COUNT  ERROR  LOWER BOUND  SHARE
+1     10.0.0.2  4098   0      4098         53.06%
+2     10.0.0.1  3028   0      3028         39.20%
+`, stdout.String())
+}
+
+func TestRunPcap_Invalid(t *testing.T) {
+	var stdout bytes.Buffer
+	require.Error(t, run([]string{"pcap", "-format", "xml"}, strings.NewReader(""), &stdout))
+	require.Error(t, run([]string{"pcap", "-weight", "flows"}, strings.NewReader(""), &stdout))
+	require.Error(t, run([]string{"pcap"}, strings.NewReader("not a capture"), &stdout))
+	require.Error(t, run([]string{"pcap", "missing.pcap"}, strings.NewReader(""), &stdout))
+}
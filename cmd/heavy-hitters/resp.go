@@ -0,0 +1,65 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"heavy-hitters/resp"
+	"io"
+	"net"
+)
+
+// runResp executes the resp command with the given arguments, excluding the command name.
+// It serves the TOPK commands of RedisBloom over RESP2 until SIGINT or SIGTERM.
+func runResp(args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("heavy-hitters resp", flag.ContinueOnError)
+	flags.SetOutput(stdout)
+
+	addr := flags.String("addr", "127.0.0.1:6379", "address to listen on")
+	maxCounters := flags.Int("max-counters", 1<<22, "maximum number of counters of all keys together")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if flags.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments %q", flags.Args())
+	}
+
+	if *maxCounters <= 0 {
+		return fmt.Errorf("invalid maximum number of counters %d", *maxCounters)
+	}
+
+	listener, err := net.Listen("tcp", *addr)
+	if err != nil {
+		return err
+	}
+
+	ctx, _, stop := followSignals()
+	defer stop()
+
+	server := resp.New(resp.WithMaxCounters(*maxCounters))
+	done := make(chan error, 1)
+
+	go func() {
+		done <- server.Serve(listener)
+	}()
+
+	fmt.Fprintf(stdout, "Listening on %s\n", listener.Addr())
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+	}
+
+	if err := server.Close(); err != nil {
+		return err
+	}
+
+	if err := <-done; !errors.Is(err, resp.ErrServerClosed) {
+		return err
+	}
+
+	return nil
+}
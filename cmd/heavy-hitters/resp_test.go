@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunResp(t *testing.T) {
+	cancel, _ := fakeSignals(t)
+
+	var stdout lockedBuffer
+	done := make(chan error)
+
+	go func() {
+		done <- run([]string{"resp", "-addr", "127.0.0.1:0"}, strings.NewReader(""), &stdout)
+	}()
+
+	listening := regexp.MustCompile(`Listening on (\S+)\n`)
+	require.Eventually(t, func() bool { return listening.MatchString(stdout.String()) }, 5*time.Second, time.Millisecond)
+
+	conn, err := net.Dial("tcp", listening.FindStringSubmatch(stdout.String())[1])
+	require.NoError(t, err)
+	defer conn.Close()
+
+	_, err = io.WriteString(conn, "TOPK.RESERVE paths 1\r\nTOPK.ADD paths /a\r\nTOPK.LIST paths WITHCOUNT\r\n")
+	require.NoError(t, err)
+
+	reader := bufio.NewReader(conn)
+	var replies []string
+
+	for range 7 {
+		line, err := reader.ReadString('\n')
+		require.NoError(t, err)
+
+		replies = append(replies, line)
+	}
+
+	require.Equal(t, []string{"+OK\r\n", "*1\r\n", "$-1\r\n", "*2\r\n", "$2\r\n", "/a\r\n", ":1\r\n"}, replies)
+
+	cancel()
+	require.NoError(t, <-done)
+}
+
+func TestRunResp_Invalid(t *testing.T) {
+	var stdout bytes.Buffer
+	require.Error(t, run([]string{"resp", "extra"}, strings.NewReader(""), &stdout))
+	require.Error(t, run([]string{"resp", "-addr", "invalid address"}, strings.NewReader(""), &stdout))
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"heavy-hitters/server"
+	"io"
+	"net"
+	"net/http"
+	"time"
+)
+
+// shutdownTimeout bounds the time given to requests in progress when the server is stopped.
+const shutdownTimeout = 5 * time.Second
+
+// runServe executes the serve command with the given arguments, excluding the command name.
+// It serves named summaries over HTTP until SIGINT or SIGTERM.
+func runServe(args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("heavy-hitters serve", flag.ContinueOnError)
+	flags.SetOutput(stdout)
+
+	addr := flags.String("addr", "127.0.0.1:8080", "address to listen on")
+	capacity := flags.Int("capacity", 1000, "number of counters of the default summary and of summaries created without a capacity")
+	maxCapacity := flags.Int("max-capacity", 1<<20, "maximum number of counters of summaries created by requests or restored from snapshots")
+	maxBodyBytes := flags.Int64("max-body-bytes", 32<<20, "maximum size of request bodies, such as batches of hits and snapshots")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if flags.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments %q", flags.Args())
+	}
+
+	handler, err := server.New(server.WithCapacity(*capacity), server.WithMaxCapacity(*maxCapacity), server.WithMaxBodyBytes(*maxBodyBytes))
+	if err != nil {
+		return err
+	}
+
+	listener, err := net.Listen("tcp", *addr)
+	if err != nil {
+		return err
+	}
+
+	ctx, _, stop := followSignals()
+	defer stop()
+
+	srv := &http.Server{Handler: handler, ReadHeaderTimeout: 10 * time.Second}
+	done := make(chan error, 1)
+
+	go func() {
+		done <- srv.Serve(listener)
+	}()
+
+	fmt.Fprintf(stdout, "Listening on %s\n", listener.Addr())
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdown, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	if err := srv.Shutdown(shutdown); err != nil {
+		return err
+	}
+
+	if err := <-done; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunServe(t *testing.T) {
+	cancel, _ := fakeSignals(t)
+
+	var stdout lockedBuffer
+	done := make(chan error)
+
+	go func() {
+		done <- run([]string{"serve", "-addr", "127.0.0.1:0", "-capacity", "10"}, strings.NewReader(""), &stdout)
+	}()
+
+	listening := regexp.MustCompile(`Listening on (\S+)\n`)
+	require.Eventually(t, func() bool { return listening.MatchString(stdout.String()) }, 5*time.Second, time.Millisecond)
+	url := "http://" + listening.FindStringSubmatch(stdout.String())[1]
+
+	// without keep-alives, no idle connection delays the shutdown of the server.
+	client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+
+	response, err := client.Post(url+"/hit", "application/json", strings.NewReader(`[{"key": "a", "weight": 2}, {"key": "b"}]`))
+	require.NoError(t, err)
+	require.NoError(t, response.Body.Close())
+	require.Equal(t, http.StatusOK, response.StatusCode)
+
+	response, err = client.Get(url + "/top?k=1&format=csv")
+	require.NoError(t, err)
+
+	body, err := io.ReadAll(response.Body)
+	require.NoError(t, err)
+	require.NoError(t, response.Body.Close())
+	require.Equal(t, "result,rank,key,count,error,lower_bound,share\ntop,1,a,2,0,2,0.6666666666666666\n", string(body))
+
+	cancel()
+	require.NoError(t, <-done)
+}
+
+func TestRunServe_Invalid(t *testing.T) {
+	var stdout bytes.Buffer
+	require.Error(t, run([]string{"serve", "-capacity", "0"}, strings.NewReader(""), &stdout))
+	require.Error(t, run([]string{"serve", "extra"}, strings.NewReader(""), &stdout))
+	require.Error(t, run([]string{"serve", "-addr", "invalid address"}, strings.NewReader(""), &stdout))
+}
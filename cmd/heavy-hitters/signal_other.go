@@ -0,0 +1,8 @@
+//go:build !unix
+
+package main
+
+import "os"
+
+// reportSignals is empty where SIGUSR1 does not exist, so reports are only written periodically.
+var reportSignals []os.Signal
@@ -0,0 +1,11 @@
+//go:build unix
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// reportSignals request a report of the current results while following a file.
+var reportSignals = []os.Signal{syscall.SIGUSR1}
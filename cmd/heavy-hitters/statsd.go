@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"heavy-hitters/report"
+	"heavy-hitters/statsd"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// runStatsd executes the statsd command with the given arguments, excluding the command name.
+// It summarizes the metric names, tags and sources of StatsD packets until SIGINT or SIGTERM,
+// writing the top-k every interval and on SIGUSR1, and serving them over HTTP if requested.
+func runStatsd(args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("heavy-hitters statsd", flag.ContinueOnError)
+	flags.SetOutput(stdout)
+
+	addr := flags.String("addr", "127.0.0.1:8125", "UDP address to receive StatsD packets on")
+	httpAddr := flags.String("http", "", "address to serve the top-k over HTTP on, or empty to not serve them")
+	k := flags.Int("k", 10, "number of top metric names, tags and sources to report")
+	capacity := flags.Int("capacity", 1000, "number of counters of each summary")
+	interval := flags.Duration("interval", time.Minute, "time between reports, or 0 to only report on SIGUSR1")
+	format := flags.String("format", "table", "output format: "+strings.Join(report.Formats, ", "))
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	formatter, err := report.ByName(*format)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case flags.NArg() > 0:
+		return fmt.Errorf("unexpected arguments %q", flags.Args())
+	case *interval < 0:
+		return fmt.Errorf("invalid interval %s", *interval)
+	}
+
+	listener, err := statsd.New(*capacity)
+	if err != nil {
+		return err
+	}
+
+	conn, err := net.ListenPacket("udp", *addr)
+	if err != nil {
+		return err
+	}
+
+	done := make(chan error, 2)
+
+	go func() {
+		done <- listener.Serve(conn)
+	}()
+
+	defer conn.Close()
+
+	fmt.Fprintf(stdout, "Listening on %s\n", conn.LocalAddr())
+
+	if *httpAddr != "" {
+		httpListener, err := net.Listen("tcp", *httpAddr)
+		if err != nil {
+			return err
+		}
+
+		srv := &http.Server{Handler: listener, ReadHeaderTimeout: 10 * time.Second}
+
+		go func() {
+			if err := srv.Serve(httpListener); !errors.Is(err, http.ErrServerClosed) {
+				done <- err
+			}
+		}()
+
+		defer func() {
+			shutdown, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+			defer cancel()
+
+			srv.Shutdown(shutdown)
+		}()
+
+		fmt.Fprintf(stdout, "Serving the top-%d on http://%s\n", *k, httpListener.Addr())
+	}
+
+	ctx, requests, stop := followSignals()
+	defer stop()
+
+	write := func() error {
+		stats := listener.Stats()
+		description := fmt.Sprintf("Packets: %d, metrics: %d, skipped: %d, malformed: %d", stats.Packets, stats.Metrics, stats.Skipped, stats.Malformed)
+
+		return writeResults(stdout, *format, formatter, description, listener.Top(*k)...)
+	}
+
+	var ticks <-chan time.Time
+
+	if *interval > 0 {
+		ticker := time.NewTicker(*interval)
+		defer ticker.Stop()
+
+		ticks = ticker.C
+	}
+
+	for {
+		select {
+		case <-ticks:
+		case <-requests:
+		case err := <-done:
+			return err
+		case <-ctx.Done():
+			return write()
+		}
+
+		if err := write(); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunStatsd(t *testing.T) {
+	cancel, requests := fakeSignals(t)
+
+	var stdout lockedBuffer
+	done := make(chan error)
+
+	go func() {
+		done <- run([]string{"statsd", "-addr", "127.0.0.1:0", "-http", "127.0.0.1:0", "-interval", "0", "-k", "1", "-format", "csv"}, strings.NewReader(""), &stdout)
+	}()
+
+	serving := regexp.MustCompile(`Listening on (\S+)\nServing the top-1 on (\S+)\n`)
+	require.Eventually(t, func() bool { return serving.MatchString(stdout.String()) }, 5*time.Second, time.Millisecond)
+	addrs := serving.FindStringSubmatch(stdout.String())
+
+	client, err := net.Dial("udp", addrs[1])
+	require.NoError(t, err)
+	defer client.Close()
+
+	_, err = client.Write([]byte("requests:1|c|#env:prod\nrequests:1|c|#env:dev\nmalformed\n"))
+	require.NoError(t, err)
+
+	// without keep-alives, no idle connection delays the shutdown of the server.
+	httpClient := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+
+	require.Eventually(t, func() bool {
+		response, err := httpClient.Get(addrs[2] + "?format=csv")
+		require.NoError(t, err)
+		defer response.Body.Close()
+
+		body, err := io.ReadAll(response.Body)
+		require.NoError(t, err)
+
+		return strings.Contains(string(body), "names,1,requests,2,0,2,1\n")
+	}, 5*time.Second, 10*time.Millisecond)
+
+	requests <- os.Interrupt
+	cancel()
+	require.NoError(t, <-done)
+
+	reports := strings.Split(stdout.String(), "result,rank,key,count,error,lower_bound,share\n")
+	require.Len(t, reports, 3)
+	require.Regexp(t, `^names,1,requests,2,0,2,1
+tags,1,env=dev,1,0,1,0.5
+sources,1,127\.0\.0\.1,2,0,2,1
+$`, reports[2])
+}
+
+func TestRunStatsd_Invalid(t *testing.T) {
+	var stdout bytes.Buffer
+	require.Error(t, run([]string{"statsd", "extra"}, strings.NewReader(""), &stdout))
+	require.Error(t, run([]string{"statsd", "-capacity", "0"}, strings.NewReader(""), &stdout))
+	require.Error(t, run([]string{"statsd", "-interval", "-1s"}, strings.NewReader(""), &stdout))
+	require.Error(t, run([]string{"statsd", "-format", "xml"}, strings.NewReader(""), &stdout))
+	require.Error(t, run([]string{"statsd", "-addr", "invalid address"}, strings.NewReader(""), &stdout))
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	hh "heavy-hitters"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+	"unicode/utf8"
+)
+
+// clearScreen moves the cursor to the top left corner of the terminal and clears it.
+const clearScreen = "\x1b[H\x1b[2J"
+
+// runTop executes the top command with the given arguments, excluding the command name.
+// The input is ingested in the background while the top elements are redrawn at every interval, and once more when the input ends.
+// A followed file ends on SIGINT or SIGTERM.
+func runTop(args []string, stdin io.Reader, stdout io.Writer) error {
+	flags := flag.NewFlagSet("heavy-hitters top", flag.ContinueOnError)
+	flags.SetOutput(stdout)
+
+	k := flags.Int("k", 10, "number of top elements to show")
+	capacity := flags.Int("capacity", 1000, "number of counters; the error is bounded by tokens / capacity")
+	interval := flags.Duration("interval", time.Second, "time between refreshes")
+	follow := flags.Bool("follow", false, "keep reading the file as it grows, like tail -F, until SIGINT or SIGTERM")
+	newSource := sourceFlags(flags)
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *interval <= 0 {
+		return fmt.Errorf("invalid interval %s", *interval)
+	}
+
+	if *follow && (flags.NArg() != 1 || flags.Arg(0) == "-") {
+		return errors.New("-follow requires a single file")
+	}
+
+	summary, err := hh.New(hh.WithCapacity[string](*capacity), hh.WithTieBreak[string](hh.TieBreakKey))
+	if err != nil {
+		return err
+	}
+
+	synchronized := hh.NewSynchronized[string](summary)
+
+	source, err := newSource(synchronized)
+	if err != nil {
+		return err
+	}
+
+	view := newTopView(*k, isTerminal(stdout), time.Now())
+	done := make(chan error, 1)
+	var requests <-chan os.Signal
+
+	if *follow {
+		ctx, signals, stop := followSignals()
+		defer stop()
+
+		requests = signals
+
+		go func() {
+			_, err := followFile(ctx, flags.Arg(0), source)
+			done <- err
+		}()
+	} else {
+		go func() {
+			_, err := ingestFiles(flags.Args(), stdin, source)
+			done <- err
+		}()
+	}
+
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			if err := view.draw(stdout, synchronized, now); err != nil {
+				return err
+			}
+		case <-requests:
+			if err := view.draw(stdout, synchronized, time.Now()); err != nil {
+				return err
+			}
+		case err := <-done:
+			if err != nil {
+				return err
+			}
+
+			return view.draw(stdout, synchronized, time.Now())
+		}
+	}
+}
+
+// isTerminal reports whether w is a terminal, which can be redrawn with ANSI escape codes.
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+
+	info, err := f.Stat()
+
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// topView draws the top elements of a summary, along with their rates and rank movements since the previous refresh.
+type topView struct {
+	k        int
+	terminal bool
+	// The time, hits, counts and ranks at the previous refresh, or at the start before the first refresh.
+	last   time.Time
+	hits   int
+	counts map[string]int
+	ranks  map[string]int
+	first  bool
+}
+
+// topRow is a row of the top view.
+type topRow struct {
+	rank int
+	// movement is "new" if the element was not shown at the previous refresh, or the number of ranks it moved up or down.
+	movement string
+	key      string
+	// rate is the increase of the count per second since the previous refresh, or -1 if the previous count is unknown.
+	rate  float64
+	count hh.Count
+}
+
+func newTopView(k int, terminal bool, start time.Time) *topView {
+	return &topView{k: k, terminal: terminal, last: start, first: true}
+}
+
+// frame queries the summary for the rows shown at the given time, and remembers them for the next refresh.
+// It returns the rate of hits per second along with the rows.
+func (v *topView) frame(summary hh.HeavyHitters[string], now time.Time) (float64, []topRow) {
+	elapsed := now.Sub(v.last).Seconds()
+	hits := summary.Hits()
+	// the counts of more elements than shown are kept, so elements entering the view have known rates.
+	tracked, _, _ := summary.Top(4 * v.k)
+	counts := make(map[string]int, len(tracked))
+	ranks := make(map[string]int, v.k)
+	rows := make([]topRow, 0, v.k)
+
+	for i, key := range tracked {
+		count, _ := summary.Get(key)
+		counts[key] = count.Count
+
+		if i >= v.k {
+			continue
+		}
+
+		ranks[key] = i + 1
+		row := topRow{rank: i + 1, movement: "new", key: key, rate: -1, count: count}
+
+		if previous, ok := v.ranks[key]; ok {
+			row.movement = movement(previous - row.rank)
+		}
+
+		// before the first refresh, every element had a count of zero.
+		previous, ok := v.counts[key]
+		if ok || v.first {
+			row.rate = rate(count.Count-previous, elapsed)
+		}
+
+		rows = append(rows, row)
+	}
+
+	hitRate := rate(hits-v.hits, elapsed)
+	v.last, v.hits, v.counts, v.ranks, v.first = now, hits, counts, ranks, false
+
+	return hitRate, rows
+}
+
+// draw writes the frame at the given time, replacing the previous frame on a terminal.
+// The summary is locked while the frame is queried, so all rows observe the same state.
+func (v *topView) draw(w io.Writer, summary *hh.Synchronized[string], now time.Time) error {
+	var hits int
+	var hitRate float64
+	var rows []topRow
+
+	summary.Do(func(summary hh.WeightedHeavyHitters[string]) {
+		hitRate, rows = v.frame(summary, now)
+		hits = summary.Hits()
+	})
+
+	if v.terminal {
+		fmt.Fprint(w, clearScreen)
+	}
+
+	fmt.Fprintf(w, "%s  hits: %d  rate: %.1f/s\n\n", now.Format(time.TimeOnly), hits, hitRate)
+
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "RANK\tMOVE\tKEY\tRATE/S\tCOUNT\tERROR")
+
+	for _, row := range rows {
+		r := "-"
+		if row.rate >= 0 {
+			r = strconv.FormatFloat(row.rate, 'f', 1, 64)
+		}
+
+		fmt.Fprintf(table, "%d\t%s\t%s\t%s\t%d\t%d\n", row.rank, row.movement, printable(row.key), r, row.count.Count, row.count.Error)
+	}
+
+	if err := table.Flush(); err != nil {
+		return err
+	}
+
+	// plain output is appended, so frames are separated by a blank line.
+	if !v.terminal {
+		_, err := fmt.Fprintln(w)
+		return err
+	}
+
+	return nil
+}
+
+// movement formats the number of ranks an element moved up, negative if it moved down.
+func movement(up int) string {
+	switch {
+	case up > 0:
+		return fmt.Sprintf("+%d", up)
+	case up < 0:
+		return strconv.Itoa(up)
+	default:
+		return "="
+	}
+}
+
+// rate is the increase per second, which is zero if no time elapsed.
+func rate(increase int, elapsed float64) float64 {
+	if elapsed <= 0 {
+		return 0
+	}
+
+	return float64(increase) / elapsed
+}
+
+// printable quotes a key that contains control characters, such as escape sequences or tabs from untrusted input,
+// or invalid UTF-8, so it can neither rewrite the terminal nor break the columns of the table.
+func printable(key string) string {
+	if !utf8.ValidString(key) || strings.IndexFunc(key, func(r rune) bool { return !strconv.IsPrint(r) }) >= 0 {
+		return strconv.Quote(key)
+	}
+
+	return key
+}
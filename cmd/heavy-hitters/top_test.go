@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/require"
+	hh "heavy-hitters"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTopView_Frame(t *testing.T) {
+	summary, err := hh.New(hh.WithCapacity[string](10), hh.WithTieBreak[string](hh.TieBreakKey))
+	require.NoError(t, err)
+
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	view := newTopView(2, false, start)
+
+	summary.HitN("a", 4)
+	summary.HitN("b", 2)
+	summary.HitN("c", 1)
+
+	hitRate, rows := view.frame(summary, start.Add(2*time.Second))
+	require.Equal(t, 3.5, hitRate)
+	require.Equal(t, []topRow{
+		{rank: 1, movement: "new", key: "a", rate: 2, count: hh.Count{Count: 4}},
+		{rank: 2, movement: "new", key: "b", rate: 1, count: hh.Count{Count: 2}},
+	}, rows)
+
+	summary.HitN("c", 5)
+	summary.HitN("d", 3)
+
+	hitRate, rows = view.frame(summary, start.Add(3*time.Second))
+	require.Equal(t, 8.0, hitRate)
+	require.Equal(t, []topRow{
+		{rank: 1, movement: "new", key: "c", rate: 5, count: hh.Count{Count: 6}},
+		{rank: 2, movement: "-1", key: "a", rate: 0, count: hh.Count{Count: 4}},
+	}, rows)
+
+	summary.HitN("a", 3)
+
+	_, rows = view.frame(summary, start.Add(4*time.Second))
+	require.Equal(t, []topRow{
+		{rank: 1, movement: "+1", key: "a", rate: 3, count: hh.Count{Count: 7}},
+		{rank: 2, movement: "-1", key: "c", rate: 0, count: hh.Count{Count: 6}},
+	}, rows)
+}
+
+func TestTopView_Draw(t *testing.T) {
+	summary, err := hh.New(hh.WithCapacity[string](10))
+	require.NoError(t, err)
+
+	synchronized := hh.NewSynchronized[string](summary)
+	synchronized.HitN("a", 3)
+
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	var plain bytes.Buffer
+	require.NoError(t, newTopView(5, false, start).draw(&plain, synchronized, start.Add(time.Second)))
+	require.Equal(t, `12:00:01  hits: 3  rate: 3.0/s
+
+RANK  MOVE  KEY  RATE/S  COUNT  ERROR
+1     new   a    3.0     3      0
+
+`, plain.String())
+
+	var terminal bytes.Buffer
+	require.NoError(t, newTopView(5, true, start).draw(&terminal, synchronized, start.Add(time.Second)))
+	require.True(t, strings.HasPrefix(terminal.String(), clearScreen+"12:00:01"))
+
+	// a key carrying escape sequences is quoted rather than interpreted by the terminal.
+	synchronized.HitN("\x1b[2Jowned\tb", 5)
+	terminal.Reset()
+	require.NoError(t, newTopView(5, true, start).draw(&terminal, synchronized, start.Add(time.Second)))
+	require.Equal(t, strings.Count(clearScreen, "\x1b"), strings.Count(terminal.String(), "\x1b"))
+	require.Contains(t, terminal.String(), `"\x1b[2Jowned\tb"`)
+}
+
+func TestPrintable(t *testing.T) {
+	require.Equal(t, "/index.html", printable("/index.html"))
+	require.Equal(t, "café 日本", printable("café 日本"))
+	require.Equal(t, `"a\x1b[31mb"`, printable("a\x1b[31mb"))
+	require.Equal(t, `"a\tb"`, printable("a\tb"))
+	require.Equal(t, `"\xff"`, printable("\xff"))
+}
+
+func TestRunTop(t *testing.T) {
+	var stdout bytes.Buffer
+	require.NoError(t, run([]string{"top", "-k", "2", "-tokenizer", "lines"}, strings.NewReader("x\ny\ny\nz\nz\nz\n"), &stdout))
+
+	output := stdout.String()
+	require.NotContains(t, output, clearScreen)
+	require.Contains(t, output, "hits: 6")
+	require.Regexp(t, `1\s+new\s+z\s+\S+\s+3\s+0\n2\s+new\s+y\s+\S+\s+2\s+0\n`, output)
+}
+
+func TestRunTop_Invalid(t *testing.T) {
+	var stdout bytes.Buffer
+	require.Error(t, run([]string{"top", "-interval", "0s"}, strings.NewReader(""), &stdout))
+	require.Error(t, run([]string{"top", "-tokenizer", "sentences"}, strings.NewReader(""), &stdout))
+	require.Error(t, run([]string{"top", "missing.txt"}, strings.NewReader(""), &stdout))
+}
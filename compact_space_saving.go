@@ -0,0 +1,340 @@
+package heavy_hitters
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+)
+
+// nilIndex marks the absence of a counter or bucket in a CompactStreamSummary.
+const nilIndex int32 = -1
+
+// CompactStreamSummary is an implementation of the [SpaceSaving] algorithm whose counters and buckets are linked without pointers.
+// Counters and buckets are stored in flat slices that are linked by int32 indices instead of pointers.
+// All counters and buckets are allocated up front and buckets are recycled through a free list, so Hit does not allocate in the steady state.
+// The buckets hold no pointers, and neither do the counters and the map of monitored elements for keys without pointers, such as integers,
+// so the garbage collector has nothing to trace in them. String keys are still pointers that the garbage collector traces.
+//
+// [SpaceSaving]: https://www.cs.ucsb.edu/sites/default/files/documents/2005-23.pdf
+type CompactStreamSummary[T cmp.Ordered] struct {
+	hits     int
+	elements map[T]int32
+	counters []compactCounter[T]
+	// At most one bucket per counter is in use, plus one extra for the bucket created while a counter moves.
+	buckets []compactBucket
+	// The head of the list of free buckets, linked through their next index.
+	free int32
+	// The head of the list of buckets is the maximum frequency and the tail is the minimum.
+	head int32
+	tail int32
+	// The number of monitored elements that were replaced by other elements.
+	evictions int
+}
+
+// compactBucket maintains a list of counters with the same frequency.
+type compactBucket struct {
+	count int
+	// The neighboring buckets, from the point of view of traversing the list from head to tail.
+	previous int32
+	next     int32
+	// The head of the list of counters is the least recently inserted and the tail is the most recently inserted.
+	head int32
+	tail int32
+}
+
+// compactCounter counts the frequency of an element in a stream along with its error bounds.
+type compactCounter[T cmp.Ordered] struct {
+	key    T
+	count  int
+	error  int
+	bucket int32
+	// The neighboring counters in the same bucket, from the point of view of traversing the list from head to tail.
+	previous int32
+	next     int32
+}
+
+// Hit increments the frequency for the given element, then returns an approximation of the current frequency.
+func (s *CompactStreamSummary[T]) Hit(e T) Count {
+	s.hits++
+
+	i, monitored := s.elements[e]
+
+	if !monitored {
+		// replace the most recently inserted counter with the least hits.
+		i = s.buckets[s.tail].tail
+		c := &s.counters[i]
+
+		// avoid deleting the element from the elements if e is the zero value.
+		if c.count > 0 {
+			delete(s.elements, c.key)
+			s.evictions++
+		}
+
+		c.key = e
+		c.error = c.count
+		s.elements[e] = i
+	}
+
+	s.incrementCounter(i)
+
+	c := &s.counters[i]
+
+	return Count{Count: c.count, Error: c.error}
+}
+
+func (s *CompactStreamSummary[T]) incrementCounter(i int32) {
+	c := &s.counters[i]
+	oldBucket := c.bucket
+	newBucket := s.buckets[oldBucket].previous
+
+	c.count++
+	s.detachCounter(i)
+
+	if newBucket == nilIndex || s.buckets[newBucket].count != c.count {
+		// The old bucket was the head or its previous bucket has a larger frequency.
+		// Take a bucket from the free list and link it between the old bucket and its previous bucket.
+		newBucket = s.insertBucketBefore(oldBucket, c.count)
+	}
+
+	s.pushCounter(newBucket, i)
+
+	// If the old bucket is empty, return it to the free list.
+	if s.buckets[oldBucket].head == nilIndex {
+		s.removeBucket(oldBucket)
+	}
+}
+
+// detachCounter unlinks the counter from its bucket.
+func (s *CompactStreamSummary[T]) detachCounter(i int32) {
+	c := &s.counters[i]
+	b := &s.buckets[c.bucket]
+
+	if c.previous == nilIndex {
+		b.head = c.next
+	} else {
+		s.counters[c.previous].next = c.next
+	}
+
+	if c.next == nilIndex {
+		b.tail = c.previous
+	} else {
+		s.counters[c.next].previous = c.previous
+	}
+
+	c.previous = nilIndex
+	c.next = nilIndex
+	c.bucket = nilIndex
+}
+
+// pushCounter appends the counter as the new tail of the bucket.
+func (s *CompactStreamSummary[T]) pushCounter(bucket int32, i int32) {
+	c := &s.counters[i]
+	b := &s.buckets[bucket]
+
+	c.bucket = bucket
+	c.previous = b.tail
+	c.next = nilIndex
+
+	if b.tail == nilIndex {
+		b.head = i
+	} else {
+		s.counters[b.tail].next = i
+	}
+
+	b.tail = i
+}
+
+// insertBucketBefore takes a bucket from the free list and inserts it as the previous of the given bucket.
+func (s *CompactStreamSummary[T]) insertBucketBefore(next int32, count int) int32 {
+	i := s.free
+	b := &s.buckets[i]
+	s.free = b.next
+
+	previous := s.buckets[next].previous
+
+	*b = compactBucket{
+		count:    count,
+		previous: previous,
+		next:     next,
+		head:     nilIndex,
+		tail:     nilIndex,
+	}
+
+	if previous == nilIndex {
+		s.head = i
+	} else {
+		s.buckets[previous].next = i
+	}
+
+	s.buckets[next].previous = i
+
+	return i
+}
+
+// removeBucket unlinks the bucket from the list of buckets and returns it to the free list.
+func (s *CompactStreamSummary[T]) removeBucket(i int32) {
+	b := &s.buckets[i]
+
+	if b.previous == nilIndex {
+		s.head = b.next
+	} else {
+		s.buckets[b.previous].next = b.next
+	}
+
+	if b.next == nilIndex {
+		s.tail = b.previous
+	} else {
+		s.buckets[b.next].previous = b.previous
+	}
+
+	b.previous = nilIndex
+	b.next = s.free
+	s.free = i
+}
+
+// Top finds the top-k elements seen in the stream.
+// The slice is returned in descending order of frequency.
+// The first boolean is true iff the order of the top-k elements is correct and the implementation guarantees they are the actual top-k, irrespective of the errors.
+// The second boolean is true iff the implementation guarantees they are the actual top-k, irrespective of the errors.
+func (s *CompactStreamSummary[T]) Top(k int) ([]T, bool, bool) {
+	topK := make([]T, 0, min(k, len(s.elements)))
+	order := true
+	guaranteed := false
+	minGuaranteedCount := math.MaxInt
+	previousGuaranteedCount := math.MaxInt
+
+OuterLoop:
+	for b := s.head; b != nilIndex; b = s.buckets[b].next {
+		if s.buckets[b].count == 0 {
+			continue
+		}
+
+		for i := s.buckets[b].head; i != nilIndex; i = s.counters[i].next {
+			c := &s.counters[i]
+
+			if len(topK) >= k {
+				guaranteed = c.count <= minGuaranteedCount
+				break OuterLoop
+			}
+
+			topK = append(topK, c.key)
+			guaranteedCount := c.count - c.error
+			minGuaranteedCount = min(minGuaranteedCount, guaranteedCount)
+			order = order && (guaranteedCount <= previousGuaranteedCount)
+
+			previousGuaranteedCount = guaranteedCount
+		}
+	}
+
+	return topK, order, guaranteed
+}
+
+// Frequent finds the set of elements that contribute more than phi * Hits of the total frequency.
+// The slice is returned in descending order of frequency.
+// The boolean is true iff the returned slice is guaranteed to all be frequent elements, irrespective of the errors.
+func (s *CompactStreamSummary[T]) Frequent(phi float64) ([]T, bool) {
+	threshold := int(math.Ceil(phi * float64(s.hits)))
+	frequent := make([]T, 0)
+	guaranteed := true
+
+	for b := s.head; b != nilIndex; b = s.buckets[b].next {
+		// all counts in the same bucket have the same frequency, so we only need to test this predicate once per bucket.
+		if s.buckets[b].count <= threshold {
+			break
+		}
+
+		for i := s.buckets[b].head; i != nilIndex; i = s.counters[i].next {
+			c := &s.counters[i]
+			frequent = append(frequent, c.key)
+			guaranteed = guaranteed && ((c.count - c.error) >= threshold)
+		}
+	}
+
+	return frequent, guaranteed
+}
+
+// Hits counts the total number of hits for all elements.
+func (s *CompactStreamSummary[T]) Hits() int {
+	return s.hits
+}
+
+// Capacity is the maximum number of elements monitored by the summary.
+func (s *CompactStreamSummary[T]) Capacity() int {
+	return len(s.counters)
+}
+
+// Evictions counts the monitored elements that were replaced by other elements.
+func (s *CompactStreamSummary[T]) Evictions() int {
+	return s.evictions
+}
+
+// MinCount is the smallest count of a monitored element, or zero if no element is monitored.
+// Once the summary is full, an element that is not monitored may have been counted up to this count.
+func (s *CompactStreamSummary[T]) MinCount() int {
+	b := s.tail
+	if b != nilIndex && s.buckets[b].count == 0 {
+		b = s.buckets[b].previous
+	}
+
+	if b == nilIndex {
+		return 0
+	}
+
+	return s.buckets[b].count
+}
+
+// Get retrieves the approximated frequency for the given element, with a bounds on the error.
+func (s *CompactStreamSummary[T]) Get(e T) (Count, bool) {
+	var count Count
+
+	i, found := s.elements[e]
+	if found {
+		count = Count{Count: s.counters[i].count, Error: s.counters[i].error}
+	}
+
+	return count, found
+}
+
+// NewCompactStreamSummary creates a new instance of a compact stream summary with the given capacity.
+// The error for frequency approximations is guaranteed to be bounded by Hits / capacity.
+// NewCompactStreamSummary panics if the capacity is not positive or does not fit in an int32 index.
+func NewCompactStreamSummary[T cmp.Ordered](capacity int) *CompactStreamSummary[T] {
+	if capacity <= 0 || capacity >= math.MaxInt32 {
+		panic(fmt.Errorf("%w: capacity must be in the range (0, %d), got %d", ErrInvalidOption, math.MaxInt32, capacity))
+	}
+
+	s := &CompactStreamSummary[T]{
+		elements: make(map[T]int32, capacity),
+		counters: make([]compactCounter[T], capacity),
+		buckets:  make([]compactBucket, capacity+1),
+		head:     0,
+		tail:     0,
+	}
+
+	// the first bucket holds all counters with a frequency of zero.
+	s.buckets[0] = compactBucket{
+		previous: nilIndex,
+		next:     nilIndex,
+		head:     0,
+		tail:     int32(capacity - 1),
+	}
+
+	for i := range s.counters {
+		s.counters[i] = compactCounter[T]{
+			previous: int32(i - 1),
+			next:     int32(i + 1),
+		}
+	}
+
+	s.counters[capacity-1].next = nilIndex
+
+	// the remaining buckets are free.
+	s.free = nilIndex
+
+	for i := len(s.buckets) - 1; i > 0; i-- {
+		s.buckets[i].next = s.free
+		s.free = int32(i)
+	}
+
+	return s
+}
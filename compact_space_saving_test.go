@@ -0,0 +1,202 @@
+package heavy_hitters
+
+import (
+	"github.com/stretchr/testify/require"
+	"math"
+	"math/rand"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestCompactSpaceSaving(t *testing.T) {
+	var hh HeavyHitters[int]
+
+	stream := []int{12, 199997, 30000, 3, 8, 5, 10, 9, 2, 3, 5}
+	hh = NewCompactStreamSummary[int](8)
+
+	hits := 0
+
+	for _, e := range stream {
+		hits++
+		hh.Hit(e)
+	}
+
+	count := hh.Hit(5)
+	require.Equal(t, 3, count.Count)
+	require.Equal(t, 0, count.Error)
+
+	top, order, guaranteed := hh.Top(2)
+	require.True(t, order)
+	require.False(t, guaranteed)
+	require.Equal(t, []int{5, 2}, top)
+
+	frequent, guaranteed := hh.Frequent(0.1)
+	require.True(t, guaranteed)
+	require.Equal(t, []int{5}, frequent)
+
+	require.Equal(t, hits+1, hh.Hits())
+
+	count, found := hh.Get(9)
+	require.False(t, found)
+	require.Equal(t, Count{Count: 0, Error: 0}, count)
+
+	count, found = hh.Get(2)
+	require.True(t, found)
+	require.Equal(t, Count{Count: 2, Error: 1}, count)
+}
+
+func TestCompactSpaceSaving_TopLargeK(t *testing.T) {
+	hh := NewCompactStreamSummary[string](4)
+	hh.Hit("a")
+	hh.Hit("a")
+	hh.Hit("b")
+
+	top, _, _ := hh.Top(math.MaxInt)
+	require.Equal(t, []string{"a", "b"}, top)
+}
+
+func TestCompactSpaceSaving_MatchesStreamSummary(t *testing.T) {
+	generator := rand.NewZipf(rand.New(rand.NewSource(42)), 1.08, 2, 10_000)
+	compact := NewCompactStreamSummary[uint64](50)
+	pointers := NewStreamSummary[uint64](50)
+
+	for i := 0; i < 100_000; i++ {
+		e := generator.Uint64()
+		require.Equal(t, pointers.Hit(e), compact.Hit(e))
+	}
+
+	for e := range pointers.elements {
+		expected, _ := pointers.Get(e)
+		actual, found := compact.Get(e)
+		require.True(t, found)
+		require.Equal(t, expected, actual)
+	}
+
+	expectedTop, expectedOrder, expectedGuaranteed := pointers.Top(10)
+	top, order, guaranteed := compact.Top(10)
+	require.Equal(t, expectedTop, top)
+	require.Equal(t, expectedOrder, order)
+	require.Equal(t, expectedGuaranteed, guaranteed)
+
+	expectedFrequent, expectedFGuaranteed := pointers.Frequent(0.01)
+	frequent, fGuaranteed := compact.Frequent(0.01)
+	require.Equal(t, expectedFrequent, frequent)
+	require.Equal(t, expectedFGuaranteed, fGuaranteed)
+}
+
+func TestCompactSpaceSaving_ZeroAllocations(t *testing.T) {
+	generator := rand.NewZipf(rand.New(rand.NewSource(42)), 1.08, 2, math.MaxUint64)
+	hh := NewCompactStreamSummary[uint64](100)
+
+	// warm up the map so that it has grown to its steady state size.
+	for i := 0; i < 10_000; i++ {
+		hh.Hit(generator.Uint64())
+	}
+
+	allocations := testing.AllocsPerRun(10_000, func() {
+		hh.Hit(generator.Uint64())
+	})
+
+	require.Zero(t, allocations)
+}
+
+func TestCompactSpaceSaving_Stats(t *testing.T) {
+	hh := NewCompactStreamSummary[string](2)
+	require.Equal(t, 2, hh.Capacity())
+	require.Equal(t, 0, hh.MinCount())
+
+	hh.Hit("a")
+	hh.Hit("a")
+	require.Equal(t, 2, hh.MinCount())
+
+	for _, e := range []string{"b", "c", "d"} {
+		hh.Hit(e)
+	}
+
+	require.Equal(t, 2, hh.Evictions())
+	require.Equal(t, 2, hh.MinCount())
+	require.Equal(t, 2, hh.Capacity())
+}
+
+func TestNewCompactStreamSummary_Invalid(t *testing.T) {
+	require.Panics(t, func() {
+		NewCompactStreamSummary[int](0)
+	})
+}
+
+func BenchmarkCompactSpaceSaving(b *testing.B) {
+	seed := time.Now().UTC().UnixNano()
+
+	s := 1.08
+	v := 2.0
+	imax := uint64(math.MaxUint64)
+
+	generator := rand.NewZipf(rand.New(rand.NewSource(seed)), s, v, imax)
+	ss := NewCompactStreamSummary[uint64](100)
+
+	b.Run("Hit", func(b *testing.B) {
+		b.ReportAllocs()
+
+		for i := 0; i < b.N; i++ {
+			ss.Hit(generator.Uint64())
+		}
+	})
+
+	b.Run("Top", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			ss.Top(5)
+		}
+	})
+
+	top, tGuaranteed, order := ss.Top(5)
+	require.Equal(b, []uint64{0, 1, 2, 3, 4}, top)
+	require.True(b, tGuaranteed)
+	require.True(b, order)
+
+	b.Run("Frequent", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			ss.Frequent(0.01)
+		}
+	})
+}
+
+// BenchmarkGarbageCollection compares allocations and GC pause time of the pointer-based and compact summaries.
+func BenchmarkGarbageCollection(b *testing.B) {
+	const capacity = 1_000_000
+
+	implementations := map[string]func() HeavyHitters[uint64]{
+		"List": func() HeavyHitters[uint64] {
+			return NewStreamSummary[uint64](capacity)
+		},
+		"Compact": func() HeavyHitters[uint64] {
+			return NewCompactStreamSummary[uint64](capacity)
+		},
+	}
+
+	for name, constructor := range implementations {
+		b.Run(name, func(b *testing.B) {
+			generator := rand.NewZipf(rand.New(rand.NewSource(42)), 1.08, 2, math.MaxUint64)
+			hh := constructor()
+
+			var before, after runtime.MemStats
+
+			runtime.GC()
+			runtime.ReadMemStats(&before)
+
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				hh.Hit(generator.Uint64())
+			}
+
+			b.StopTimer()
+			runtime.GC()
+			runtime.ReadMemStats(&after)
+
+			b.ReportMetric(float64(after.PauseTotalNs-before.PauseTotalNs)/float64(after.NumGC-before.NumGC), "ns/gc-pause")
+			runtime.KeepAlive(hh)
+		})
+	}
+}
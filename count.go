@@ -1,7 +1,18 @@
 package heavy_hitters
 
+import "math"
+
 // Count is the frequency of an element in a stream along with its estimation error.
 type Count struct {
 	Count int
 	Error int
 }
+
+// saturatingAdd adds two non-negative counts, saturating at math.MaxInt rather than overflowing.
+func saturatingAdd(a, b int) int {
+	if a > math.MaxInt-b {
+		return math.MaxInt
+	}
+
+	return a + b
+}
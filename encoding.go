@@ -0,0 +1,476 @@
+package heavy_hitters
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"math"
+	"reflect"
+	"slices"
+)
+
+// The binary format of a summary is:
+//
+//	magic     [4]byte "HHSS"
+//	version   uint8
+//	algorithm uint8
+//	key kind  uint8, the reflect.Kind of the key type
+//	capacity  uvarint, zero for summaries without a fixed capacity
+//	hits      uvarint
+//	counters  uvarint, followed by each counter in descending order of frequency
+//	  key     varint for signed integers, uvarint for unsigned integers, 8 bytes for floats, uvarint length and bytes for strings
+//	  count   uvarint
+//	  error   uvarint
+//	  arrival uvarint
+//	checksum  uint32, the CRC-32 (IEEE) of all preceding bytes
+const (
+	// FormatVersion is the version of the binary format written by MarshalBinary.
+	FormatVersion uint8 = 1
+	// AlgorithmSpaceSaving identifies summaries of the SpaceSaving algorithm.
+	// StreamSummary and CompactStreamSummary share the same algorithm, so their binary formats are interchangeable.
+	AlgorithmSpaceSaving uint8 = 1
+	// AlgorithmExact identifies summaries that count every element exactly.
+	AlgorithmExact uint8 = 2
+)
+
+var magic = [4]byte{'H', 'H', 'S', 'S'}
+
+// ErrCorrupt is returned when decoding data that is not a valid summary in the binary format.
+var ErrCorrupt = errors.New("corrupt summary")
+
+// ErrIncompatible is returned when decoding a summary that was written by a different algorithm, key type or format version.
+var ErrIncompatible = errors.New("incompatible summary")
+
+// snapshot is the intermediate representation of a summary in the binary format.
+type snapshot[T cmp.Ordered] struct {
+	algorithm uint8
+	capacity  int
+	hits      int
+	counters  []snapshotCounter[T]
+}
+
+// snapshotCounter is a single counter in the intermediate representation of a summary.
+type snapshotCounter[T cmp.Ordered] struct {
+	key     T
+	count   int
+	error   int
+	arrival int
+}
+
+// MarshalBinary encodes the summary in the binary format.
+func (s *StreamSummary[T]) MarshalBinary() ([]byte, error) {
+	return s.snapshot().encode(), nil
+}
+
+// snapshot captures the monitored counters of the summary in descending order of frequency.
+func (s *StreamSummary[T]) snapshot() snapshot[T] {
+	snap := snapshot[T]{
+		algorithm: AlgorithmSpaceSaving,
+		capacity:  s.capacity,
+		hits:      s.hits,
+		counters:  make([]snapshotCounter[T], 0, len(s.elements)),
+	}
+
+	for b := s.buckets.Head(); b != nil && b.Value.count > 0; b = b.Next() {
+		for c := b.Value.counts.Head(); c != nil; c = c.Next() {
+			snap.counters = append(snap.counters, snapshotCounter[T]{
+				key:     c.Value.key,
+				count:   c.Value.count,
+				error:   c.Value.error,
+				arrival: c.Value.arrival,
+			})
+		}
+	}
+
+	return snap
+}
+
+// UnmarshalBinary replaces the contents of the summary with the decoded binary format.
+// The capacity is taken from the encoded summary, while the options the summary was created with are kept.
+func (s *StreamSummary[T]) UnmarshalBinary(data []byte) error {
+	snap, err := decodeSnapshot[T](data, AlgorithmSpaceSaving)
+	if err != nil {
+		return err
+	}
+
+	s.restore(snap)
+
+	return nil
+}
+
+// restore replaces the contents of the summary with the counters of the snapshot.
+// The counters must be in descending order of frequency, with distinct keys, and must not exceed the capacity of the snapshot.
+// The count an element that is not monitored may have been counted up to is reset, since the snapshot only has unused counters for elements that were never counted.
+func (s *StreamSummary[T]) restore(snap snapshot[T]) {
+	buckets := NewList[frequencyBucket[T]]()
+	elements := make(map[T]*Node[frequencyCounter[T]], len(snap.counters))
+
+	for _, c := range snap.counters {
+		bucket := buckets.Tail()
+
+		if bucket == nil || bucket.Value.count != c.count {
+			buckets.PushTail(frequencyBucket[T]{
+				count:  c.count,
+				counts: NewList[frequencyCounter[T]](),
+			})
+			bucket = buckets.Tail()
+		}
+
+		bucket.Value.counts.PushTail(frequencyCounter[T]{
+			key:     c.key,
+			count:   c.count,
+			error:   c.error,
+			arrival: c.arrival,
+			bucket:  bucket,
+		})
+		elements[c.key] = bucket.Value.counts.Tail()
+	}
+
+	// the unused counters are only allocated once they are needed, so the memory of a decoded summary is bounded by its encoding.
+	s.hits = snap.hits
+	s.capacity = snap.capacity
+	s.elements = elements
+	s.buckets = buckets
+	// the floor is not encoded, since only the summaries of a GroupedSummary have one and those cannot be serialized.
+	s.floor = 0
+	s.top, s.topSet, s.kth = nil, nil, 0
+
+	if s.onTopKChange != nil {
+		top, _, _ := s.Top(s.topK)
+		s.setTop(top)
+	}
+}
+
+// MarshalBinary encodes the summary in the binary format.
+// The format is interchangeable with the one of [StreamSummary].
+func (s *CompactStreamSummary[T]) MarshalBinary() ([]byte, error) {
+	snap := snapshot[T]{
+		algorithm: AlgorithmSpaceSaving,
+		capacity:  len(s.counters),
+		hits:      s.hits,
+		counters:  make([]snapshotCounter[T], 0, len(s.elements)),
+	}
+
+	for b := s.head; b != nilIndex && s.buckets[b].count > 0; b = s.buckets[b].next {
+		for i := s.buckets[b].head; i != nilIndex; i = s.counters[i].next {
+			c := &s.counters[i]
+			snap.counters = append(snap.counters, snapshotCounter[T]{
+				key:   c.key,
+				count: c.count,
+				error: c.error,
+			})
+		}
+	}
+
+	return snap.encode(), nil
+}
+
+// UnmarshalBinary replaces the contents of the summary with the decoded binary format.
+func (s *CompactStreamSummary[T]) UnmarshalBinary(data []byte) error {
+	snap, err := decodeSnapshot[T](data, AlgorithmSpaceSaving)
+	if err != nil {
+		return err
+	}
+
+	// unlike a StreamSummary, every counter is allocated up front, so the unused counters must be bounded.
+	if snap.capacity > len(snap.counters) && snap.capacity > MaxCapacity {
+		return fmt.Errorf("%w: capacity %d is above the maximum of %d", ErrIncompatible, snap.capacity, MaxCapacity)
+	}
+
+	if snap.capacity >= math.MaxInt32 {
+		return fmt.Errorf("%w: capacity %d does not fit in an int32 index", ErrIncompatible, snap.capacity)
+	}
+
+	// Start from an empty summary and move each counter out of the zero frequency bucket, from the most to the least frequent.
+	restored := NewCompactStreamSummary[T](snap.capacity)
+	restored.hits = snap.hits
+
+	const zero int32 = 0
+	last := nilIndex
+
+	for j, c := range snap.counters {
+		i := int32(j)
+
+		if last == nilIndex || restored.buckets[last].count != c.count {
+			last = restored.insertBucketBefore(zero, c.count)
+		}
+
+		restored.detachCounter(i)
+		restored.counters[i].key = c.key
+		restored.counters[i].count = c.count
+		restored.counters[i].error = c.error
+		restored.pushCounter(last, i)
+		restored.elements[c.key] = i
+	}
+
+	// The zero frequency bucket is only kept while it has unused counters.
+	if restored.buckets[zero].head == nilIndex {
+		restored.removeBucket(zero)
+	}
+
+	*s = *restored
+
+	return nil
+}
+
+// MarshalBinary encodes the exact counts in the binary format.
+func (n NaiveHeavyHitters[T]) MarshalBinary() ([]byte, error) {
+	snap := snapshot[T]{
+		algorithm: AlgorithmExact,
+		counters:  make([]snapshotCounter[T], 0, len(n.counts)),
+	}
+
+	for key, count := range n.counts {
+		snap.hits = saturatingAdd(snap.hits, count)
+		snap.counters = append(snap.counters, snapshotCounter[T]{
+			key:     key,
+			count:   count,
+			arrival: n.arrivals[key],
+		})
+	}
+
+	// the binary format expects counters in descending order of frequency, with the key making the encoding deterministic.
+	sortCounters(snap.counters)
+
+	return snap.encode(), nil
+}
+
+// UnmarshalBinary replaces the exact counts with the decoded binary format.
+func (n *NaiveHeavyHitters[T]) UnmarshalBinary(data []byte) error {
+	snap, err := decodeSnapshot[T](data, AlgorithmExact)
+	if err != nil {
+		return err
+	}
+
+	n.counts = make(map[T]int, len(snap.counters))
+
+	if n.tieBreak == TieBreakArrival {
+		n.arrivals = make(map[T]int, len(snap.counters))
+	}
+
+	for _, c := range snap.counters {
+		n.counts[c.key] = c.count
+
+		if n.arrivals != nil {
+			n.arrivals[c.key] = c.arrival
+		}
+	}
+
+	return nil
+}
+
+// AppendKey appends the binary encoding of the key to the buffer, using the same encoding as the keys of a summary.
+func AppendKey[T cmp.Ordered](b []byte, key T) []byte {
+	v := reflect.ValueOf(key)
+
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return binary.AppendVarint(b, v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return binary.AppendUvarint(b, v.Uint())
+	case reflect.Float32, reflect.Float64:
+		return binary.LittleEndian.AppendUint64(b, math.Float64bits(v.Float()))
+	default:
+		s := v.String()
+		b = binary.AppendUvarint(b, uint64(len(s)))
+		return append(b, s...)
+	}
+}
+
+// DecodeKey decodes a key encoded by AppendKey from the start of the buffer, returning the key and the number of bytes read.
+func DecodeKey[T cmp.Ordered](b []byte) (T, int, error) {
+	var key T
+
+	v := reflect.ValueOf(&key).Elem()
+
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, n := binary.Varint(b)
+		if n <= 0 || v.OverflowInt(i) {
+			return key, 0, fmt.Errorf("%w: invalid integer key", ErrCorrupt)
+		}
+
+		v.SetInt(i)
+
+		return key, n, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		u, n := binary.Uvarint(b)
+		if n <= 0 || v.OverflowUint(u) {
+			return key, 0, fmt.Errorf("%w: invalid unsigned integer key", ErrCorrupt)
+		}
+
+		v.SetUint(u)
+
+		return key, n, nil
+	case reflect.Float32, reflect.Float64:
+		if len(b) < 8 {
+			return key, 0, fmt.Errorf("%w: invalid float key", ErrCorrupt)
+		}
+
+		v.SetFloat(math.Float64frombits(binary.LittleEndian.Uint64(b)))
+
+		return key, 8, nil
+	default:
+		length, n := binary.Uvarint(b)
+		if n <= 0 || length > uint64(len(b)-n) {
+			return key, 0, fmt.Errorf("%w: invalid string key", ErrCorrupt)
+		}
+
+		v.SetString(string(b[n : n+int(length)]))
+
+		return key, n + int(length), nil
+	}
+}
+
+// keyKind identifies the key type of a summary in the binary format.
+func keyKind[T cmp.Ordered]() uint8 {
+	var key T
+	return uint8(reflect.TypeOf(key).Kind())
+}
+
+// encode writes the snapshot in the binary format.
+func (s snapshot[T]) encode() []byte {
+	b := make([]byte, 0, 32+len(s.counters)*16)
+	b = append(b, magic[:]...)
+	b = append(b, FormatVersion, s.algorithm, keyKind[T]())
+	b = binary.AppendUvarint(b, uint64(s.capacity))
+	b = binary.AppendUvarint(b, uint64(s.hits))
+	b = binary.AppendUvarint(b, uint64(len(s.counters)))
+
+	for _, c := range s.counters {
+		b = AppendKey(b, c.key)
+		b = binary.AppendUvarint(b, uint64(c.count))
+		b = binary.AppendUvarint(b, uint64(c.error))
+		b = binary.AppendUvarint(b, uint64(c.arrival))
+	}
+
+	return binary.LittleEndian.AppendUint32(b, crc32.ChecksumIEEE(b))
+}
+
+// Header describes a summary in the binary format, without decoding its counters.
+type Header struct {
+	Version   uint8
+	Algorithm uint8
+	// KeyKind is the reflect.Kind of the key type of the summary.
+	KeyKind  reflect.Kind
+	Capacity int
+	Hits     int
+	// Counters is the number of monitored counters.
+	Counters int
+}
+
+// DecodeHeader reads the header of a summary in the binary format, verifying its checksum.
+// Unlike decoding a summary, it accepts any format version, algorithm and key type, so that they can be checked for compatibility.
+func DecodeHeader(data []byte) (Header, error) {
+	header, _, err := decodeHeader(data)
+	return header, err
+}
+
+// decodeHeader reads the header of a summary in the binary format, returning the encoded counters that follow it.
+func decodeHeader(data []byte) (Header, []byte, error) {
+	var header Header
+	var err error
+
+	if len(data) < len(magic)+3+4 || !bytes.Equal(data[:len(magic)], magic[:]) {
+		return header, nil, fmt.Errorf("%w: missing header", ErrCorrupt)
+	}
+
+	body, checksum := data[:len(data)-4], binary.LittleEndian.Uint32(data[len(data)-4:])
+	if crc32.ChecksumIEEE(body) != checksum {
+		return header, nil, fmt.Errorf("%w: checksum mismatch", ErrCorrupt)
+	}
+
+	header.Version, header.Algorithm, header.KeyKind = body[4], body[5], reflect.Kind(body[6])
+	r := body[7:]
+
+	for _, field := range []*int{&header.Capacity, &header.Hits, &header.Counters} {
+		if *field, r, err = readInt(r); err != nil {
+			return header, nil, err
+		}
+	}
+
+	return header, r, nil
+}
+
+// decodeSnapshot reads a snapshot in the binary format, verifying its checksum, compatibility and invariants.
+func decodeSnapshot[T cmp.Ordered](data []byte, algorithm uint8) (snapshot[T], error) {
+	var snap snapshot[T]
+
+	header, r, err := decodeHeader(data)
+	if err != nil {
+		return snap, err
+	}
+
+	switch {
+	case header.Version != FormatVersion:
+		return snap, fmt.Errorf("%w: format version %d, expected %d", ErrIncompatible, header.Version, FormatVersion)
+	case header.Algorithm != algorithm:
+		return snap, fmt.Errorf("%w: algorithm %d, expected %d", ErrIncompatible, header.Algorithm, algorithm)
+	case header.KeyKind != reflect.Kind(keyKind[T]()):
+		return snap, fmt.Errorf("%w: key kind %s, expected %s", ErrIncompatible, header.KeyKind, reflect.Kind(keyKind[T]()))
+	}
+
+	snap.algorithm, snap.capacity, snap.hits = header.Algorithm, header.Capacity, header.Hits
+	counters := header.Counters
+
+	if (algorithm == AlgorithmSpaceSaving && (counters > snap.capacity || snap.capacity == 0)) || counters > len(r) {
+		return snap, fmt.Errorf("%w: %d counters do not fit a capacity of %d", ErrCorrupt, counters, snap.capacity)
+	}
+
+	snap.counters = make([]snapshotCounter[T], counters)
+	keys := make(map[T]struct{}, counters)
+
+	for i := range snap.counters {
+		c := &snap.counters[i]
+
+		var n int
+
+		if c.key, n, err = DecodeKey[T](r); err != nil {
+			return snap, err
+		}
+
+		r = r[n:]
+
+		if _, duplicate := keys[c.key]; duplicate {
+			return snap, fmt.Errorf("%w: duplicate key %v", ErrCorrupt, c.key)
+		}
+
+		keys[c.key] = struct{}{}
+
+		for _, field := range []*int{&c.count, &c.error, &c.arrival} {
+			if *field, r, err = readInt(r); err != nil {
+				return snap, err
+			}
+		}
+
+		if c.count == 0 || c.error > c.count || (i > 0 && c.count > snap.counters[i-1].count) {
+			return snap, fmt.Errorf("%w: counters are not in descending order of frequency", ErrCorrupt)
+		}
+	}
+
+	if len(r) != 0 {
+		return snap, fmt.Errorf("%w: %d trailing bytes", ErrCorrupt, len(r))
+	}
+
+	return snap, nil
+}
+
+// readInt reads a non-negative int encoded as a uvarint from the start of the buffer, returning the rest of the buffer.
+func readInt(b []byte) (int, []byte, error) {
+	u, n := binary.Uvarint(b)
+	if n <= 0 || u > math.MaxInt {
+		return 0, b, fmt.Errorf("%w: invalid integer", ErrCorrupt)
+	}
+
+	return int(u), b[n:], nil
+}
+
+// sortCounters orders counters in descending order of frequency, breaking ties by key.
+func sortCounters[T cmp.Ordered](counters []snapshotCounter[T]) {
+	slices.SortFunc(counters, func(a, b snapshotCounter[T]) int {
+		return cmp.Or(cmp.Compare(b.count, a.count), cmp.Compare(a.key, b.key))
+	})
+}
@@ -0,0 +1,226 @@
+package heavy_hitters
+
+import (
+	"github.com/stretchr/testify/require"
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestStreamSummary_MarshalBinary(t *testing.T) {
+	generator := rand.NewZipf(rand.New(rand.NewSource(42)), 1.08, 2, 1_000)
+	hh := NewStreamSummary[int64](20, WithTieBreak[int64](TieBreakArrival))
+
+	for i := 0; i < 10_000; i++ {
+		hh.Hit(int64(generator.Uint64()) - 500)
+	}
+
+	data, err := hh.MarshalBinary()
+	require.NoError(t, err)
+
+	restored := NewStreamSummary[int64](1, WithTieBreak[int64](TieBreakArrival))
+	require.NoError(t, restored.UnmarshalBinary(data))
+
+	require.Equal(t, hh.Hits(), restored.Hits())
+	require.Equal(t, hh.capacity, restored.capacity)
+
+	for e := range hh.elements {
+		expected, _ := hh.Get(e)
+		actual, found := restored.Get(e)
+		require.True(t, found)
+		require.Equal(t, expected, actual)
+	}
+
+	expected, _, _ := hh.Top(10)
+	actual, _, _ := restored.Top(10)
+	require.Equal(t, expected, actual)
+
+	// both summaries must keep evolving identically after a round-trip.
+	for i := 0; i < 1_000; i++ {
+		e := int64(generator.Uint64())
+		require.Equal(t, hh.Hit(e), restored.Hit(e))
+	}
+
+	again, err := restored.MarshalBinary()
+	require.NoError(t, err)
+
+	data, err = hh.MarshalBinary()
+	require.NoError(t, err)
+	require.Equal(t, data, again)
+}
+
+func TestCompactStreamSummary_MarshalBinary(t *testing.T) {
+	generator := rand.NewZipf(rand.New(rand.NewSource(42)), 1.08, 2, 1_000)
+	pointers := NewStreamSummary[string](20)
+
+	for i := 0; i < 10_000; i++ {
+		pointers.Hit(string(rune('a' + generator.Uint64()%64)))
+	}
+
+	data, err := pointers.MarshalBinary()
+	require.NoError(t, err)
+
+	compact := NewCompactStreamSummary[string](1)
+	require.NoError(t, compact.UnmarshalBinary(data))
+
+	// both summaries must keep evolving identically after a round-trip.
+	for i := 0; i < 1_000; i++ {
+		e := string(rune('a' + generator.Uint64()%64))
+		require.Equal(t, pointers.Hit(e), compact.Hit(e))
+	}
+
+	data, err = compact.MarshalBinary()
+	require.NoError(t, err)
+
+	restored := NewStreamSummary[string](1)
+	require.NoError(t, restored.UnmarshalBinary(data))
+	require.Equal(t, pointers.Hits(), restored.Hits())
+
+	for e := range pointers.elements {
+		expected, _ := pointers.Get(e)
+		actual, found := restored.Get(e)
+		require.True(t, found)
+		require.Equal(t, expected, actual)
+	}
+}
+
+func TestNaiveHeavyHitters_MarshalBinary(t *testing.T) {
+	hh := NewNaive[string](WithTieBreak[string](TieBreakArrival))
+
+	for _, e := range []string{"c", "b", "a", "a", "b", "c", "d"} {
+		hh.Hit(e)
+	}
+
+	data, err := hh.MarshalBinary()
+	require.NoError(t, err)
+
+	restored := NewNaive[string](WithTieBreak[string](TieBreakArrival))
+	require.NoError(t, restored.UnmarshalBinary(data))
+
+	require.Equal(t, hh.counts, restored.counts)
+	require.Equal(t, hh.arrivals, restored.arrivals)
+
+	top, _, _ := restored.Top(3)
+	require.Equal(t, []string{"c", "b", "a"}, top)
+}
+
+func TestNaiveHeavyHitters_MarshalBinarySaturates(t *testing.T) {
+	hh := NewNaive[string]()
+	hh.HitN("a", math.MaxInt)
+	hh.HitN("b", math.MaxInt)
+
+	data, err := hh.MarshalBinary()
+	require.NoError(t, err)
+
+	restored := NewNaive[string]()
+	require.NoError(t, restored.UnmarshalBinary(data))
+	require.Equal(t, hh.counts, restored.counts)
+	require.Equal(t, math.MaxInt, restored.Hits())
+}
+
+func TestUnmarshalBinary_Invalid(t *testing.T) {
+	hh := NewStreamSummary[string](4)
+	hh.Hit("a")
+	hh.Hit("b")
+
+	data, err := hh.MarshalBinary()
+	require.NoError(t, err)
+
+	corrupt := append([]byte(nil), data...)
+	corrupt[len(corrupt)/2] ^= 0xFF
+	require.ErrorIs(t, NewStreamSummary[string](1).UnmarshalBinary(corrupt), ErrCorrupt)
+	require.ErrorIs(t, NewStreamSummary[string](1).UnmarshalBinary(data[:len(data)-1]), ErrCorrupt)
+	require.ErrorIs(t, NewStreamSummary[string](1).UnmarshalBinary(nil), ErrCorrupt)
+
+	require.ErrorIs(t, NewStreamSummary[int](1).UnmarshalBinary(data), ErrIncompatible)
+
+	naive := NewNaive[string]()
+	require.ErrorIs(t, naive.UnmarshalBinary(data), ErrIncompatible)
+}
+
+func TestUnmarshalBinary_LargeCapacity(t *testing.T) {
+	data := snapshot[string]{
+		algorithm: AlgorithmSpaceSaving,
+		capacity:  1 << 40,
+		hits:      3,
+		counters:  []snapshotCounter[string]{{key: "a", count: 2}, {key: "b", count: 1}},
+	}.encode()
+
+	// the unused counters are only allocated once they are needed.
+	hh := NewStreamSummary[string](1)
+	require.NoError(t, hh.UnmarshalBinary(data))
+	require.Equal(t, 1<<40, hh.Capacity())
+	require.Equal(t, 2, hh.buckets.Len())
+
+	hh.Hit("c")
+	count, found := hh.Get("c")
+	require.True(t, found)
+	require.Equal(t, Count{Count: 1}, count)
+
+	require.ErrorIs(t, NewCompactStreamSummary[string](1).UnmarshalBinary(data), ErrIncompatible)
+}
+
+func TestUnmarshalBinary_DuplicateKeys(t *testing.T) {
+	data := snapshot[string]{
+		algorithm: AlgorithmSpaceSaving,
+		capacity:  4,
+		hits:      3,
+		counters:  []snapshotCounter[string]{{key: "a", count: 2}, {key: "a", count: 1}},
+	}.encode()
+
+	require.ErrorIs(t, NewStreamSummary[string](1).UnmarshalBinary(data), ErrCorrupt)
+	require.ErrorIs(t, NewStreamSummary[string](1).MergeBinary(data), ErrCorrupt)
+	require.ErrorIs(t, NewCompactStreamSummary[string](1).UnmarshalBinary(data), ErrCorrupt)
+}
+
+func TestDecodeHeader(t *testing.T) {
+	hh := NewStreamSummary[int](4)
+	hh.HitN(1, 3)
+	hh.Hit(2)
+
+	data, err := hh.MarshalBinary()
+	require.NoError(t, err)
+
+	header, err := DecodeHeader(data)
+	require.NoError(t, err)
+	require.Equal(t, Header{
+		Version:   FormatVersion,
+		Algorithm: AlgorithmSpaceSaving,
+		KeyKind:   reflect.Int,
+		Capacity:  4,
+		Hits:      4,
+		Counters:  2,
+	}, header)
+
+	_, err = DecodeHeader(data[:len(data)-1])
+	require.ErrorIs(t, err, ErrCorrupt)
+}
+
+func TestAppendKey(t *testing.T) {
+	b := AppendKey(nil, -42)
+	key, n, err := DecodeKey[int](b)
+	require.NoError(t, err)
+	require.Equal(t, -42, key)
+	require.Equal(t, len(b), n)
+
+	b = AppendKey(nil, 3.5)
+	f, n, err := DecodeKey[float64](b)
+	require.NoError(t, err)
+	require.Equal(t, 3.5, f)
+	require.Equal(t, len(b), n)
+
+	type path string
+
+	b = AppendKey(nil, path("/index.html"))
+	p, n, err := DecodeKey[path](b)
+	require.NoError(t, err)
+	require.Equal(t, path("/index.html"), p)
+	require.Equal(t, len(b), n)
+
+	_, _, err = DecodeKey[int8](AppendKey(nil, 300))
+	require.ErrorIs(t, err, ErrCorrupt)
+
+	_, _, err = DecodeKey[string](b[:len(b)-1])
+	require.ErrorIs(t, err, ErrCorrupt)
+}
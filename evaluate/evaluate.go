@@ -0,0 +1,320 @@
+// Package evaluate measures the accuracy and cost of heavy hitters implementations against the exact counts of the same stream.
+//
+// Evaluate feeds a stream to every candidate along with a NaiveHeavyHitters baseline, and compares the answers of the candidates
+// to the exact ones: the precision and recall of Frequent and Top, the relative error of Get, and how often the guarantees were violated.
+// It also reports the memory usage and throughput of every candidate, which helps choosing a capacity.
+package evaluate
+
+import (
+	"cmp"
+	"errors"
+	hh "heavy-hitters"
+	"math"
+	"slices"
+	"time"
+)
+
+// Candidate is a heavy hitters implementation to evaluate.
+// Implementations of hh.WeightedHeavyHitters receive weighted hits with HitN, while others are hit once for every unit of weight.
+// Implementations with a MemoryUsage() int method report their memory usage.
+type Candidate[T cmp.Ordered] struct {
+	Name    string
+	Summary hh.HeavyHitters[T]
+}
+
+// Option configures the queries of an evaluation.
+type Option func(*options)
+
+type options struct {
+	phi           float64
+	k             int
+	checkInterval int
+}
+
+// WithPhi evaluates Frequent(phi), 0.01 by default.
+func WithPhi(phi float64) Option {
+	return func(o *options) {
+		o.phi = phi
+	}
+}
+
+// WithK evaluates Top(k), 10 by default.
+func WithK(k int) Option {
+	return func(o *options) {
+		o.k = k
+	}
+}
+
+// WithCheckInterval checks the guarantees of the candidates every given number of hits, 10000 by default, as well as at the end of the stream.
+func WithCheckInterval(hits int) Option {
+	return func(o *options) {
+		o.checkInterval = hits
+	}
+}
+
+// Result is the evaluation of a candidate, or of the exact baseline.
+type Result struct {
+	Name string
+	// Frequent and Top compare the elements returned by Frequent(phi) and Top(k) at the end of the stream with the actual ones.
+	Frequent Accuracy
+	Top      Accuracy
+	// MeanRelativeError is the mean of |count - actual| / actual for the counts returned by Get for the actual top-k elements.
+	// An element without a count counts as zero, with a relative error of 1.
+	MeanRelativeError float64
+	Violations        Violations
+	// Memory is the number of bytes used by the summary at the end of the stream, or zero if it does not report its memory usage.
+	Memory int
+	// Duration is the total time spent hitting the summary, and Throughput the number of hits per second.
+	Duration   time.Duration
+	Throughput float64
+}
+
+// Accuracy compares a set of elements to the actual set.
+type Accuracy struct {
+	// Precision is the fraction of the elements that are actual elements, or 1 if there are no elements.
+	Precision float64
+	// Recall is the fraction of the actual elements that are found, or 1 if there are no actual elements.
+	Recall float64
+}
+
+// Violations counts the checks where a candidate claimed a guarantee that did not hold.
+type Violations struct {
+	// Checks is the number of times the guarantees were checked.
+	Checks int
+	// Frequent counts the checks where Frequent claimed its elements were all frequent, but one of them was not.
+	Frequent int
+	// Top counts the checks where Top claimed its elements were the actual top-k, but they were not.
+	Top int
+	// Order counts the checks where Top claimed its elements were in the correct order, but they were not.
+	Order int
+}
+
+// Total is the number of violations of any guarantee.
+func (v Violations) Total() int {
+	return v.Frequent + v.Top + v.Order
+}
+
+// batchSize is the number of hits buffered before hitting the summaries, so the time spent on each summary is measured over many hits.
+const batchSize = 4096
+
+type hit[T cmp.Ordered] struct {
+	element T
+	weight  int
+}
+
+// evaluation is the state of a summary being evaluated.
+type evaluation[T cmp.Ordered] struct {
+	Candidate[T]
+	duration   time.Duration
+	violations Violations
+}
+
+// Evaluate feeds the stream to every candidate and to an exact baseline, then compares the answers of the candidates to the exact ones.
+// The stream calls hit for every element of the stream with its weight, where weights that are not positive are ignored.
+// The first result is the baseline, named "exact", which is accurate by definition and whose cost the candidates can be compared against.
+// The candidates must be empty, and an error of the stream is returned along with no results.
+func Evaluate[T cmp.Ordered](stream func(hit func(e T, weight int)) error, candidates []Candidate[T], opts ...Option) ([]Result, error) {
+	o := options{phi: 0.01, k: 10, checkInterval: 10_000}
+
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	if o.phi <= 0 || o.phi > 1 || o.k < 1 || o.checkInterval < 1 {
+		return nil, errors.New("phi must be in (0, 1], and k and the check interval must be positive")
+	}
+
+	exact := hh.NewNaive[T]()
+	evaluations := make([]*evaluation[T], 0, len(candidates)+1)
+	evaluations = append(evaluations, &evaluation[T]{Candidate: Candidate[T]{Name: "exact", Summary: exact}})
+
+	for _, c := range candidates {
+		evaluations = append(evaluations, &evaluation[T]{Candidate: c})
+	}
+
+	batch := make([]hit[T], 0, batchSize)
+	var hits, checked int
+
+	flush := func() {
+		for _, e := range evaluations {
+			start := time.Now()
+
+			for _, h := range batch {
+				hitN(e.Summary, h.element, h.weight)
+			}
+
+			e.duration += time.Since(start)
+		}
+
+		batch = batch[:0]
+	}
+
+	err := stream(func(element T, weight int) {
+		if weight <= 0 {
+			return
+		}
+
+		batch = append(batch, hit[T]{element, weight})
+		hits++
+
+		// the batch is flushed early at every check interval, so the guarantees are checked after exactly that many hits.
+		if len(batch) == batchSize || hits%o.checkInterval == 0 {
+			flush()
+
+			if hits%o.checkInterval == 0 {
+				for _, e := range evaluations[1:] {
+					e.check(exact, o)
+				}
+
+				checked = hits
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	flush()
+
+	results := make([]Result, len(evaluations))
+
+	for i, e := range evaluations {
+		if i > 0 && checked != hits {
+			e.check(exact, o)
+		}
+
+		results[i] = e.result(exact, o)
+	}
+
+	return results, nil
+}
+
+// hitN hits the summary with a weight, once for every unit of weight if it does not support weights.
+func hitN[T cmp.Ordered](summary hh.HeavyHitters[T], e T, weight int) {
+	if weighted, ok := summary.(hh.WeightedHeavyHitters[T]); ok {
+		weighted.HitN(e, weight)
+		return
+	}
+
+	for range weight {
+		summary.Hit(e)
+	}
+}
+
+// check counts the guarantees of the summary that do not hold for the exact counts.
+func (e *evaluation[T]) check(exact hh.NaiveHeavyHitters[T], o options) {
+	e.violations.Checks++
+
+	if frequent, guaranteed := e.Summary.Frequent(o.phi); guaranteed {
+		threshold := o.phi * float64(exact.Hits())
+
+		if slices.ContainsFunc(frequent, func(element T) bool { return float64(actual(exact, element)) <= threshold }) {
+			e.violations.Frequent++
+		}
+	}
+
+	top, ordered, guaranteed := e.Summary.Top(o.k)
+
+	if guaranteed && !isTop(exact, top, o.k) {
+		e.violations.Top++
+	}
+
+	if ordered && !slices.IsSortedFunc(top, func(a, b T) int { return cmp.Compare(actual(exact, b), actual(exact, a)) }) {
+		e.violations.Order++
+	}
+}
+
+// isTop reports whether the elements are the actual top-k, where an element tied with the actual k-th element is as good as it.
+func isTop[T cmp.Ordered](exact hh.NaiveHeavyHitters[T], elements []T, k int) bool {
+	top, _, _ := exact.Top(k)
+
+	if len(elements) != len(top) {
+		return false
+	}
+
+	if len(top) == 0 {
+		return true
+	}
+
+	kth := actual(exact, top[len(top)-1])
+
+	return !slices.ContainsFunc(elements, func(element T) bool { return actual(exact, element) < kth })
+}
+
+// result compares the answers of the summary at the end of the stream with the exact ones.
+func (e *evaluation[T]) result(exact hh.NaiveHeavyHitters[T], o options) Result {
+	result := Result{
+		Name:       e.Name,
+		Violations: e.violations,
+		Duration:   e.duration,
+	}
+
+	if e.duration > 0 {
+		result.Throughput = float64(exact.Hits()) / e.duration.Seconds()
+	}
+
+	if m, ok := e.Summary.(interface{ MemoryUsage() int }); ok {
+		result.Memory = m.MemoryUsage()
+	}
+
+	frequent, _ := e.Summary.Frequent(o.phi)
+	actualFrequent, _ := exact.Frequent(o.phi)
+	result.Frequent = accuracy(frequent, func(element T) bool { return slices.Contains(actualFrequent, element) }, len(actualFrequent))
+
+	top, _, _ := e.Summary.Top(o.k)
+	actualTop, _, _ := exact.Top(o.k)
+	result.Top = accuracy(top, func(element T) bool { return inTop(exact, actualTop, element) }, len(actualTop))
+
+	var relativeErrors float64
+
+	for _, element := range actualTop {
+		count, _ := e.Summary.Get(element)
+		a := actual(exact, element)
+		relativeErrors += math.Abs(float64(count.Count-a)) / float64(a)
+	}
+
+	if len(actualTop) > 0 {
+		result.MeanRelativeError = relativeErrors / float64(len(actualTop))
+	}
+
+	return result
+}
+
+// inTop reports whether the element is among the actual top-k, or tied with the actual k-th element.
+func inTop[T cmp.Ordered](exact hh.NaiveHeavyHitters[T], top []T, element T) bool {
+	if len(top) == 0 {
+		return false
+	}
+
+	return actual(exact, element) >= actual(exact, top[len(top)-1])
+}
+
+// accuracy computes the precision and recall of the elements, where relevant identifies the actual elements.
+// An element tied with an actual element may count as relevant, so recall is capped at 1.
+func accuracy[T cmp.Ordered](elements []T, relevant func(T) bool, actual int) Accuracy {
+	found := 0
+
+	for _, element := range elements {
+		if relevant(element) {
+			found++
+		}
+	}
+
+	a := Accuracy{Precision: 1, Recall: 1}
+
+	if len(elements) > 0 {
+		a.Precision = float64(found) / float64(len(elements))
+	}
+
+	if actual > 0 {
+		a.Recall = min(1, float64(found)/float64(actual))
+	}
+
+	return a
+}
+
+// actual is the exact count of the element.
+func actual[T cmp.Ordered](exact hh.NaiveHeavyHitters[T], element T) int {
+	count, _ := exact.Get(element)
+	return count.Count
+}
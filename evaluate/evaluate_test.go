@@ -0,0 +1,101 @@
+package evaluate
+
+import (
+	"github.com/stretchr/testify/require"
+	hh "heavy-hitters"
+	"math/rand"
+	"slices"
+	"testing"
+)
+
+// zipf streams n elements of a Zipf distribution.
+func zipf(n int) func(hit func(e int, weight int)) error {
+	return func(hit func(e int, weight int)) error {
+		generator := rand.NewZipf(rand.New(rand.NewSource(42)), 1.2, 2, 10_000)
+
+		for range n {
+			hit(int(generator.Uint64()), 1)
+		}
+
+		return nil
+	}
+}
+
+// liar claims its top-k are guaranteed and ordered while returning them in reverse.
+type liar struct {
+	hh.NaiveHeavyHitters[int]
+}
+
+func (l liar) Top(k int) ([]int, bool, bool) {
+	top, _, _ := l.NaiveHeavyHitters.Top(k)
+	slices.Reverse(top)
+
+	return top[:len(top)-1], true, true
+}
+
+func TestEvaluate(t *testing.T) {
+	results, err := Evaluate(zipf(50_000), []Candidate[int]{
+		{Name: "stream", Summary: hh.NewStreamSummary[int](200)},
+		{Name: "compact", Summary: hh.NewCompactStreamSummary[int](20)},
+		{Name: "liar", Summary: liar{hh.NewNaive[int]()}},
+	}, WithK(5), WithPhi(0.02), WithCheckInterval(10_000))
+	require.NoError(t, err)
+	require.Len(t, results, 4)
+
+	exact := results[0]
+	require.Equal(t, "exact", exact.Name)
+	require.Equal(t, Accuracy{Precision: 1, Recall: 1}, exact.Frequent)
+	require.Equal(t, Accuracy{Precision: 1, Recall: 1}, exact.Top)
+	require.Zero(t, exact.MeanRelativeError)
+	require.Positive(t, exact.Memory)
+	require.Positive(t, exact.Throughput)
+
+	stream := results[1]
+	require.Equal(t, "stream", stream.Name)
+	require.Equal(t, Accuracy{Precision: 1, Recall: 1}, stream.Top)
+	require.Equal(t, Violations{Checks: 5}, stream.Violations)
+	require.Less(t, stream.MeanRelativeError, 0.05)
+	require.Less(t, stream.Memory, exact.Memory)
+
+	// a small capacity overestimates the counts and misses frequent elements, but the guarantees of the elements still hold.
+	// The order of the top-k is only inferred from lower bounds that do not increase, which does not rule out every misordering.
+	compact := results[2]
+	require.Greater(t, compact.MeanRelativeError, stream.MeanRelativeError)
+	require.Less(t, compact.Frequent.Recall, stream.Frequent.Recall)
+	require.Zero(t, compact.Violations.Frequent)
+	require.Zero(t, compact.Violations.Top)
+
+	lying := results[3]
+	require.Equal(t, Violations{Checks: 5, Top: 5, Order: 5}, lying.Violations)
+	require.Equal(t, Accuracy{Precision: 1, Recall: 0.8}, lying.Top)
+}
+
+func TestEvaluate_Weights(t *testing.T) {
+	stream := func(hit func(e string, weight int)) error {
+		hit("a", 3)
+		hit("b", 1)
+		hit("c", 0)
+		hit("b", 1)
+		hit("c", 1)
+
+		return nil
+	}
+
+	// the compact summary does not support weights, so it is hit once for every unit of weight.
+	results, err := Evaluate(stream, []Candidate[string]{{Name: "compact", Summary: hh.NewCompactStreamSummary[string](2)}}, WithK(2), WithCheckInterval(2))
+	require.NoError(t, err)
+
+	// c evicts b, taking over its count, so c is reported instead of b, which has no count anymore.
+	compact := results[1]
+	require.Equal(t, Violations{Checks: 2}, compact.Violations)
+	require.Equal(t, Accuracy{Precision: 0.5, Recall: 0.5}, compact.Top)
+	require.Equal(t, 0.5, compact.MeanRelativeError)
+}
+
+func TestEvaluate_Invalid(t *testing.T) {
+	_, err := Evaluate(zipf(1), nil, WithPhi(0))
+	require.Error(t, err)
+
+	_, err = Evaluate(zipf(1), nil, WithK(0))
+	require.Error(t, err)
+}
@@ -9,10 +9,17 @@ import (
 	"time"
 )
 
+// summary is a heavy hitters implementation that can report its memory usage.
+type summary interface {
+	hh.HeavyHitters[uint64]
+	MemoryUsage() int
+}
+
 func main() {
 	var hits int
 	var seed int64
 	var zipf bool
+	var budget int
 
 	// See https://en.wikipedia.org/wiki/Zipf%27s_law
 	var a, b float64
@@ -24,38 +31,72 @@ func main() {
 	flag.Float64Var(&a, "a", 1.08, "a parameter for Zipf's law")
 	flag.Float64Var(&b, "b", 2, "b parameter for Zipf's law'")
 	flag.Uint64Var(&imax, "imax", math.MaxUint64, "imax parameter for Zipf generator")
+	flag.IntVar(&budget, "m", 16*1024, "memory budget in bytes for each approximate summary")
 	flag.Parse()
 
-	fmt.Printf("Running simulation with seed %d (zipf: %v).\n", seed, zipf)
+	fmt.Printf("Running simulation with seed %d (zipf: %v, memory budget: %d bytes).\n", seed, zipf, budget)
 
 	rng := rand.New(rand.NewSource(seed))
 	generator := rand.NewZipf(rng, a, b, imax)
-	start := time.Now()
-	ss := hh.NewStreamSummary[uint64](100)
+	stream := make([]uint64, hits)
 
-	for i := 0; i < hits; i++ {
+	for i := range stream {
 		if zipf {
-			ss.Hit(generator.Uint64())
+			stream[i] = generator.Uint64()
 		} else {
-			ss.Hit(uint64(rng.NormFloat64()))
+			stream[i] = uint64(rng.NormFloat64())
 		}
 	}
 
-	frequent, fGuaranteed := ss.Frequent(0.01)
-	top, tGuaranteed, order := ss.Top(5)
+	ss, err := hh.NewWithMemoryBudget[uint64](budget, 0)
+	if err != nil {
+		panic(err)
+	}
 
-	fmt.Printf("Elapsed: %s\n", time.Since(start))
-	fmt.Printf("Total hits: %d, summarized hits: %d\n", hits, ss.Hits())
-	fmt.Printf("Frequent elements: %v (guaranteed: %v)\n", frequent, fGuaranteed)
-	fmt.Printf("Top elements (guaranteed: %v, order: %v): %v\n", tGuaranteed, order, top)
+	compact, err := hh.NewCompactWithMemoryBudget[uint64](budget, 0)
+	if err != nil {
+		panic(err)
+	}
+
+	exact := hh.NewNaive[uint64]()
+
+	summaries := []struct {
+		name    string
+		summary summary
+	}{
+		// the exact counts are summarized first to compare the approximations against them.
+		{"Naive", exact},
+		{"StreamSummary", ss},
+		{"CompactStreamSummary", compact},
+	}
 
-	for i, e := range top {
-		count, found := ss.Get(e)
+	for _, s := range summaries {
+		start := time.Now()
 
-		if !found {
-			panic("unable to find element")
+		for _, e := range stream {
+			s.summary.Hit(e)
 		}
 
-		fmt.Printf("Top-%d is %d: {count: %d, error: %d}}\n", i+1, e, count.Count, count.Error)
+		frequent, fGuaranteed := s.summary.Frequent(0.01)
+		top, tGuaranteed, order := s.summary.Top(5)
+
+		fmt.Printf("\n%s\n", s.name)
+		fmt.Printf("Elapsed: %s\n", time.Since(start))
+		fmt.Printf("Memory usage: %d bytes\n", s.summary.MemoryUsage())
+		fmt.Printf("Total hits: %d, summarized hits: %d\n", hits, s.summary.Hits())
+		fmt.Printf("Frequent elements: %v (guaranteed: %v)\n", frequent, fGuaranteed)
+		fmt.Printf("Top elements (guaranteed: %v, order: %v): %v\n", tGuaranteed, order, top)
+
+		for i, e := range top {
+			count, found := s.summary.Get(e)
+
+			if !found {
+				panic("unable to find element")
+			}
+
+			actual, _ := exact.Get(e)
+
+			fmt.Printf("Top-%d is %d: {count: %d, error: %d, actual: %d}\n", i+1, e, count.Count, count.Error, actual.Count)
+		}
 	}
 }
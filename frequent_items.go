@@ -0,0 +1,210 @@
+package heavy_hitters
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/bits"
+	"reflect"
+)
+
+// The serialization format of the Apache DataSketches frequent items sketch, shared by its Java and C++ implementations.
+// See https://datasketches.apache.org/docs/Frequency/FrequentItemsOverview.html
+//
+//	byte 0      preamble longs, 1 for an empty sketch and 4 otherwise
+//	byte 1      serial version, 1
+//	byte 2      family, 10
+//	byte 3      log2 of the maximum map size
+//	byte 4      log2 of the current map size
+//	byte 5      flags, with bits 0 and 2 both marking an empty sketch
+//	bytes 6-7   unused
+//	bytes 8-11  number of active items, uint32
+//	bytes 16-23 total weight of the stream, uint64
+//	bytes 24-31 offset, the maximum error of any weight, uint64
+//	weights     one uint64 per active item
+//	items       one per active item; an int32 length and UTF-8 bytes for strings, or an int64 for longs
+//
+// All values are little-endian.
+const (
+	frequentItemsSerialVersion      = 1
+	frequentItemsFamily             = 10
+	frequentItemsPreambleLongsEmpty = 1
+	frequentItemsPreambleLongs      = 4
+	frequentItemsEmptyFlags         = 1<<0 | 1<<2
+	frequentItemsMinLgMapSize       = 3
+	frequentItemsMaxLgMapSize       = 30
+)
+
+// MarshalFrequentItems encodes the summary in the serialization format of the Apache DataSketches frequent items sketch.
+// Only string and int64 keys are supported, matching the string and long items of the DataSketches libraries.
+//
+// The sketch keeps a lower bound for every item along with a single offset that bounds the error of all items.
+// The lower bound of each counter is its count minus its error, and the offset is the largest error of any element.
+// Counters without a guaranteed count are covered by the offset, so they are left out of the sketch.
+func (s *StreamSummary[T]) MarshalFrequentItems() ([]byte, error) {
+	kind := reflect.Kind(keyKind[T]())
+	if kind != reflect.String && kind != reflect.Int64 {
+		return nil, fmt.Errorf("%w: frequent items sketches only support string and int64 items, got %s", ErrIncompatible, kind)
+	}
+
+	snap := s.snapshot()
+	lgMax := frequentItemsLgMapSize(snap.capacity)
+
+	if snap.hits == 0 {
+		return []byte{frequentItemsPreambleLongsEmpty, frequentItemsSerialVersion, frequentItemsFamily, lgMax, frequentItemsMinLgMapSize, frequentItemsEmptyFlags, 0, 0}, nil
+	}
+
+	offset := snap.minimum()
+
+	for _, c := range snap.counters {
+		offset = max(offset, c.error)
+	}
+
+	counters := make([]snapshotCounter[T], 0, len(snap.counters))
+
+	for _, c := range snap.counters {
+		if c.count > c.error {
+			counters = append(counters, c)
+		}
+	}
+
+	lgCur := min(frequentItemsLgMapSize(len(counters)), lgMax)
+
+	b := make([]byte, 0, frequentItemsPreambleLongs*8+len(counters)*16)
+	b = append(b, frequentItemsPreambleLongs, frequentItemsSerialVersion, frequentItemsFamily, lgMax, lgCur, 0, 0, 0)
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(counters)))
+	b = binary.LittleEndian.AppendUint32(b, 0)
+	b = binary.LittleEndian.AppendUint64(b, uint64(snap.hits))
+	b = binary.LittleEndian.AppendUint64(b, uint64(offset))
+
+	for _, c := range counters {
+		b = binary.LittleEndian.AppendUint64(b, uint64(c.count-c.error))
+	}
+
+	for _, c := range counters {
+		v := reflect.ValueOf(c.key)
+
+		if kind == reflect.String {
+			b = binary.LittleEndian.AppendUint32(b, uint32(v.Len()))
+			b = append(b, v.String()...)
+		} else {
+			b = binary.LittleEndian.AppendUint64(b, uint64(v.Int()))
+		}
+	}
+
+	return b, nil
+}
+
+// UnmarshalFrequentItems replaces the contents of the summary with a decoded Apache DataSketches frequent items sketch.
+// The capacity is the maximum number of items the sketch can hold, while the options the summary was created with are kept.
+// Each item is counted as its weight plus the offset of the sketch, with the offset as its error.
+// Use [StreamSummary.Merge] to combine the decoded sketch with another summary.
+func (s *StreamSummary[T]) UnmarshalFrequentItems(data []byte) error {
+	kind := reflect.Kind(keyKind[T]())
+	if kind != reflect.String && kind != reflect.Int64 {
+		return fmt.Errorf("%w: frequent items sketches only support string and int64 items, got %s", ErrIncompatible, kind)
+	}
+
+	if len(data) < 8 {
+		return fmt.Errorf("%w: missing preamble", ErrCorrupt)
+	}
+
+	preambleLongs, version, family, lgMax, lgCur, flags := data[0]&0x3F, data[1], data[2], data[3], data[4], data[5]
+
+	switch {
+	case version != frequentItemsSerialVersion:
+		return fmt.Errorf("%w: serial version %d, expected %d", ErrIncompatible, version, frequentItemsSerialVersion)
+	case family != frequentItemsFamily:
+		return fmt.Errorf("%w: family %d, expected %d", ErrIncompatible, family, frequentItemsFamily)
+	case lgMax < frequentItemsMinLgMapSize || lgMax > frequentItemsMaxLgMapSize || lgCur > lgMax:
+		return fmt.Errorf("%w: invalid map sizes 2^%d and 2^%d", ErrCorrupt, lgCur, lgMax)
+	}
+
+	snap := snapshot[T]{
+		algorithm: AlgorithmSpaceSaving,
+		capacity:  frequentItemsCapacity(lgMax),
+	}
+
+	if flags&frequentItemsEmptyFlags != 0 {
+		if preambleLongs != frequentItemsPreambleLongsEmpty {
+			return fmt.Errorf("%w: %d preamble longs for an empty sketch", ErrCorrupt, preambleLongs)
+		}
+
+		s.restore(snap)
+
+		return nil
+	}
+
+	if preambleLongs != frequentItemsPreambleLongs || len(data) < frequentItemsPreambleLongs*8 {
+		return fmt.Errorf("%w: %d preamble longs for a non-empty sketch", ErrCorrupt, preambleLongs)
+	}
+
+	items := int(binary.LittleEndian.Uint32(data[8:]))
+	hits := binary.LittleEndian.Uint64(data[16:])
+	offset := binary.LittleEndian.Uint64(data[24:])
+	data = data[frequentItemsPreambleLongs*8:]
+
+	if items > snap.capacity || hits > math.MaxInt || offset > math.MaxInt || len(data) < items*8 {
+		return fmt.Errorf("%w: %d items do not fit a capacity of %d", ErrCorrupt, items, snap.capacity)
+	}
+
+	snap.hits = int(hits)
+	snap.counters = make([]snapshotCounter[T], items)
+
+	for i := range snap.counters {
+		weight := binary.LittleEndian.Uint64(data[i*8:])
+		if weight == 0 || weight > math.MaxInt-offset {
+			return fmt.Errorf("%w: invalid weight %d", ErrCorrupt, weight)
+		}
+
+		snap.counters[i].count = int(weight + offset)
+		snap.counters[i].error = int(offset)
+	}
+
+	data = data[items*8:]
+
+	for i := range snap.counters {
+		v := reflect.ValueOf(&snap.counters[i].key).Elem()
+
+		if kind == reflect.String {
+			if len(data) < 4 || uint64(binary.LittleEndian.Uint32(data)) > uint64(len(data)-4) {
+				return fmt.Errorf("%w: invalid string item", ErrCorrupt)
+			}
+
+			length := int(binary.LittleEndian.Uint32(data))
+			v.SetString(string(data[4 : 4+length]))
+			data = data[4+length:]
+		} else {
+			if len(data) < 8 {
+				return fmt.Errorf("%w: invalid long item", ErrCorrupt)
+			}
+
+			v.SetInt(int64(binary.LittleEndian.Uint64(data)))
+			data = data[8:]
+		}
+	}
+
+	if len(data) != 0 {
+		return fmt.Errorf("%w: %d trailing bytes", ErrCorrupt, len(data))
+	}
+
+	// the sketch stores items in the order of its hash map.
+	sortCounters(snap.counters)
+
+	s.restore(snap)
+
+	return nil
+}
+
+// frequentItemsLgMapSize is the log2 of the smallest map size of a frequent items sketch that holds the given number of items.
+func frequentItemsLgMapSize(items int) uint8 {
+	// the map of a sketch is purged once it is 3/4 full.
+	size := (items*4 + 2) / 3
+
+	return uint8(min(max(bits.Len(uint(max(size-1, 0))), frequentItemsMinLgMapSize), frequentItemsMaxLgMapSize))
+}
+
+// frequentItemsCapacity is the maximum number of items in a frequent items sketch with a map size of 2^lgMapSize.
+func frequentItemsCapacity(lgMapSize uint8) int {
+	return (1 << lgMapSize) * 3 / 4
+}
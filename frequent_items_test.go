@@ -0,0 +1,177 @@
+package heavy_hitters
+
+import (
+	"encoding/binary"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func readFrequentItems(t *testing.T, name string) []byte {
+	data, err := os.ReadFile(filepath.Join("testdata", "frequent_items", name))
+	require.NoError(t, err)
+
+	return data
+}
+
+// requireFrequentItemsRoundTrip checks that re-encoding the sketch decoded from data writes the same bytes.
+// The reference implementations write items in the order of their hash map, and size the map by the items they have seen
+// rather than the items they keep, so items are compared as a set and the current map size at byte 4 is not compared.
+func requireFrequentItemsRoundTrip[T int64 | string](t *testing.T, hh *StreamSummary[T], data []byte) {
+	encoded, err := hh.MarshalFrequentItems()
+	require.NoError(t, err)
+	require.Equal(t, len(data), len(encoded))
+
+	header := frequentItemsPreambleLongs * 8
+	require.Equal(t, data[:4], encoded[:4])
+	require.Equal(t, data[5:header], encoded[5:header])
+	require.ElementsMatch(t, frequentItemsEntries[T](t, data), frequentItemsEntries[T](t, encoded))
+}
+
+// frequentItemsEntry is an item of an encoded sketch along with its weight.
+type frequentItemsEntry[T int64 | string] struct {
+	item   T
+	weight uint64
+}
+
+// frequentItemsEntries splits the weights and items of an encoded non-empty sketch, in the order they are written.
+func frequentItemsEntries[T int64 | string](t *testing.T, data []byte) []frequentItemsEntry[T] {
+	n := int(binary.LittleEndian.Uint32(data[8:]))
+	weights := data[frequentItemsPreambleLongs*8:]
+	items := weights[n*8:]
+	entries := make([]frequentItemsEntry[T], n)
+
+	for i := range entries {
+		entries[i].weight = binary.LittleEndian.Uint64(weights[i*8:])
+
+		switch item := any(&entries[i].item).(type) {
+		case *string:
+			length := int(binary.LittleEndian.Uint32(items))
+			*item = string(items[4 : 4+length])
+			items = items[4+length:]
+		case *int64:
+			*item = int64(binary.LittleEndian.Uint64(items))
+			items = items[8:]
+		}
+	}
+
+	require.Empty(t, items)
+
+	return entries
+}
+
+func TestStreamSummary_UnmarshalFrequentItems(t *testing.T) {
+	data := readFrequentItems(t, "strings.bin")
+
+	hh := NewStreamSummary[string](1)
+	require.NoError(t, hh.UnmarshalFrequentItems(data))
+
+	require.Equal(t, 30, hh.Hits())
+	require.Equal(t, 12, hh.capacity)
+
+	top, _, _ := hh.Top(3)
+	require.Equal(t, []string{"apple", "banana", "cherry"}, top)
+
+	count, found := hh.Get("banana")
+	require.True(t, found)
+	require.Equal(t, Count{Count: 6, Error: 1}, count)
+
+	requireFrequentItemsRoundTrip(t, hh, data)
+}
+
+func TestStreamSummary_UnmarshalFrequentItemsLongs(t *testing.T) {
+	data := readFrequentItems(t, "longs.bin")
+
+	hh := NewStreamSummary[int64](1)
+	require.NoError(t, hh.UnmarshalFrequentItems(data))
+
+	require.Equal(t, 10, hh.Hits())
+	require.Equal(t, 6, hh.capacity)
+
+	count, found := hh.Get(-1)
+	require.True(t, found)
+	require.Equal(t, Count{Count: 3}, count)
+
+	requireFrequentItemsRoundTrip(t, hh, data)
+}
+
+func TestStreamSummary_UnmarshalFrequentItemsEmpty(t *testing.T) {
+	data := readFrequentItems(t, "empty.bin")
+
+	hh := NewStreamSummary[string](1)
+	require.NoError(t, hh.UnmarshalFrequentItems(data))
+	require.Equal(t, 0, hh.Hits())
+	require.Equal(t, 12, hh.capacity)
+
+	encoded, err := hh.MarshalFrequentItems()
+	require.NoError(t, err)
+	require.Equal(t, data, encoded)
+}
+
+func TestStreamSummary_MergeFrequentItems(t *testing.T) {
+	sketch := NewStreamSummary[string](1)
+	require.NoError(t, sketch.UnmarshalFrequentItems(readFrequentItems(t, "strings.bin")))
+
+	hh := NewStreamSummary[string](12)
+
+	for _, e := range []string{"banana", "banana", "banana", "banana", "banana", "date"} {
+		hh.Hit(e)
+	}
+
+	hh.Merge(sketch)
+
+	require.Equal(t, 36, hh.Hits())
+
+	count, found := hh.Get("banana")
+	require.True(t, found)
+	require.Equal(t, Count{Count: 11, Error: 1}, count)
+
+	top, _, _ := hh.Top(2)
+	require.Equal(t, []string{"apple", "banana"}, top)
+}
+
+func TestStreamSummary_MarshalFrequentItemsErrors(t *testing.T) {
+	hh := NewStreamSummary[string](4)
+
+	// the error of "c" is the count of the evicted "b", and is covered by the offset of the sketch.
+	for _, e := range []string{"a", "a", "a", "b", "c", "d", "e", "c"} {
+		hh.Hit(e)
+	}
+
+	data, err := hh.MarshalFrequentItems()
+	require.NoError(t, err)
+
+	decoded := NewStreamSummary[string](1)
+	require.NoError(t, decoded.UnmarshalFrequentItems(data))
+
+	for e := range hh.elements {
+		expected, _ := hh.Get(e)
+		actual, found := decoded.Get(e)
+
+		if !found {
+			// only counters without a guaranteed count are left out.
+			require.Equal(t, expected.Count, expected.Error)
+			continue
+		}
+
+		// the decoded bounds must contain the original bounds.
+		require.LessOrEqual(t, actual.Count-actual.Error, expected.Count-expected.Error)
+		require.GreaterOrEqual(t, actual.Count, expected.Count)
+	}
+}
+
+func TestStreamSummary_UnmarshalFrequentItemsInvalid(t *testing.T) {
+	data := readFrequentItems(t, "strings.bin")
+
+	require.ErrorIs(t, NewStreamSummary[int](1).UnmarshalFrequentItems(data), ErrIncompatible)
+	require.ErrorIs(t, NewStreamSummary[string](1).UnmarshalFrequentItems(data[:len(data)-1]), ErrCorrupt)
+	require.ErrorIs(t, NewStreamSummary[string](1).UnmarshalFrequentItems(data[:4]), ErrCorrupt)
+
+	family := append([]byte(nil), data...)
+	family[2] = 7
+	require.ErrorIs(t, NewStreamSummary[string](1).UnmarshalFrequentItems(family), ErrIncompatible)
+
+	_, err := NewStreamSummary[uint8](1).MarshalFrequentItems()
+	require.ErrorIs(t, err, ErrIncompatible)
+}
@@ -0,0 +1,435 @@
+package heavy_hitters
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"unsafe"
+)
+
+// GroupedSummary approximates the heavy hitters of every group of a stream, such as the top endpoints of every customer.
+// Every group is monitored by its own [StreamSummary], created on the first hit of the group, and the summaries share a budget of counters.
+//
+// A group starts with a minimum capacity and grows once full, up to the minimum capacity plus a share of the remaining counters
+// proportional to its share of the hits, so small groups use few counters and large groups many.
+// Counters are taken back from groups above their share when a group grows or a new group arrives.
+// When the budget cannot fit the minimum capacity of another group, a whole group is evicted according to the [GroupEviction] policy.
+//
+// The counts of every group remain bounded by the hits of that group: resizing a summary never understates the error of its counters.
+// A group that is evicted and hit again starts over, and its answers only cover the hits since.
+// A grouped summary cannot be serialized, and neither can the summaries of its groups, which are not exposed.
+type GroupedSummary[G, T cmp.Ordered] struct {
+	// The number of counters shared by the groups, and the number of counters used by their summaries.
+	budget int
+	used   int
+	// The hits of the monitored groups.
+	hits        int
+	minCapacity int
+	eviction    GroupEviction
+	tieBreak    TieBreak
+	groups      map[G]*Node[summaryGroup[G, T]]
+	// The monitored groups, from the least to the most recently hit.
+	recency *List[summaryGroup[G, T]]
+	// The hits of the monitored groups in buckets of groups with the same hits, from the most hits at the head to the fewest at the tail,
+	// with the least recently hit group at the head of each bucket. It is only maintained for GroupEvictionLeastHits.
+	byHits *List[frequencyBucket[G]]
+}
+
+// summaryGroup is the summary of a single group.
+type summaryGroup[G, T cmp.Ordered] struct {
+	key     G
+	summary *StreamSummary[T]
+	// The counter of the hits of the group in byHits, only for GroupEvictionLeastHits.
+	hits *Node[frequencyCounter[G]]
+}
+
+// GroupEviction selects which group a [GroupedSummary] stops monitoring when a new group does not fit in its budget.
+type GroupEviction int
+
+const (
+	// GroupEvictionLeastRecent evicts the group that was least recently hit.
+	GroupEvictionLeastRecent GroupEviction = iota
+	// GroupEvictionLeastHits evicts the group with the fewest hits, or the least recently hit of the groups tied for the fewest hits.
+	GroupEvictionLeastHits
+)
+
+// String returns the name of the group eviction policy.
+func (e GroupEviction) String() string {
+	switch e {
+	case GroupEvictionLeastRecent:
+		return "least-recent"
+	case GroupEvictionLeastHits:
+		return "least-hits"
+	default:
+		return "unknown"
+	}
+}
+
+// GroupOption configures optional behavior of a [GroupedSummary] at construction time.
+type GroupOption func(*groupOptions)
+
+type groupOptions struct {
+	minCapacity int
+	eviction    GroupEviction
+	tieBreak    TieBreak
+}
+
+// WithMinGroupCapacity sets the capacity of the summary of a new group, below which no group is shrunk.
+// It defaults to 8 counters, or the whole budget if it is smaller.
+func WithMinGroupCapacity(capacity int) GroupOption {
+	return func(o *groupOptions) {
+		o.minCapacity = capacity
+	}
+}
+
+// WithGroupEviction selects which group is evicted when a new group does not fit in the budget, [GroupEvictionLeastRecent] by default.
+func WithGroupEviction(policy GroupEviction) GroupOption {
+	return func(o *groupOptions) {
+		o.eviction = policy
+	}
+}
+
+// WithGroupTieBreak selects the order of elements with the same frequency in the results of Top and Frequent of every group.
+func WithGroupTieBreak(policy TieBreak) GroupOption {
+	return func(o *groupOptions) {
+		o.tieBreak = policy
+	}
+}
+
+// defaultMinGroupCapacity is the capacity of the summary of a new group without WithMinGroupCapacity.
+const defaultMinGroupCapacity = 8
+
+// NewGroupedSummary creates a new grouped summary whose groups share the given number of counters.
+// An error wrapping [ErrInvalidOption] is returned if the budget or options are invalid.
+func NewGroupedSummary[G, T cmp.Ordered](budget int, opts ...GroupOption) (*GroupedSummary[G, T], error) {
+	o := groupOptions{minCapacity: min(defaultMinGroupCapacity, budget)}
+
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	switch {
+	case budget <= 0:
+		return nil, fmt.Errorf("%w: budget must be positive, got %d", ErrInvalidOption, budget)
+	case o.minCapacity <= 0 || o.minCapacity > budget:
+		return nil, fmt.Errorf("%w: minimum group capacity must be in the range [1, %d], got %d", ErrInvalidOption, budget, o.minCapacity)
+	case o.eviction != GroupEvictionLeastRecent && o.eviction != GroupEvictionLeastHits:
+		return nil, fmt.Errorf("%w: unknown group eviction policy %d", ErrInvalidOption, o.eviction)
+	case !o.tieBreak.valid():
+		return nil, fmt.Errorf("%w: unknown tie-break policy %d", ErrInvalidOption, o.tieBreak)
+	}
+
+	s := &GroupedSummary[G, T]{
+		budget:      budget,
+		minCapacity: o.minCapacity,
+		eviction:    o.eviction,
+		tieBreak:    o.tieBreak,
+		groups:      make(map[G]*Node[summaryGroup[G, T]]),
+		recency:     NewList[summaryGroup[G, T]](),
+	}
+
+	if o.eviction == GroupEvictionLeastHits {
+		s.byHits = NewList[frequencyBucket[G]]()
+	}
+
+	return s, nil
+}
+
+// Hit increments the frequency for the given element of a group, then returns an approximation of its current frequency in the group.
+func (s *GroupedSummary[G, T]) Hit(g G, e T) Count {
+	return s.HitN(g, e, 1)
+}
+
+// HitN increments the frequency for the given element of a group by a weight, then returns an approximation of its current frequency in the group.
+// Weights that are not positive are ignored.
+func (s *GroupedSummary[G, T]) HitN(g G, e T, n int) Count {
+	if n <= 0 {
+		count, _ := s.Get(g, e)
+		return count
+	}
+
+	node, monitored := s.groups[g]
+	if monitored {
+		s.recency.PushTailNode(node)
+	} else {
+		node = s.add(g)
+	}
+
+	s.hits = saturatingAdd(s.hits, n)
+	count := node.Value.summary.HitN(e, n)
+	s.grow(node)
+
+	if node.Value.hits != nil {
+		incrementCounter(node.Value.hits, n)
+	}
+
+	return count
+}
+
+// add starts monitoring a group with the minimum capacity, evicting another group if the budget cannot fit one more group.
+func (s *GroupedSummary[G, T]) add(g G) *Node[summaryGroup[G, T]] {
+	if len(s.groups) == s.budget/s.minCapacity {
+		s.evict()
+	}
+
+	// the group is added first, so the shares of the other groups leave room for its minimum capacity.
+	s.recency.PushTail(summaryGroup[G, T]{key: g})
+	node := s.recency.Tail()
+	s.groups[g] = node
+
+	s.reclaim(s.minCapacity, node)
+
+	node.Value.summary = NewStreamSummary[T](s.minCapacity, WithTieBreak[T](s.tieBreak))
+	s.used += s.minCapacity
+
+	if s.byHits != nil {
+		// the group starts in a bucket of zero hits at the tail, which its first hit leaves.
+		unused := s.byHits.Tail()
+		if unused == nil || unused.Value.count > 0 {
+			unused = s.byHits.PushTail(frequencyBucket[G]{
+				counts: NewList[frequencyCounter[G]](),
+			}).Tail()
+		}
+
+		unused.Value.counts.PushTail(frequencyCounter[G]{key: g, bucket: unused})
+		node.Value.hits = unused.Value.counts.Tail()
+	}
+
+	return node
+}
+
+// evict stops monitoring a group according to the eviction policy.
+// With GroupEvictionLeastHits, the group is the head of the bucket with the fewest hits, which is the least recently hit of that bucket.
+func (s *GroupedSummary[G, T]) evict() {
+	victim := s.recency.Head()
+
+	if s.byHits != nil {
+		victim = s.groups[s.byHits.Tail().Value.counts.Head().Value.key]
+	}
+
+	s.remove(victim)
+}
+
+// Remove stops monitoring a group, returning its counters to the budget.
+// It reports whether the group was monitored.
+func (s *GroupedSummary[G, T]) Remove(g G) bool {
+	node, monitored := s.groups[g]
+	if monitored {
+		s.remove(node)
+	}
+
+	return monitored
+}
+
+func (s *GroupedSummary[G, T]) remove(node *Node[summaryGroup[G, T]]) {
+	delete(s.groups, node.Value.key)
+	node.RemoveSelf()
+
+	if hits := node.Value.hits; hits != nil {
+		bucket := hits.Value.bucket
+		hits.RemoveSelf()
+
+		if bucket.Value.counts.Empty() {
+			bucket.RemoveSelf()
+		}
+	}
+
+	s.used -= node.Value.summary.capacity
+	s.hits -= node.Value.summary.Hits()
+}
+
+// share is the capacity a group with the given hits is entitled to: the minimum capacity, plus a share of the counters left once every group has its minimum capacity.
+// The shares of all groups add up to at most the budget.
+func (s *GroupedSummary[G, T]) share(hits int) int {
+	if s.hits == 0 {
+		return s.minCapacity
+	}
+
+	spare := s.budget - s.minCapacity*len(s.groups)
+
+	return s.minCapacity + int(float64(spare)*float64(hits)/float64(s.hits))
+}
+
+// grow adds counters to the summary of a group once it is full, up to its share.
+// Counters are only taken back from other groups when the share is at least twice the capacity, so the groups are not scanned on every hit.
+func (s *GroupedSummary[G, T]) grow(node *Node[summaryGroup[G, T]]) {
+	summary := node.Value.summary
+
+	if len(summary.elements) < summary.capacity {
+		return
+	}
+
+	share := s.share(summary.Hits())
+	if share <= summary.capacity {
+		return
+	}
+
+	if s.budget-s.used < share-summary.capacity && share >= 2*summary.capacity {
+		s.reclaim(share-summary.capacity, node)
+	}
+
+	if capacity := min(share, summary.capacity+s.budget-s.used); capacity > summary.capacity {
+		s.used += capacity - summary.capacity
+		summary.resize(capacity)
+	}
+}
+
+// reclaim shrinks the groups above their share, other than the given group, until the given number of counters are unused or no group is above its share.
+// The least recently hit groups are shrunk first.
+func (s *GroupedSummary[G, T]) reclaim(counters int, except *Node[summaryGroup[G, T]]) {
+	for n := s.recency.Head(); n != nil && s.budget-s.used < counters; n = n.Next() {
+		summary := n.Value.summary
+
+		if n == except || summary == nil {
+			continue
+		}
+
+		capacity := max(s.share(summary.Hits()), summary.capacity-(counters-(s.budget-s.used)))
+		if capacity < summary.capacity {
+			s.used -= summary.capacity - capacity
+			summary.resize(capacity)
+		}
+	}
+}
+
+// Hits counts the total number of hits for all monitored groups.
+func (s *GroupedSummary[G, T]) Hits() int {
+	return s.hits
+}
+
+// GroupHits counts the number of hits for a group since it is monitored, or zero if it is not monitored.
+func (s *GroupedSummary[G, T]) GroupHits(g G) int {
+	if node, monitored := s.groups[g]; monitored {
+		return node.Value.summary.Hits()
+	}
+
+	return 0
+}
+
+// Capacity is the number of counters currently used by the summary of a group, or zero if it is not monitored.
+func (s *GroupedSummary[G, T]) Capacity(g G) int {
+	if node, monitored := s.groups[g]; monitored {
+		return node.Value.summary.capacity
+	}
+
+	return 0
+}
+
+// Groups returns the monitored groups in descending order of hits, with ties in ascending order.
+func (s *GroupedSummary[G, T]) Groups() []G {
+	groups := make([]G, 0, len(s.groups))
+
+	for g := range s.groups {
+		groups = append(groups, g)
+	}
+
+	slices.SortFunc(groups, func(a, b G) int {
+		return cmp.Or(cmp.Compare(s.GroupHits(b), s.GroupHits(a)), cmp.Compare(a, b))
+	})
+
+	return groups
+}
+
+// Get retrieves the approximated frequency for the given element of a group, with a bounds on the error.
+func (s *GroupedSummary[G, T]) Get(g G, e T) (Count, bool) {
+	if node, monitored := s.groups[g]; monitored {
+		return node.Value.summary.Get(e)
+	}
+
+	return Count{}, false
+}
+
+// Top finds the top-k elements of a group, as [StreamSummary.Top] does for the hits of the group.
+// A group that is not monitored has no elements, which are guaranteed.
+func (s *GroupedSummary[G, T]) Top(g G, k int) ([]T, bool, bool) {
+	if node, monitored := s.groups[g]; monitored {
+		return node.Value.summary.Top(k)
+	}
+
+	return []T{}, true, true
+}
+
+// Frequent finds the elements that contribute more than phi * GroupHits of the frequency of a group, as [StreamSummary.Frequent] does for the hits of the group.
+func (s *GroupedSummary[G, T]) Frequent(g G, phi float64) ([]T, bool) {
+	if node, monitored := s.groups[g]; monitored {
+		return node.Value.summary.Frequent(phi)
+	}
+
+	return []T{}, true
+}
+
+// MemoryUsage estimates the number of bytes used by the summaries of the groups, the map of monitored groups and the bytes referenced by string group keys.
+func (s *GroupedSummary[G, T]) MemoryUsage() int {
+	var g G
+	var node Node[summaryGroup[G, T]]
+
+	usage := int(unsafe.Sizeof(*s)) + int(unsafe.Sizeof(*s.recency)) + mapUsage(len(s.groups), unsafe.Sizeof(g), unsafe.Sizeof(&node)) +
+		mapKeyBytes(s.groups)
+
+	for _, n := range s.groups {
+		usage += int(unsafe.Sizeof(node)) + n.Value.summary.MemoryUsage()
+
+		if n.Value.hits != nil {
+			usage += int(unsafe.Sizeof(*n.Value.hits))
+		}
+	}
+
+	if s.byHits != nil {
+		var bucket Node[frequencyBucket[G]]
+
+		for b := s.byHits.Head(); b != nil; b = b.Next() {
+			usage += int(unsafe.Sizeof(bucket)) + int(unsafe.Sizeof(*b.Value.counts))
+		}
+	}
+
+	return usage
+}
+
+// resize changes the capacity of the summary.
+// Growing a full summary adds unused counters that start from its minimum count, since the elements they monitor may have been evicted before.
+// Shrinking drops unused counters first, then the counters with the lowest counts, so the elements that are no longer monitored
+// were counted up to at most the counts that remain, as if they had been evicted.
+func (s *StreamSummary[T]) resize(capacity int) {
+	if capacity > s.capacity && len(s.elements) == s.capacity {
+		s.floor = s.MinCount()
+	}
+
+	// the unused counters of the grown summary are allocated on demand by HitN.
+	s.capacity = max(s.capacity, capacity)
+
+	for ; s.capacity > capacity; s.capacity-- {
+		tail := s.buckets.Tail()
+
+		if len(s.elements) < s.capacity {
+			// drop an unused counter, unless it was never allocated.
+			if tail != nil && tail.Value.count == 0 {
+				tail.Value.counts.RemoveTail()
+
+				if tail.Value.counts.Empty() {
+					tail.RemoveSelf()
+				}
+			}
+
+			continue
+		}
+
+		dropped := tail.Value.counts.RemoveTail()
+
+		if tail.Value.counts.Empty() {
+			tail.RemoveSelf()
+		}
+
+		if dropped.count > 0 {
+			delete(s.elements, dropped.key)
+			s.evictions++
+
+			if s.onEvict != nil {
+				s.onEvict(dropped.key, Count{Count: dropped.count, Error: dropped.error})
+			}
+		}
+	}
+
+	if s.onTopKChange != nil {
+		s.updateTopK()
+	}
+}
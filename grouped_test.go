@@ -0,0 +1,235 @@
+package heavy_hitters
+
+import (
+	"github.com/stretchr/testify/require"
+	"math/rand"
+	"slices"
+	"testing"
+)
+
+func TestGroupedSummary(t *testing.T) {
+	s, err := NewGroupedSummary[string, string](16, WithMinGroupCapacity(4), WithGroupTieBreak(TieBreakKey))
+	require.NoError(t, err)
+
+	s.Hit("alice", "/login")
+	s.HitN("alice", "/search", 3)
+	s.HitN("bob", "/login", 2)
+	s.HitN("bob", "/login", 0)
+
+	require.Equal(t, 6, s.Hits())
+	require.Equal(t, 4, s.GroupHits("alice"))
+	require.Equal(t, 2, s.GroupHits("bob"))
+	require.Equal(t, 0, s.GroupHits("carol"))
+	require.Equal(t, []string{"alice", "bob"}, s.Groups())
+
+	top, _, _ := s.Top("alice", 2)
+	require.Equal(t, []string{"/search", "/login"}, top)
+
+	frequent, guaranteed := s.Frequent("bob", 0.5)
+	require.Equal(t, []string{"/login"}, frequent)
+	require.True(t, guaranteed)
+
+	count, found := s.Get("alice", "/search")
+	require.True(t, found)
+	require.Equal(t, Count{Count: 3}, count)
+
+	_, found = s.Get("bob", "/search")
+	require.False(t, found)
+
+	top, ordered, guaranteed := s.Top("carol", 2)
+	require.Empty(t, top)
+	require.True(t, ordered)
+	require.True(t, guaranteed)
+
+	require.Positive(t, s.MemoryUsage())
+
+	require.True(t, s.Remove("alice"))
+	require.False(t, s.Remove("alice"))
+	require.Equal(t, 2, s.Hits())
+	require.Equal(t, []string{"bob"}, s.Groups())
+}
+
+func TestGroupedSummary_Shares(t *testing.T) {
+	s, err := NewGroupedSummary[string, int](100, WithMinGroupCapacity(4))
+	require.NoError(t, err)
+
+	for i := 0; i < 1_000; i++ {
+		s.Hit("large", i%200)
+
+		if i%100 == 0 {
+			s.Hit("small", i%2)
+		}
+	}
+
+	require.Equal(t, 4, s.Capacity("small"))
+	require.Greater(t, s.Capacity("large"), 50)
+	require.LessOrEqual(t, s.Capacity("large")+s.Capacity("small"), 100)
+
+	// a new group takes its minimum capacity from the groups above their share.
+	s.Hit("new", 1)
+	require.Equal(t, 4, s.Capacity("new"))
+	require.LessOrEqual(t, s.Capacity("large")+s.Capacity("small")+s.Capacity("new"), 100)
+	require.ElementsMatch(t, []string{"large", "small", "new"}, s.Groups())
+}
+
+func TestGroupedSummary_Eviction(t *testing.T) {
+	for _, test := range []struct {
+		policy  GroupEviction
+		evicted string
+		hits    int
+	}{
+		{policy: GroupEvictionLeastRecent, evicted: "a", hits: 2},
+		{policy: GroupEvictionLeastHits, evicted: "b", hits: 6},
+	} {
+		t.Run(test.policy.String(), func(t *testing.T) {
+			s, err := NewGroupedSummary[string, int](8, WithMinGroupCapacity(4), WithGroupEviction(test.policy))
+			require.NoError(t, err)
+
+			s.HitN("a", 1, 5)
+			s.Hit("b", 1)
+			s.Hit("c", 1)
+
+			require.Equal(t, 0, s.GroupHits(test.evicted))
+			require.Len(t, s.Groups(), 2)
+			require.Equal(t, test.hits, s.Hits())
+
+			// an evicted group starts over.
+			s.Hit(test.evicted, 2)
+
+			count, found := s.Get(test.evicted, 2)
+			require.True(t, found)
+			require.Equal(t, Count{Count: 1}, count)
+		})
+	}
+}
+
+func TestGroupedSummary_EvictionLeastHits(t *testing.T) {
+	rng := rand.New(rand.NewSource(42))
+
+	s, err := NewGroupedSummary[int, int](40, WithMinGroupCapacity(4), WithGroupEviction(GroupEvictionLeastHits))
+	require.NoError(t, err)
+
+	for i := 0; i < 10_000; i++ {
+		g := rng.Intn(30)
+
+		if _, monitored := s.groups[g]; !monitored && len(s.groups) == 10 {
+			// the least recently hit of the groups with the fewest hits, scanning from the least recently hit.
+			victim := s.recency.Head()
+			for n := victim.Next(); n != nil; n = n.Next() {
+				if n.Value.summary.Hits() < victim.Value.summary.Hits() {
+					victim = n
+				}
+			}
+
+			s.HitN(g, 0, 1+rng.Intn(3))
+			require.Zero(t, s.GroupHits(victim.Value.key))
+
+			continue
+		}
+
+		s.HitN(g, 0, 1+rng.Intn(3))
+	}
+
+	for _, node := range s.groups {
+		require.Equal(t, node.Value.summary.Hits(), node.Value.hits.Value.count)
+	}
+}
+
+func TestGroupedSummary_Bounds(t *testing.T) {
+	// the smaller budget cannot fit every group, so groups are also evicted.
+	for _, budget := range []int{1_000, 400} {
+		testGroupedSummaryBounds(t, budget)
+	}
+}
+
+func testGroupedSummaryBounds(t *testing.T, budget int) {
+	generator := rand.NewZipf(rand.New(rand.NewSource(42)), 1.2, 2, 1_000)
+
+	s, err := NewGroupedSummary[int, uint64](budget, WithMinGroupCapacity(5), WithGroupEviction(GroupEvictionLeastHits))
+	require.NoError(t, err)
+
+	exact := make(map[int]map[uint64]int)
+
+	for i := 0; i < 100_000; i++ {
+		// a few large groups and many small ones.
+		g := int(generator.Uint64() % 100)
+
+		if s.GroupHits(g) == 0 {
+			exact[g] = make(map[uint64]int)
+		}
+
+		e := generator.Uint64()
+		exact[g][e]++
+		s.Hit(g, e)
+	}
+
+	used := 0
+
+	for _, g := range s.Groups() {
+		used += s.Capacity(g)
+
+		all, _, _ := s.Top(g, s.Capacity(g))
+		minimum, _ := s.Get(g, all[len(all)-1])
+
+		for e, actual := range exact[g] {
+			count, found := s.Get(g, e)
+
+			if found {
+				require.LessOrEqual(t, count.Count-count.Error, actual)
+				require.GreaterOrEqual(t, count.Count, actual)
+			} else {
+				require.LessOrEqual(t, actual, minimum.Count)
+			}
+		}
+
+		top, _, guaranteed := s.Top(g, 3)
+		if guaranteed {
+			for _, e := range top {
+				require.GreaterOrEqual(t, exact[g][e], kth(exact[g], len(top)))
+			}
+		}
+	}
+
+	require.LessOrEqual(t, used, budget)
+}
+
+// kth is the k-th largest count.
+func kth[T comparable](counts map[T]int, k int) int {
+	sorted := make([]int, 0, len(counts))
+
+	for _, c := range counts {
+		sorted = append(sorted, c)
+	}
+
+	slices.Sort(sorted)
+
+	return sorted[len(sorted)-k]
+}
+
+func TestStreamSummary_Resize(t *testing.T) {
+	var evicted []string
+	s := NewStreamSummary[string](2, WithOnEvict(func(key string, _ Count) { evicted = append(evicted, key) }))
+
+	s.HitN("a", 3)
+	s.HitN("b", 2)
+	s.Hit("c")
+
+	// c replaced b, so an element claiming a new counter may have been counted up to the minimum count.
+	s.resize(3)
+	count := s.Hit("b")
+	require.Equal(t, Count{Count: 4, Error: 3}, count)
+
+	s.resize(4)
+	require.Equal(t, Count{Count: 4, Error: 3}, s.Hit("d"))
+
+	s.resize(1)
+	require.Equal(t, []string{"b", "c", "a", "d"}, evicted)
+	require.Equal(t, 1, s.capacity)
+
+	top, _, _ := s.Top(2)
+	require.Len(t, top, 1)
+
+	count, found := s.Get(top[0])
+	require.True(t, found)
+	require.Equal(t, 4, count.Count)
+}
@@ -23,3 +23,12 @@ type HeavyHitters[T cmp.Ordered] interface {
 	// The second boolean is true iff the implementation guarantees they are the actual top-k, irrespective of the errors.
 	Top(k int) ([]T, bool, bool)
 }
+
+// WeightedHeavyHitters provides approximations for finding frequent and top-k elements, where each hit can carry a weight.
+// The frequency of an element is the sum of its weights, such as the number of bytes sent for a key.
+type WeightedHeavyHitters[T cmp.Ordered] interface {
+	HeavyHitters[T]
+	// HitN increments the frequency for the given element by a weight, then returns an approximation of the current frequency.
+	// Weights that are not positive are ignored, and frequencies saturate at math.MaxInt rather than overflowing.
+	HitN(T, int) Count
+}
@@ -0,0 +1,61 @@
+package ingest
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"io"
+)
+
+// Compression is a compression format detected by Decompress.
+type Compression int
+
+const (
+	// Uncompressed streams are read as they are.
+	Uncompressed Compression = iota
+	Gzip
+	Bzip2
+)
+
+func (c Compression) String() string {
+	switch c {
+	case Gzip:
+		return "gzip"
+	case Bzip2:
+		return "bzip2"
+	default:
+		return "none"
+	}
+}
+
+// The magic bytes at the start of compressed streams.
+var (
+	gzipMagic  = []byte{0x1f, 0x8b}
+	bzip2Magic = []byte("BZh")
+)
+
+// Decompress detects whether the stream is compressed with gzip or bzip2 from its magic bytes, and decompresses it on the fly.
+// Uncompressed streams are returned unchanged, apart from buffering.
+// Concatenated gzip members, as written by appending to a .gz file, are read as a single stream.
+func Decompress(r io.Reader) (io.Reader, Compression, error) {
+	buffered := bufio.NewReader(r)
+
+	// a short stream cannot be compressed, and any error will be returned again by the next read.
+	magic, _ := buffered.Peek(4)
+
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		decompressed, err := gzip.NewReader(buffered)
+		if err != nil {
+			return nil, Gzip, err
+		}
+
+		return decompressed, Gzip, nil
+	case len(magic) == 4 && bytes.HasPrefix(magic, bzip2Magic) && magic[3] >= '1' && magic[3] <= '9':
+		// the fourth byte is the block size, from 100k to 900k.
+		return bzip2.NewReader(buffered), Bzip2, nil
+	default:
+		return buffered, Uncompressed, nil
+	}
+}
@@ -0,0 +1,66 @@
+package ingest
+
+import (
+	"bytes"
+	"compress/gzip"
+	"github.com/stretchr/testify/require"
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+func decompress(t *testing.T, data []byte) (string, Compression) {
+	r, compression, err := Decompress(bytes.NewReader(data))
+	require.NoError(t, err)
+
+	decompressed, err := io.ReadAll(r)
+	require.NoError(t, err)
+
+	return string(decompressed), compression
+}
+
+func TestDecompress_Gzip(t *testing.T) {
+	var compressed bytes.Buffer
+
+	// appending to a .gz file concatenates gzip members.
+	for _, part := range []string{"a b a\n", "c a\n"} {
+		w := gzip.NewWriter(&compressed)
+		_, err := w.Write([]byte(part))
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+	}
+
+	decompressed, compression := decompress(t, compressed.Bytes())
+	require.Equal(t, "a b a\nc a\n", decompressed)
+	require.Equal(t, Gzip, compression)
+}
+
+func TestDecompress_Bzip2(t *testing.T) {
+	data, err := os.ReadFile("testdata/words.txt.bz2")
+	require.NoError(t, err)
+
+	decompressed, compression := decompress(t, data)
+	require.Equal(t, "a b a\nc a\n", decompressed)
+	require.Equal(t, Bzip2, compression)
+	require.Equal(t, "bzip2", compression.String())
+}
+
+func TestDecompress_Uncompressed(t *testing.T) {
+	for _, input := range []string{"", "a", "BZh", "BZhx and more", "a b a\nc a\n"} {
+		decompressed, compression := decompress(t, []byte(input))
+		require.Equal(t, input, decompressed)
+		require.Equal(t, Uncompressed, compression)
+	}
+}
+
+func TestDecompress_Corrupt(t *testing.T) {
+	_, _, err := Decompress(strings.NewReader("\x1f\x8bnot gzip"))
+	require.Error(t, err)
+
+	r, _, err := Decompress(strings.NewReader("BZh9not bzip2"))
+	require.NoError(t, err)
+
+	_, err = io.ReadAll(r)
+	require.Error(t, err)
+}
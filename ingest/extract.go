@@ -0,0 +1,495 @@
+package ingest
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	hh "heavy-hitters"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Format is the encoding of a stream of structured records.
+type Format int
+
+const (
+	// CSV records are lines of comma-separated values, quoted as described by RFC 4180.
+	CSV Format = iota
+	// TSV records are lines of tab-separated values, without quoting.
+	TSV
+	// JSONLines records are JSON values, one per line.
+	JSONLines
+)
+
+// ParseFormat parses the name of a format, as returned by [Format.String].
+func ParseFormat(name string) (Format, error) {
+	for _, f := range []Format{CSV, TSV, JSONLines} {
+		if f.String() == name {
+			return f, nil
+		}
+	}
+
+	return 0, fmt.Errorf("unknown record format %q", name)
+}
+
+func (f Format) String() string {
+	switch f {
+	case CSV:
+		return "csv"
+	case TSV:
+		return "tsv"
+	case JSONLines:
+		return "jsonl"
+	default:
+		return fmt.Sprintf("Format(%d)", int(f))
+	}
+}
+
+// Extractor turns each record of a stream of structured records into a key, and optionally a weight.
+//
+// Fields of CSV and TSV records are selected by column name, which requires a header, or by 1-based column index.
+// Fields of JSON records are selected by simple paths such as "$.request.path" or "$.items[0].id"; the leading "$." is optional.
+// A key built from several fields joins their values with a separator.
+type Extractor struct {
+	format    Format
+	fields    []string
+	weight    string
+	separator string
+	header    bool
+	// The parsed paths of the fields followed by the weight, only for JSON records.
+	paths [][]pathElement
+}
+
+// ExtractorOption configures an Extractor.
+type ExtractorOption func(*Extractor)
+
+// WithWeight weights the hit of each record by the numeric value of a field, such as the number of bytes of a response.
+// Fractional weights are rounded to the nearest integer.
+func WithWeight(field string) ExtractorOption {
+	return func(e *Extractor) {
+		e.weight = field
+	}
+}
+
+// WithSeparator sets the separator between the values of a key built from several fields, "|" by default.
+func WithSeparator(separator string) ExtractorOption {
+	return func(e *Extractor) {
+		e.separator = separator
+	}
+}
+
+// WithHeader treats the first record of a CSV or TSV stream as a header naming the columns.
+func WithHeader() ExtractorOption {
+	return func(e *Extractor) {
+		e.header = true
+	}
+}
+
+// NewExtractor creates an extractor of keys built from the given fields of each record.
+func NewExtractor(format Format, fields []string, opts ...ExtractorOption) (*Extractor, error) {
+	e := &Extractor{
+		format:    format,
+		fields:    fields,
+		separator: "|",
+	}
+
+	for _, opt := range opts {
+		opt(e)
+	}
+
+	if len(fields) == 0 {
+		return nil, errors.New("keys must be built from at least one field")
+	}
+
+	for _, field := range e.selectors() {
+		switch format {
+		case CSV, TSV:
+			if index, err := strconv.Atoi(field); err == nil && index <= 0 {
+				return nil, fmt.Errorf("column indices start at 1, got %d", index)
+			} else if err != nil && !e.header {
+				return nil, fmt.Errorf("column %q can only be selected by name with a header", field)
+			}
+		case JSONLines:
+			path, err := parsePath(field)
+			if err != nil {
+				return nil, err
+			}
+
+			e.paths = append(e.paths, path)
+		default:
+			return nil, fmt.Errorf("unknown record format %d", int(format))
+		}
+	}
+
+	return e, nil
+}
+
+// selectors lists the fields of the key followed by the weight field, if any.
+func (e *Extractor) selectors() []string {
+	if e.weight == "" {
+		return e.fields
+	}
+
+	return append(e.fields[:len(e.fields):len(e.fields)], e.weight)
+}
+
+// Extract reads the stream of records with the extractor and hits the summary with the key and weight of every record.
+// Malformed records, such as records missing a field or with an invalid weight, are skipped and counted in the stats.
+// The stats cover everything processed up to the point an error occurred, if any.
+func Extract(r io.Reader, summary hh.WeightedHeavyHitters[string], extractor *Extractor) (Stats, error) {
+	var stats Stats
+
+	counter := &countingReader{reader: r, count: &stats.Bytes}
+
+	err := extractor.extract(counter, func(values []string) {
+		key, weight, ok := extractor.record(values)
+		if !ok {
+			stats.Skipped++
+			return
+		}
+
+		stats.Tokens++
+		summary.HitN(key, weight)
+	})
+
+	return stats, err
+}
+
+// extract reads the stream until EOF, calling emit with the values of the selectors of each record, or nil if the record is malformed.
+func (e *Extractor) extract(r io.Reader, emit func(values []string)) error {
+	if e.format == JSONLines {
+		return e.extractJSON(r, emit)
+	}
+
+	return e.extractDelimited(r, emit)
+}
+
+func (e *Extractor) extractDelimited(r io.Reader, emit func(values []string)) error {
+	var next func() ([]string, error)
+
+	if e.format == CSV {
+		next = csvRows(r)
+	} else {
+		next = tsvRows(r)
+	}
+
+	var columns []int
+
+	if !e.header {
+		columns = e.columns(nil)
+	}
+
+	values := make([]string, 0, len(e.fields)+1)
+
+	for {
+		row, err := next()
+
+		switch {
+		case err == io.EOF:
+			return nil
+		case err == errMalformed && columns != nil:
+			emit(nil)
+			continue
+		case err != nil:
+			return err
+		}
+
+		if columns == nil {
+			if columns = e.columns(row); columns == nil {
+				return fmt.Errorf("the header %q does not name all the columns %q", row, e.selectors())
+			}
+
+			continue
+		}
+
+		values = values[:0]
+
+		for _, column := range columns {
+			if column >= len(row) {
+				values = nil
+				break
+			}
+
+			values = append(values, row[column])
+		}
+
+		emit(values)
+	}
+}
+
+// errMalformed reports a record that cannot be parsed, after which the following records can still be read.
+var errMalformed = errors.New("malformed record")
+
+// MaxRecordSize is the maximum number of bytes in a CSV record.
+// Unlike the lines of other formats, a CSV record cannot be truncated, since a quoted field may span several lines.
+const MaxRecordSize = 1024 * 1024
+
+// ErrRecordTooLong is returned when a CSV record exceeds MaxRecordSize, which stops the extraction.
+var ErrRecordTooLong = fmt.Errorf("CSV record longer than %d bytes", MaxRecordSize)
+
+// csvRows returns a function reading the next row of a CSV stream, quoted as described by RFC 4180.
+func csvRows(r io.Reader) func() ([]string, error) {
+	limiter := &recordLimiter{reader: r}
+	reader := csv.NewReader(limiter)
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+
+	return func() ([]string, error) {
+		limiter.start = reader.InputOffset()
+		row, err := reader.Read()
+
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			return nil, errMalformed
+		}
+
+		return row, err
+	}
+}
+
+// recordLimiter fails reads once the record being read by a CSV reader exceeds MaxRecordSize,
+// since encoding/csv buffers whole records of any length.
+type recordLimiter struct {
+	reader io.Reader
+	// The number of bytes read, and the offset of the start of the current record.
+	read, start int64
+}
+
+func (l *recordLimiter) Read(p []byte) (int, error) {
+	if l.read-l.start > MaxRecordSize {
+		return 0, ErrRecordTooLong
+	}
+
+	n, err := l.reader.Read(p)
+	l.read += int64(n)
+
+	return n, err
+}
+
+// tsvRows returns a function reading the next row of a TSV stream.
+// Fields of TSV records cannot contain tabs or line breaks, so quotes are kept as is.
+func tsvRows(r io.Reader) func() ([]string, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 4096), MaxTokenSize)
+	scanner.Split(truncating(bufio.ScanLines))
+
+	return func() ([]string, error) {
+		if !scanner.Scan() {
+			if err := scanner.Err(); err != nil {
+				return nil, err
+			}
+
+			return nil, io.EOF
+		}
+
+		return strings.Split(scanner.Text(), "\t"), nil
+	}
+}
+
+// columns resolves the 0-based column of every selector using the header, or nil if a column is not named by the header.
+func (e *Extractor) columns(header []string) []int {
+	selectors := e.selectors()
+	columns := make([]int, len(selectors))
+
+	for i, field := range selectors {
+		if index, err := strconv.Atoi(field); err == nil {
+			columns[i] = index - 1
+			continue
+		}
+
+		columns[i] = -1
+
+		for j, name := range header {
+			if name == field {
+				columns[i] = j
+				break
+			}
+		}
+
+		if columns[i] < 0 {
+			return nil
+		}
+	}
+
+	return columns
+}
+
+func (e *Extractor) extractJSON(r io.Reader, emit func(values []string)) error {
+	values := make([]string, 0, len(e.paths))
+
+	return scan(r, bufio.ScanLines, func(line []byte) {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			return
+		}
+
+		decoder := json.NewDecoder(bytes.NewReader(line))
+		decoder.UseNumber()
+
+		var record any
+
+		if err := decoder.Decode(&record); err != nil {
+			emit(nil)
+			return
+		}
+
+		// a line holding more than a single value is not a valid record.
+		if _, err := decoder.Token(); err != io.EOF {
+			emit(nil)
+			return
+		}
+
+		values = values[:0]
+
+		for _, path := range e.paths {
+			value, ok := jsonString(lookup(record, path))
+			if !ok {
+				emit(nil)
+				return
+			}
+
+			values = append(values, value)
+		}
+
+		emit(values)
+	})
+}
+
+// record builds the key and weight of a record from the values of its selectors.
+// The boolean is false iff the record is malformed.
+func (e *Extractor) record(values []string) (string, int, bool) {
+	if values == nil {
+		return "", 0, false
+	}
+
+	weight := 1
+
+	if e.weight != "" {
+		f, err := strconv.ParseFloat(strings.TrimSpace(values[len(values)-1]), 64)
+		if err != nil || f < 0 || f >= math.MaxInt || math.IsNaN(f) {
+			return "", 0, false
+		}
+
+		weight = int(math.Round(f))
+		values = values[:len(values)-1]
+	}
+
+	if len(values) == 1 {
+		return values[0], weight, true
+	}
+
+	return strings.Join(values, e.separator), weight, true
+}
+
+// pathElement selects either a member of a JSON object by name, or an element of a JSON array by index.
+type pathElement struct {
+	name  string
+	index int
+}
+
+// parsePath parses a simple JSON path made of names and array indices, such as "$.items[0].id".
+func parsePath(path string) ([]pathElement, error) {
+	rest := path
+
+	if strings.HasPrefix(rest, "$") {
+		rest = rest[1:]
+
+		if rest != "" && rest[0] != '.' && rest[0] != '[' {
+			return nil, fmt.Errorf("invalid JSON path %q", path)
+		}
+	} else if rest == "" {
+		return nil, errors.New("empty JSON path")
+	} else {
+		rest = "." + rest
+	}
+
+	var elements []pathElement
+
+	for rest != "" {
+		if rest[0] == '[' {
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated index in JSON path %q", path)
+			}
+
+			index, err := strconv.Atoi(rest[1:end])
+			if err != nil || index < 0 {
+				return nil, fmt.Errorf("invalid index %q in JSON path %q", rest[1:end], path)
+			}
+
+			elements = append(elements, pathElement{index: index})
+			rest = rest[end+1:]
+
+			continue
+		}
+
+		if rest[0] != '.' {
+			return nil, fmt.Errorf("invalid JSON path %q", path)
+		}
+
+		rest = rest[1:]
+		end := strings.IndexAny(rest, ".[")
+
+		if end < 0 {
+			end = len(rest)
+		}
+
+		if end == 0 {
+			return nil, fmt.Errorf("empty name in JSON path %q", path)
+		}
+
+		elements = append(elements, pathElement{name: rest[:end], index: -1})
+		rest = rest[end:]
+	}
+
+	return elements, nil
+}
+
+// lookup finds the value at the path in a decoded JSON value, or nil if there is none.
+func lookup(value any, path []pathElement) any {
+	for _, element := range path {
+		switch v := value.(type) {
+		case map[string]any:
+			if element.index >= 0 {
+				return nil
+			}
+
+			value = v[element.name]
+		case []any:
+			if element.index < 0 || element.index >= len(v) {
+				return nil
+			}
+
+			value = v[element.index]
+		default:
+			return nil
+		}
+	}
+
+	return value
+}
+
+// jsonString formats a decoded JSON value as a key.
+// Strings are used as is, while objects and arrays are encoded as JSON.
+// The boolean is false iff the value is missing or null.
+func jsonString(value any) (string, bool) {
+	switch v := value.(type) {
+	case nil:
+		return "", false
+	case string:
+		return v, true
+	case json.Number:
+		return v.String(), true
+	case bool:
+		return strconv.FormatBool(v), true
+	default:
+		b, err := json.Marshal(v)
+
+		return string(b), err == nil
+	}
+}
@@ -0,0 +1,135 @@
+package ingest
+
+import (
+	"github.com/stretchr/testify/require"
+	hh "heavy-hitters"
+	"strings"
+	"testing"
+)
+
+func extract(t *testing.T, input string, format Format, fields []string, opts ...ExtractorOption) (map[string]int, Stats) {
+	t.Helper()
+
+	extractor, err := NewExtractor(format, fields, opts...)
+	require.NoError(t, err)
+
+	summary := hh.NewNaive[string]()
+
+	stats, err := Extract(strings.NewReader(input), summary, extractor)
+	require.NoError(t, err)
+
+	counts := make(map[string]int)
+	top, _, _ := summary.Top(summary.Hits())
+
+	for _, key := range top {
+		count, _ := summary.Get(key)
+		counts[key] = count.Count
+	}
+
+	return counts, stats
+}
+
+func TestExtract_CSV(t *testing.T) {
+	input := "method,path,bytes\nGET,/a,100\nPOST,/b,20\nGET,/a,1.6\nGET,/c\"d,3\nGET\n"
+
+	counts, stats := extract(t, input, CSV, []string{"path"}, WithHeader())
+	require.Equal(t, map[string]int{"/a": 2, "/b": 1}, counts)
+	require.Equal(t, Stats{Bytes: int64(len(input)), Tokens: 3, Skipped: 2}, stats)
+
+	counts, _ = extract(t, input, CSV, []string{"method", "2"}, WithHeader(), WithWeight("bytes"), WithSeparator(" "))
+	require.Equal(t, map[string]int{"GET /a": 102, "POST /b": 20}, counts)
+
+	counts, stats = extract(t, "GET,/a\nPOST,/b\nGET,/a\n", CSV, []string{"1"})
+	require.Equal(t, map[string]int{"GET": 2, "POST": 1}, counts)
+	require.Equal(t, int64(3), stats.Tokens)
+}
+
+func TestExtract_TSV(t *testing.T) {
+	input := "a\t\"quoted\"\t1\nb\tc\t-1\nb\tc\tx\nb\tc\t2\n"
+
+	counts, stats := extract(t, input, TSV, []string{"1", "2"}, WithWeight("3"))
+	require.Equal(t, map[string]int{`a|"quoted"`: 1, "b|c": 2}, counts)
+	require.Equal(t, int64(2), stats.Skipped)
+}
+
+func TestExtract_JSONLines(t *testing.T) {
+	input := `{"request": {"path": "/a", "tags": ["x", "y"]}, "status": 200, "bytes": 10}
+{"request": {"path": "/b", "tags": []}, "status": 404, "bytes": 5}
+
+{"request": {"path": "/a"}, "status": 200, "bytes": 7}
+not json
+{"request": null, "status": 500}
+{"request": {"path": "/a"}} {"request": {"path": "/b"}}
+`
+
+	counts, stats := extract(t, input, JSONLines, []string{"$.request.path"})
+	require.Equal(t, map[string]int{"/a": 2, "/b": 1}, counts)
+	require.Equal(t, Stats{Bytes: int64(len(input)), Tokens: 3, Skipped: 3}, stats)
+
+	counts, _ = extract(t, input, JSONLines, []string{"request.path", "status"}, WithWeight("$.bytes"))
+	require.Equal(t, map[string]int{"/a|200": 17, "/b|404": 5}, counts)
+
+	counts, _ = extract(t, input, JSONLines, []string{"$.request.tags[1]"})
+	require.Equal(t, map[string]int{"y": 1}, counts)
+
+	counts, _ = extract(t, input, JSONLines, []string{"$.request.tags"})
+	require.Equal(t, map[string]int{`["x","y"]`: 1, `[]`: 1}, counts)
+}
+
+func TestNewExtractor_Invalid(t *testing.T) {
+	invalid := map[string]struct {
+		format Format
+		fields []string
+		opts   []ExtractorOption
+	}{
+		"no fields":           {CSV, nil, nil},
+		"zero column":         {CSV, []string{"0"}, nil},
+		"name without header": {TSV, []string{"path"}, nil},
+		"weight name":         {CSV, []string{"1"}, []ExtractorOption{WithWeight("bytes")}},
+		"empty path":          {JSONLines, []string{""}, nil},
+		"empty name":          {JSONLines, []string{"$.a..b"}, nil},
+		"invalid index":       {JSONLines, []string{"$.a[-1]"}, nil},
+		"unterminated index":  {JSONLines, []string{"$.a[1"}, nil},
+		"invalid root":        {JSONLines, []string{"$a"}, nil},
+		"unknown format":      {Format(42), []string{"1"}, nil},
+	}
+
+	for name, test := range invalid {
+		t.Run(name, func(t *testing.T) {
+			_, err := NewExtractor(test.format, test.fields, test.opts...)
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestExtract_MissingColumn(t *testing.T) {
+	extractor, err := NewExtractor(CSV, []string{"path"}, WithHeader())
+	require.NoError(t, err)
+
+	_, err = Extract(strings.NewReader("method,bytes\nGET,1\n"), hh.NewNaive[string](), extractor)
+	require.Error(t, err)
+}
+
+func TestParseFormat(t *testing.T) {
+	for _, f := range []Format{CSV, TSV, JSONLines} {
+		parsed, err := ParseFormat(f.String())
+		require.NoError(t, err)
+		require.Equal(t, f, parsed)
+	}
+
+	_, err := ParseFormat("xml")
+	require.Error(t, err)
+}
+
+func TestExtract_CSVRecordTooLong(t *testing.T) {
+	extractor, err := NewExtractor(CSV, []string{"1"})
+	require.NoError(t, err)
+
+	input := "a,1\n\"" + strings.Repeat("x\n", MaxRecordSize) + "\",2\nb,3\n"
+
+	summary := hh.NewNaive[string]()
+	stats, err := Extract(strings.NewReader(input), summary, extractor)
+	require.ErrorIs(t, err, ErrRecordTooLong)
+	require.Equal(t, int64(1), stats.Tokens)
+	require.Less(t, stats.Bytes, int64(2*MaxRecordSize))
+}
@@ -0,0 +1,188 @@
+package ingest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"time"
+)
+
+// Follower reads a file as it grows, like tail -F, until its context is done.
+//
+// It reads the file from the start and then waits for more data at its end.
+// When the file is rotated, by renaming or removing it and creating a new file at its path, the rest of the old file is read before the new one.
+// When the file is truncated, it is read again from the start.
+// Like tail -F, a truncation followed by writes beyond the previous end of the file, such as by the copytruncate rotation of logrotate,
+// is detected by a change of the first bytes of the file whenever its size or modification time changes.
+// A truncated file that is rewritten with the same first bytes is not detected.
+// The file may not exist yet, in which case the follower waits for it to be created.
+type Follower struct {
+	ctx  context.Context
+	path string
+	poll time.Duration
+	file *os.File
+	// offset is the number of bytes read from the current file, which is larger than its size after a truncation.
+	offset int64
+	// fingerprint holds the first bytes read from the current file,
+	// which are compared to the start of the file when its size or modification time differ from the latest check.
+	fingerprint []byte
+	size        int64
+	modTime     time.Time
+	rotations   int
+}
+
+// fingerprintSize is the maximum number of bytes at the start of a followed file that identify its contents.
+const fingerprintSize = 256
+
+// Follow creates a follower of the file at path, which checks for new data every poll interval.
+// Reading returns io.EOF once the context is done, so a stream is ingested until the context is cancelled.
+func Follow(ctx context.Context, path string, poll time.Duration) *Follower {
+	return &Follower{ctx: ctx, path: path, poll: poll}
+}
+
+// Rotations is the number of times the file was rotated or truncated.
+func (f *Follower) Rotations() int {
+	return f.rotations
+}
+
+// Read reads the next data of the file, blocking until some is available or the context is done.
+func (f *Follower) Read(p []byte) (int, error) {
+	for {
+		if f.ctx.Err() != nil {
+			return 0, io.EOF
+		}
+
+		if f.file == nil {
+			file, err := os.Open(f.path)
+			if errors.Is(err, fs.ErrNotExist) {
+				if !f.wait() {
+					return 0, io.EOF
+				}
+
+				continue
+			} else if err != nil {
+				return 0, err
+			}
+
+			f.file = file
+			f.rewind()
+		}
+
+		n, err := f.file.Read(p)
+		if n > 0 {
+			if missing := fingerprintSize - len(f.fingerprint); missing > 0 && f.offset == int64(len(f.fingerprint)) {
+				f.fingerprint = append(f.fingerprint, p[:min(n, missing)]...)
+			}
+
+			f.offset += int64(n)
+
+			return n, nil
+		}
+
+		if err != nil && err != io.EOF {
+			return 0, err
+		}
+
+		// the file is checked after waiting rather than before, so a truncation is noticed before reading the data written after it.
+		if !f.wait() {
+			return 0, io.EOF
+		}
+
+		switch changed, err := f.changed(); {
+		case err != nil:
+			return 0, err
+		case changed:
+			f.rotations++
+		}
+	}
+}
+
+// rewind starts reading the current file from its start.
+func (f *Follower) rewind() {
+	f.offset = 0
+	f.fingerprint = f.fingerprint[:0]
+	f.size, f.modTime = 0, time.Time{}
+}
+
+// changed checks whether the file at the end of its data was rotated or truncated, and switches to the start of the new data.
+func (f *Follower) changed() (bool, error) {
+	current, err := f.file.Stat()
+	if err != nil {
+		return false, err
+	}
+
+	truncated := current.Size() < f.offset
+
+	if !truncated && (current.Size() != f.size || !current.ModTime().Equal(f.modTime)) {
+		f.size, f.modTime = current.Size(), current.ModTime()
+
+		prefix := make([]byte, len(f.fingerprint))
+
+		n, err := f.file.ReadAt(prefix, 0)
+		if err != nil && err != io.EOF {
+			return false, err
+		}
+
+		truncated = !bytes.Equal(prefix[:n], f.fingerprint)
+	}
+
+	if truncated {
+		if _, err := f.file.Seek(0, io.SeekStart); err != nil {
+			return false, err
+		}
+
+		f.rewind()
+
+		return true, nil
+	}
+
+	// the path is missing between renaming the old file and creating the new one, so the old file is kept until then.
+	latest, err := os.Stat(f.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	if os.SameFile(current, latest) {
+		return false, nil
+	}
+
+	// data may have been written to the old file after the last read, before it was rotated.
+	if current.Size() > f.offset {
+		return false, nil
+	}
+
+	err = f.file.Close()
+	f.file = nil
+
+	return true, err
+}
+
+// wait sleeps for the poll interval, returning false if the context is done first.
+func (f *Follower) wait() bool {
+	timer := time.NewTimer(f.poll)
+	defer timer.Stop()
+
+	select {
+	case <-f.ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// Close closes the file being followed.
+func (f *Follower) Close() error {
+	if f.file == nil {
+		return nil
+	}
+
+	err := f.file.Close()
+	f.file = nil
+
+	return err
+}
@@ -0,0 +1,130 @@
+package ingest
+
+import (
+	"context"
+	"github.com/stretchr/testify/require"
+	hh "heavy-hitters"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func appendFile(t *testing.T, path string, data string) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	require.NoError(t, err)
+	_, err = f.WriteString(data)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+}
+
+func TestFollow(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+	appendFile(t, path, "a\nb\n")
+
+	summary := hh.NewSynchronized[string](hh.NewStreamSummary[string](10))
+	ctx, cancel := context.WithCancel(context.Background())
+	follower := Follow(ctx, path, time.Millisecond)
+
+	type result struct {
+		stats Stats
+		err   error
+	}
+
+	done := make(chan result)
+
+	go func() {
+		stats, err := Ingest(follower, summary, Lines())
+		done <- result{stats, err}
+	}()
+
+	waitHits := func(hits int) {
+		require.Eventually(t, func() bool { return summary.Hits() == hits }, 5*time.Second, time.Millisecond)
+	}
+
+	waitHits(2)
+
+	// growing the file.
+	appendFile(t, path, "a\n")
+	waitHits(3)
+
+	// rotating the file, with a line written to the old file after the rename.
+	require.NoError(t, os.Rename(path, path+".1"))
+	appendFile(t, path+".1", "c\n")
+	appendFile(t, path, "a\nd\n")
+	waitHits(6)
+
+	// truncating the file, which is then shorter than what was read from it even after the next line.
+	require.NoError(t, os.Truncate(path, 0))
+	appendFile(t, path, "e\n")
+	waitHits(7)
+
+	cancel()
+
+	r := <-done
+	require.NoError(t, r.err)
+	require.Equal(t, Stats{Bytes: 14, Tokens: 7}, r.stats)
+	require.Equal(t, 2, follower.Rotations())
+	require.NoError(t, follower.Close())
+
+	for key, count := range map[string]int{"a": 3, "b": 1, "c": 1, "d": 1, "e": 1} {
+		c, ok := summary.Get(key)
+		require.True(t, ok)
+		require.Equal(t, count, c.Count, key)
+	}
+}
+
+func TestFollow_Missing(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	summary := hh.NewSynchronized[string](hh.NewStreamSummary[string](10))
+	done := make(chan Stats)
+
+	go func() {
+		stats, _ := Ingest(Follow(ctx, path, time.Millisecond), summary, Lines())
+		done <- stats
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	appendFile(t, path, "a\n")
+	require.Eventually(t, func() bool { return summary.Hits() == 1 }, 5*time.Second, time.Millisecond)
+
+	cancel()
+	require.Equal(t, Stats{Bytes: 2, Tokens: 1}, <-done)
+}
+
+func TestFollow_CopyTruncate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+	appendFile(t, path, "a\nb\n")
+
+	summary := hh.NewSynchronized[string](hh.NewStreamSummary[string](10))
+	ctx, cancel := context.WithCancel(context.Background())
+	follower := Follow(ctx, path, 10*time.Millisecond)
+	done := make(chan Stats)
+
+	go func() {
+		stats, _ := Ingest(follower, summary, Lines())
+		done <- stats
+	}()
+
+	require.Eventually(t, func() bool { return summary.Hits() == 2 }, 5*time.Second, time.Millisecond)
+
+	// truncating the file and writing past its previous end before the follower checks it again.
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_TRUNC, 0)
+	require.NoError(t, err)
+	_, err = f.WriteString("cc\ndd\nee\n")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	require.Eventually(t, func() bool { return summary.Hits() == 5 }, 5*time.Second, time.Millisecond)
+
+	cancel()
+	require.Equal(t, Stats{Bytes: 13, Tokens: 5}, <-done)
+	require.Equal(t, 1, follower.Rotations())
+
+	for _, key := range []string{"a", "b", "cc", "dd", "ee"} {
+		_, ok := summary.Get(key)
+		require.True(t, ok, key)
+	}
+}
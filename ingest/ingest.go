@@ -0,0 +1,58 @@
+// Package ingest streams the contents of a reader into a heavy hitters summary.
+//
+// A Tokenizer splits the stream into tokens, each of which is counted as a hit of the summary.
+// An Extractor instead reads structured records, such as CSV or JSON lines, and counts a key built from the fields of each record.
+// The stream is read incrementally, so memory stays bounded regardless of the size of the stream.
+// Decompress detects gzip and bzip2 streams and decompresses them on the fly.
+// A Follower turns a growing log file into such a stream, surviving rotation and truncation like tail -F.
+package ingest
+
+import (
+	hh "heavy-hitters"
+	"io"
+)
+
+// Stats reports the amount of data processed from a stream.
+type Stats struct {
+	// Bytes is the number of bytes read from the stream.
+	Bytes int64
+	// Tokens is the number of tokens, or records, counted by the summary.
+	Tokens int64
+	// Skipped is the number of malformed records that were not counted.
+	Skipped int64
+}
+
+// Add accumulates the stats of another stream.
+func (s *Stats) Add(other Stats) {
+	s.Bytes += other.Bytes
+	s.Tokens += other.Tokens
+	s.Skipped += other.Skipped
+}
+
+// Ingest splits the stream into tokens with the tokenizer and hits the summary once for every token.
+// The stats cover everything processed up to the point an error occurred, if any.
+func Ingest(r io.Reader, summary hh.HeavyHitters[string], tokenizer Tokenizer) (Stats, error) {
+	var stats Stats
+
+	counter := &countingReader{reader: r, count: &stats.Bytes}
+
+	err := tokenizer.Tokenize(counter, func(token string) {
+		stats.Tokens++
+		summary.Hit(token)
+	})
+
+	return stats, err
+}
+
+// countingReader counts the bytes read from the underlying reader.
+type countingReader struct {
+	reader io.Reader
+	count  *int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.reader.Read(p)
+	*c.count += int64(n)
+
+	return n, err
+}
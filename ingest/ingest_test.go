@@ -0,0 +1,21 @@
+package ingest
+
+import (
+	"github.com/stretchr/testify/require"
+	hh "heavy-hitters"
+	"strings"
+	"testing"
+)
+
+func TestIngest(t *testing.T) {
+	summary := hh.NewStreamSummary[string](4)
+	input := "b a\nc a\na\n"
+
+	stats, err := Ingest(strings.NewReader(input), summary, Words())
+	require.NoError(t, err)
+	require.Equal(t, Stats{Bytes: int64(len(input)), Tokens: 5}, stats)
+
+	top, _, _ := summary.Top(1)
+	require.Equal(t, []string{"a"}, top)
+	require.Equal(t, 5, summary.Hits())
+}
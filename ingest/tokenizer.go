@@ -0,0 +1,171 @@
+package ingest
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// MaxTokenSize is the maximum number of bytes in a token.
+// Longer tokens, such as very long lines, are truncated to their first MaxTokenSize bytes so memory stays bounded.
+const MaxTokenSize = 64 * 1024
+
+// Tokenizer splits a stream into tokens.
+type Tokenizer interface {
+	// Tokenize reads the stream until EOF, calling emit for each token in the order they appear in the stream.
+	Tokenize(r io.Reader, emit func(token string)) error
+}
+
+// TokenizerFunc adapts a function to the Tokenizer interface.
+type TokenizerFunc func(r io.Reader, emit func(token string)) error
+
+// Tokenize calls f(r, emit).
+func (f TokenizerFunc) Tokenize(r io.Reader, emit func(token string)) error {
+	return f(r, emit)
+}
+
+// Lines emits each line of the stream without its line ending.
+func Lines() Tokenizer {
+	return TokenizerFunc(func(r io.Reader, emit func(string)) error {
+		return scan(r, bufio.ScanLines, func(token []byte) {
+			emit(string(token))
+		})
+	})
+}
+
+// Words emits each whitespace-separated word of the stream.
+func Words() Tokenizer {
+	return TokenizerFunc(func(r io.Reader, emit func(string)) error {
+		return scan(r, bufio.ScanWords, func(token []byte) {
+			emit(string(token))
+		})
+	})
+}
+
+// WordNGrams emits every sequence of n consecutive whitespace-separated words, joined by a single space.
+func WordNGrams(n int) (Tokenizer, error) {
+	if n <= 0 {
+		return nil, fmt.Errorf("n-grams must have a positive size, got %d", n)
+	}
+
+	return TokenizerFunc(func(r io.Reader, emit func(string)) error {
+		window := make([]string, 0, n)
+
+		return scan(r, bufio.ScanWords, func(token []byte) {
+			if len(window) == n {
+				window = append(window[:0], window[1:]...)
+			}
+
+			window = append(window, string(token))
+
+			if len(window) == n {
+				emit(strings.Join(window, " "))
+			}
+		})
+	}), nil
+}
+
+// ByteNGrams emits every sequence of n consecutive bytes in the stream.
+func ByteNGrams(n int) (Tokenizer, error) {
+	if n <= 0 || n > MaxTokenSize {
+		return nil, fmt.Errorf("n-grams must have a size in the range [1, %d], got %d", MaxTokenSize, n)
+	}
+
+	return TokenizerFunc(func(r io.Reader, emit func(string)) error {
+		// the buffer keeps the last n-1 bytes of the previous read at its start.
+		buffer := make([]byte, max(n, 4096)+n-1)
+		kept := 0
+
+		for {
+			read, err := r.Read(buffer[kept:])
+			end := kept + read
+
+			for i := 0; i+n <= end; i++ {
+				emit(string(buffer[i : i+n]))
+			}
+
+			if end >= n-1 {
+				kept = copy(buffer, buffer[end-(n-1):end])
+			} else {
+				kept = end
+			}
+
+			if err == io.EOF {
+				return nil
+			} else if err != nil {
+				return err
+			}
+		}
+	}), nil
+}
+
+// Regexp emits the given capture group of every match of the regular expression in each line of the stream.
+// Group zero is the entire match. Matches where the group did not participate are skipped.
+func Regexp(re *regexp.Regexp, group int) (Tokenizer, error) {
+	if group < 0 || group > re.NumSubexp() {
+		return nil, fmt.Errorf("regular expression %q has no capture group %d", re, group)
+	}
+
+	return TokenizerFunc(func(r io.Reader, emit func(string)) error {
+		return scan(r, bufio.ScanLines, func(line []byte) {
+			for _, match := range re.FindAllSubmatchIndex(line, -1) {
+				if start, end := match[2*group], match[2*group+1]; start >= 0 {
+					emit(string(line[start:end]))
+				}
+			}
+		})
+	}), nil
+}
+
+// scan splits the stream with the split function, truncating tokens that are longer than MaxTokenSize.
+func scan(r io.Reader, split bufio.SplitFunc, emit func(token []byte)) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 4096), MaxTokenSize)
+	scanner.Split(truncating(split))
+
+	for scanner.Scan() {
+		emit(scanner.Bytes())
+	}
+
+	return scanner.Err()
+}
+
+// truncating wraps a split function to emit the first MaxTokenSize bytes of a longer token and discard the rest of it.
+// The split function must not treat the byte 'x' as a delimiter.
+func truncating(split bufio.SplitFunc) bufio.SplitFunc {
+	skipping := false
+
+	return func(data []byte, atEOF bool) (int, []byte, error) {
+		if skipping {
+			// continue the long token with a byte that is not a delimiter, so that a delimiter at the start of data ends it.
+			advance, token, err := split(append([]byte{'x'}, data...), atEOF)
+
+			if token != nil || err != nil || atEOF {
+				skipping = false
+				return max(advance-1, 0), nil, err
+			}
+
+			// keep the last few bytes in case they are the start of a multi-byte delimiter.
+			return max(len(data)-utf8.UTFMax+1, 0), nil, nil
+		}
+
+		advance, token, err := split(data, atEOF)
+
+		if advance == 0 && token == nil && err == nil && len(data) >= MaxTokenSize {
+			// the token so far, as if the stream ended here.
+			_, token, _ = split(data, true)
+
+			if token != nil {
+				skipping = true
+				token = token[:min(len(token), MaxTokenSize)]
+			}
+
+			return len(data), token, nil
+		}
+
+		return advance, token, err
+	}
+}
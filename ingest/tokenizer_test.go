@@ -0,0 +1,79 @@
+package ingest
+
+import (
+	"github.com/stretchr/testify/require"
+	"io"
+	"regexp"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func tokenize(t *testing.T, tokenizer Tokenizer, input string) []string {
+	// read one byte at a time to exercise tokens spanning multiple reads.
+	return tokenizeReader(t, tokenizer, iotest.OneByteReader(strings.NewReader(input)))
+}
+
+func tokenizeReader(t *testing.T, tokenizer Tokenizer, r io.Reader) []string {
+	var tokens []string
+
+	err := tokenizer.Tokenize(r, func(token string) {
+		tokens = append(tokens, token)
+	})
+	require.NoError(t, err)
+
+	return tokens
+}
+
+func TestLines(t *testing.T) {
+	require.Equal(t, []string{"a b", "", "c"}, tokenize(t, Lines(), "a b\r\n\nc"))
+}
+
+func TestWords(t *testing.T) {
+	require.Equal(t, []string{"a", "b", "c"}, tokenize(t, Words(), "  a b\n\tc "))
+}
+
+func TestWordNGrams(t *testing.T) {
+	tokenizer, err := WordNGrams(2)
+	require.NoError(t, err)
+	require.Equal(t, []string{"a b", "b c", "c d"}, tokenize(t, tokenizer, "a b\nc d"))
+
+	_, err = WordNGrams(0)
+	require.Error(t, err)
+}
+
+func TestByteNGrams(t *testing.T) {
+	tokenizer, err := ByteNGrams(3)
+	require.NoError(t, err)
+	require.Equal(t, []string{"abc", "bcd", "cde"}, tokenize(t, tokenizer, "abcde"))
+	require.Empty(t, tokenize(t, tokenizer, "ab"))
+
+	input := strings.Repeat("x", 10_000)
+	require.Len(t, tokenize(t, tokenizer, input), len(input)-2)
+
+	_, err = ByteNGrams(0)
+	require.Error(t, err)
+}
+
+func TestRegexp(t *testing.T) {
+	tokenizer, err := Regexp(regexp.MustCompile(`GET (\S+)|POST`), 1)
+	require.NoError(t, err)
+	require.Equal(t, []string{"/a", "/b"}, tokenize(t, tokenizer, "GET /a GET /b\nPOST /c"))
+
+	_, err = Regexp(regexp.MustCompile(`a`), 1)
+	require.Error(t, err)
+}
+
+func TestLongTokens(t *testing.T) {
+	long := strings.Repeat("y", 3*MaxTokenSize)
+
+	lines := tokenizeReader(t, Lines(), strings.NewReader("a\n"+long+"\nb\n"+long))
+	require.Len(t, lines, 4)
+	require.Equal(t, "a", lines[0])
+	require.Equal(t, long[:MaxTokenSize], lines[1])
+	require.Equal(t, "b", lines[2])
+	require.Equal(t, long[:MaxTokenSize], lines[3])
+
+	words := tokenizeReader(t, Words(), strings.NewReader("a   "+long[:MaxTokenSize]+" b"))
+	require.Equal(t, []string{"a", long[:MaxTokenSize], "b"}, words)
+}
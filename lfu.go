@@ -0,0 +1,188 @@
+package heavy_hitters
+
+import (
+	"cmp"
+	"fmt"
+)
+
+// LFUCache is a cache of a fixed number of entries that evicts the least frequently used entry,
+// or the least recently used of the entries tied for the lowest frequency.
+//
+// It keeps its entries in the buckets of counters of a [StreamSummary], grouped by frequency,
+// so Get, Put and Delete take constant time. With aging, a new entry starts one above the frequency of the last evicted entry,
+// which was the lowest frequency in the cache, so it only moves past the buckets of frequency zero and of that frequency.
+type LFUCache[K cmp.Ordered, V any] struct {
+	capacity int
+	entries  map[K]lfuEntry[K, V]
+	// A list of buckets of counters with the same frequency, from the maximum frequency at the head to the minimum at the tail.
+	// The unused counters are kept in a bucket of frequency zero at the tail.
+	buckets *List[frequencyBucket[K]]
+	aging   bool
+	// The frequency of the most recently evicted entry, from which new entries start when aging.
+	age     int
+	onEvict func(K, V)
+}
+
+// lfuEntry is the value of an entry along with the counter of its frequency.
+type lfuEntry[K cmp.Ordered, V any] struct {
+	node  *Node[frequencyCounter[K]]
+	value V
+}
+
+// LFUOption configures optional behavior of an [LFUCache] at construction time.
+type LFUOption[K cmp.Ordered, V any] func(*lfuOptions[K, V])
+
+type lfuOptions[K cmp.Ordered, V any] struct {
+	aging   bool
+	onEvict func(K, V)
+}
+
+// WithLFUAging starts the frequency of new entries from the frequency of the most recently evicted entry, plus one,
+// rather than from one. Entries that were used often in the past then age out once newer entries are used as often,
+// instead of staying in the cache forever.
+func WithLFUAging[K cmp.Ordered, V any]() LFUOption[K, V] {
+	return func(o *lfuOptions[K, V]) {
+		o.aging = true
+	}
+}
+
+// WithLFUOnEvict registers a callback invoked with the key and value of every entry evicted to make room for another entry.
+// It is not invoked for entries removed by Delete or whose value is replaced by Put.
+func WithLFUOnEvict[K cmp.Ordered, V any](fn func(K, V)) LFUOption[K, V] {
+	return func(o *lfuOptions[K, V]) {
+		o.onEvict = fn
+	}
+}
+
+// NewLFUCache creates an empty cache of at most the given number of entries.
+// An error wrapping [ErrInvalidOption] is returned if the capacity is not positive.
+func NewLFUCache[K cmp.Ordered, V any](capacity int, opts ...LFUOption[K, V]) (*LFUCache[K, V], error) {
+	var o lfuOptions[K, V]
+
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	if capacity <= 0 {
+		return nil, fmt.Errorf("%w: capacity must be positive, got %d", ErrInvalidOption, capacity)
+	}
+
+	return &LFUCache[K, V]{
+		capacity: capacity,
+		entries:  make(map[K]lfuEntry[K, V], capacity),
+		buckets:  newBuckets[K](capacity),
+		aging:    o.aging,
+		onEvict:  o.onEvict,
+	}, nil
+}
+
+// Get retrieves the value of the given key, counting a use of the entry.
+func (c *LFUCache[K, V]) Get(key K) (V, bool) {
+	entry, found := c.entries[key]
+	if !found {
+		var v V
+		return v, false
+	}
+
+	incrementCounter(entry.node, 1)
+
+	return entry.value, true
+}
+
+// Put sets the value of the given key, counting a use of the entry.
+// A new entry evicts the least frequently used entry when the cache is full.
+func (c *LFUCache[K, V]) Put(key K, value V) {
+	if entry, found := c.entries[key]; found {
+		entry.value = value
+		c.entries[key] = entry
+		incrementCounter(entry.node, 1)
+
+		return
+	}
+
+	if len(c.entries) == c.capacity {
+		c.evict()
+	}
+
+	// the unused counters are in the bucket of frequency zero at the tail.
+	node := c.buckets.Tail().Value.counts.Tail()
+	node.Value.key = key
+	c.entries[key] = lfuEntry[K, V]{node: node, value: value}
+
+	// every entry is at least as frequent as the last evicted entry, since it was the least frequent and frequencies only grow,
+	// so at most the bucket of the age lies between the unused counters and the new entry.
+	n := 1
+	if c.aging {
+		n += c.age
+	}
+
+	incrementCounter(node, n)
+}
+
+// Delete removes the entry of the given key, reporting whether there was one.
+func (c *LFUCache[K, V]) Delete(key K) bool {
+	entry, found := c.entries[key]
+	if !found {
+		return false
+	}
+
+	delete(c.entries, key)
+	c.release(entry.node)
+
+	return true
+}
+
+// Frequency returns the frequency of the given key, without counting a use of the entry.
+// With aging, the frequency includes the frequency the entry started from.
+func (c *LFUCache[K, V]) Frequency(key K) (int, bool) {
+	entry, found := c.entries[key]
+	if !found {
+		return 0, false
+	}
+
+	return entry.node.Value.count, true
+}
+
+// Len is the number of entries in the cache.
+func (c *LFUCache[K, V]) Len() int {
+	return len(c.entries)
+}
+
+// Capacity is the maximum number of entries in the cache.
+func (c *LFUCache[K, V]) Capacity() int {
+	return c.capacity
+}
+
+// evict removes the least recently used entry of the bucket with the lowest frequency, which is the head of that bucket.
+func (c *LFUCache[K, V]) evict() {
+	node := c.buckets.Tail().Value.counts.Head()
+	key := node.Value.key
+	entry := c.entries[key]
+
+	c.age = node.Value.count
+	delete(c.entries, key)
+	c.release(node)
+
+	if c.onEvict != nil {
+		c.onEvict(key, entry.value)
+	}
+}
+
+// release moves a counter to the bucket of frequency zero, creating it if needed, so it can be reused by another entry.
+func (c *LFUCache[K, V]) release(node *Node[frequencyCounter[K]]) {
+	unused := c.buckets.Tail()
+	if unused.Value.count > 0 {
+		unused = c.buckets.PushTail(frequencyBucket[K]{
+			counts: NewList[frequencyCounter[K]](),
+		}).Tail()
+	}
+
+	bucket := node.Value.bucket
+	unused.Value.counts.PushTailNode(node)
+	// reset the counter, so the cache does not retain the key.
+	node.Value = frequencyCounter[K]{bucket: unused}
+
+	if bucket.Value.counts.Empty() {
+		bucket.RemoveSelf()
+	}
+}
@@ -0,0 +1,270 @@
+package heavy_hitters
+
+import (
+	"github.com/stretchr/testify/require"
+	"math/rand"
+	"testing"
+)
+
+func TestLFUCache(t *testing.T) {
+	var evicted []string
+
+	cache, err := NewLFUCache[string, int](2, WithLFUOnEvict(func(key string, value int) {
+		evicted = append(evicted, key)
+	}))
+	require.NoError(t, err)
+
+	cache.Put("a", 1)
+	cache.Put("b", 2)
+
+	value, found := cache.Get("a")
+	require.True(t, found)
+	require.Equal(t, 1, value)
+
+	// b is the least frequently used entry.
+	cache.Put("c", 3)
+	require.Equal(t, []string{"b"}, evicted)
+
+	_, found = cache.Get("b")
+	require.False(t, found)
+
+	// a and c are tied after using c, and c is the most recently used.
+	cache.Put("c", 30)
+	cache.Put("d", 4)
+	require.Equal(t, []string{"b", "a"}, evicted)
+
+	value, found = cache.Get("c")
+	require.True(t, found)
+	require.Equal(t, 30, value)
+
+	frequency, found := cache.Frequency("c")
+	require.True(t, found)
+	require.Equal(t, 3, frequency)
+	require.Equal(t, 2, cache.Len())
+	require.Equal(t, 2, cache.Capacity())
+}
+
+func TestLFUCache_Delete(t *testing.T) {
+	var evicted []string
+
+	cache, err := NewLFUCache[string, int](2, WithLFUOnEvict(func(key string, value int) {
+		evicted = append(evicted, key)
+	}))
+	require.NoError(t, err)
+
+	cache.Put("a", 1)
+	cache.Put("b", 2)
+	cache.Get("b")
+
+	require.True(t, cache.Delete("b"))
+	require.False(t, cache.Delete("b"))
+	require.Equal(t, 1, cache.Len())
+
+	// the counter of b is reused without evicting a.
+	cache.Put("c", 3)
+	require.Empty(t, evicted)
+
+	frequency, _ := cache.Frequency("c")
+	require.Equal(t, 1, frequency)
+
+	require.True(t, cache.Delete("a"))
+	require.True(t, cache.Delete("c"))
+	require.Equal(t, 0, cache.Len())
+	require.Equal(t, 1, cache.buckets.Len())
+
+	cache.Put("d", 4)
+	cache.Put("e", 5)
+	require.Empty(t, evicted)
+}
+
+func TestLFUCache_Aging(t *testing.T) {
+	cache, err := NewLFUCache[string, int](2, WithLFUAging[string, int]())
+	require.NoError(t, err)
+
+	cache.Put("old", 1)
+	for i := 0; i < 3; i++ {
+		cache.Get("old")
+	}
+
+	cache.Put("a", 1)
+	cache.Get("a")
+
+	// b evicts a, whose frequency was 2, so it starts from 3.
+	cache.Put("b", 2)
+	frequency, _ := cache.Frequency("b")
+	require.Equal(t, 3, frequency)
+
+	// b is now as frequent as old, and old is evicted once b is used once more and c arrives.
+	cache.Get("b")
+	cache.Put("c", 3)
+
+	_, found := cache.Get("old")
+	require.False(t, found)
+
+	_, found = cache.Get("b")
+	require.True(t, found)
+
+	frequency, _ = cache.Frequency("c")
+	require.Equal(t, 5, frequency)
+}
+
+func TestLFUCache_AgingBelowEveryFrequency(t *testing.T) {
+	cache, err := NewLFUCache[int, int](8, WithLFUAging[int, int]())
+	require.NoError(t, err)
+
+	r := rand.New(rand.NewSource(1))
+
+	for i := 0; i < 10000; i++ {
+		key := r.Intn(32)
+
+		switch r.Intn(4) {
+		case 0:
+			cache.Delete(key)
+		case 1:
+			cache.Get(key)
+		default:
+			cache.Put(key, i)
+		}
+
+		// Put then only walks past the bucket of unused counters and the bucket of the age.
+		for key := range cache.entries {
+			frequency, _ := cache.Frequency(key)
+			require.GreaterOrEqual(t, frequency, cache.age)
+		}
+	}
+}
+
+// referenceLFU is a linear-time LFU cache that the LFUCache must match.
+type referenceLFU struct {
+	capacity int
+	aging    bool
+	age      int
+	tick     int
+	entries  map[int]*referenceEntry
+}
+
+type referenceEntry struct {
+	value, frequency, used int
+}
+
+func (c *referenceLFU) use(e *referenceEntry, n int) {
+	c.tick++
+	e.frequency += n
+	e.used = c.tick
+}
+
+func (c *referenceLFU) get(key int) (int, bool) {
+	e, found := c.entries[key]
+	if !found {
+		return 0, false
+	}
+
+	c.use(e, 1)
+
+	return e.value, true
+}
+
+func (c *referenceLFU) put(key, value int) (evicted int, ok bool) {
+	if e, found := c.entries[key]; found {
+		e.value = value
+		c.use(e, 1)
+
+		return 0, false
+	}
+
+	if len(c.entries) == c.capacity {
+		var victim *referenceEntry
+
+		for k, e := range c.entries {
+			if victim == nil || e.frequency < victim.frequency || (e.frequency == victim.frequency && e.used < victim.used) {
+				victim, evicted = e, k
+			}
+		}
+
+		c.age = victim.frequency
+		delete(c.entries, evicted)
+		ok = true
+	}
+
+	e := &referenceEntry{value: value}
+	if c.aging {
+		e.frequency = c.age
+	}
+
+	c.use(e, 1)
+	c.entries[key] = e
+
+	return evicted, ok
+}
+
+func TestLFUCache_MatchesReference(t *testing.T) {
+	for _, aging := range []bool{false, true} {
+		var opts []LFUOption[int, int]
+		if aging {
+			opts = append(opts, WithLFUAging[int, int]())
+		}
+
+		var evicted []int
+		opts = append(opts, WithLFUOnEvict(func(key, value int) {
+			evicted = append(evicted, key)
+		}))
+
+		cache, err := NewLFUCache[int, int](16, opts...)
+		require.NoError(t, err)
+
+		reference := &referenceLFU{capacity: 16, aging: aging, entries: make(map[int]*referenceEntry)}
+		generator := rand.NewZipf(rand.New(rand.NewSource(42)), 1.1, 2, 100)
+
+		for i := 0; i < 10_000; i++ {
+			key := int(generator.Uint64())
+
+			switch i % 5 {
+			case 0, 1:
+				value, found := cache.Get(key)
+				expected, expectedFound := reference.get(key)
+				require.Equal(t, expectedFound, found)
+				require.Equal(t, expected, value)
+			case 2, 3:
+				evicted = evicted[:0]
+				cache.Put(key, i)
+
+				if key, ok := reference.put(key, i); ok {
+					require.Equal(t, []int{key}, evicted)
+				} else {
+					require.Empty(t, evicted)
+				}
+			case 4:
+				_, found := reference.entries[key]
+				delete(reference.entries, key)
+				require.Equal(t, found, cache.Delete(key))
+			}
+
+			require.Equal(t, len(reference.entries), cache.Len())
+		}
+
+		for key, e := range reference.entries {
+			frequency, found := cache.Frequency(key)
+			require.True(t, found)
+			require.Equal(t, e.frequency, frequency)
+		}
+	}
+}
+
+func TestNewLFUCache_Invalid(t *testing.T) {
+	_, err := NewLFUCache[string, int](0)
+	require.ErrorIs(t, err, ErrInvalidOption)
+}
+
+func BenchmarkLFUCache(b *testing.B) {
+	generator := rand.NewZipf(rand.New(rand.NewSource(42)), 1.08, 2, 1_000_000)
+	cache, _ := NewLFUCache[uint64, int](1000)
+
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		key := generator.Uint64()
+		if _, found := cache.Get(key); !found {
+			cache.Put(key, i)
+		}
+	}
+}
@@ -0,0 +1,232 @@
+// Package limiter throttles the heaviest clients of a service rather than all of them.
+//
+// A Limiter counts the hits of every key, such as a client IP, in a summary of the current window and of the previous one.
+// A key is throttled once its guaranteed count, its count minus its error, exceeds a share of all the hits of both windows,
+// so a key is never throttled because of the approximation of the summary.
+// Since the previous window is kept until the current one ends, decisions span between one and two windows of hits.
+package limiter
+
+import (
+	"context"
+	"fmt"
+	hh "heavy-hitters"
+	"heavy-hitters/middleware"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Option configures a Limiter.
+type Option func(*options)
+
+type options struct {
+	capacity  int
+	window    time.Duration
+	threshold float64
+	minHits   int
+	delay     time.Duration
+}
+
+// WithCapacity sets the capacity of the summary of each window, 1000 by default.
+// Only keys with a share above 1/capacity of the hits can be guaranteed to exceed the threshold.
+func WithCapacity(capacity int) Option {
+	return func(o *options) {
+		o.capacity = capacity
+	}
+}
+
+// WithWindow sets the duration of a window, one minute by default.
+func WithWindow(window time.Duration) Option {
+	return func(o *options) {
+		o.window = window
+	}
+}
+
+// WithThreshold sets the share of all hits above which a key is throttled, 0.1 by default.
+func WithThreshold(threshold float64) Option {
+	return func(o *options) {
+		o.threshold = threshold
+	}
+}
+
+// WithMinHits sets the number of hits of both windows below which no key is throttled, 100 by default,
+// so the only clients of an idle service are not throttled.
+func WithMinHits(hits int) Option {
+	return func(o *options) {
+		o.minHits = hits
+	}
+}
+
+// WithDelay makes the handlers wrapped by the limiter delay throttled requests by the given duration, rather than rejecting them.
+func WithDelay(delay time.Duration) Option {
+	return func(o *options) {
+		o.delay = delay
+	}
+}
+
+// Limiter decides whether to throttle keys by their share of the recent hits, which is safe for concurrent use.
+type Limiter struct {
+	options
+	mutex sync.Mutex
+	// current counts the hits of the window starting at start, and previous those of the window before.
+	current, previous *hh.StreamSummary[string]
+	start             time.Time
+	// now is replaced by tests to control windows.
+	now func() time.Time
+}
+
+// New creates a limiter.
+func New(opts ...Option) (*Limiter, error) {
+	o := options{capacity: 1000, window: time.Minute, threshold: 0.1, minHits: 100}
+
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	if o.capacity <= 0 || o.window <= 0 {
+		return nil, fmt.Errorf("capacity and window must be positive, got %d and %v", o.capacity, o.window)
+	}
+
+	if !(o.threshold > 0 && o.threshold < 1) {
+		return nil, fmt.Errorf("threshold must be between 0 and 1, got %v", o.threshold)
+	}
+
+	if o.minHits < 0 || o.delay < 0 {
+		return nil, fmt.Errorf("minimum hits and delay must not be negative, got %d and %v", o.minHits, o.delay)
+	}
+
+	l := &Limiter{options: o, now: time.Now}
+	l.current = l.summary()
+	l.previous = l.summary()
+	l.start = l.now()
+
+	return l, nil
+}
+
+func (l *Limiter) summary() *hh.StreamSummary[string] {
+	return hh.NewStreamSummary[string](l.capacity)
+}
+
+// Allow counts a hit of the key, then reports whether the key is below the threshold.
+// Hits are counted whether or not they are allowed, so a key stays throttled as long as it keeps exceeding the threshold.
+func (l *Limiter) Allow(key string) bool {
+	return l.AllowN(key, 1)
+}
+
+// AllowN counts a hit of the key with the given weight, such as the cost of a request, then reports whether the key is below the threshold.
+// Weights that are not positive are not counted.
+func (l *Limiter) AllowN(key string, n int) bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.rotate()
+	l.current.HitN(key, n)
+
+	return !l.throttled(key)
+}
+
+// Throttled reports whether the key exceeds the threshold, without counting a hit.
+func (l *Limiter) Throttled(key string) bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.rotate()
+
+	return l.throttled(key)
+}
+
+// Guaranteed returns the guaranteed count of the key over the current and previous windows, and the hits of all keys in those windows.
+func (l *Limiter) Guaranteed(key string) (count int, hits int) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.rotate()
+
+	return l.guaranteed(key)
+}
+
+func (l *Limiter) guaranteed(key string) (int, int) {
+	count := 0
+
+	for _, s := range []*hh.StreamSummary[string]{l.previous, l.current} {
+		c, _ := s.Get(key)
+		count += c.Count - c.Error
+	}
+
+	return count, l.previous.Hits() + l.current.Hits()
+}
+
+func (l *Limiter) throttled(key string) bool {
+	count, hits := l.guaranteed(key)
+
+	return hits >= l.minHits && float64(count) > l.threshold*float64(hits)
+}
+
+// rotate starts a new window once the current one ends, dropping the previous window.
+// Both windows are dropped after a whole window without hits.
+func (l *Limiter) rotate() {
+	elapsed := l.now().Sub(l.start)
+	if elapsed < l.window {
+		return
+	}
+
+	windows := elapsed / l.window
+	if windows == 1 {
+		l.previous = l.current
+	} else {
+		l.previous = l.summary()
+	}
+
+	l.current = l.summary()
+	l.start = l.start.Add(windows * l.window)
+}
+
+// retryAfter is the time until the current window ends, after which a throttled key may have fallen below the threshold.
+func (l *Limiter) retryAfter() time.Duration {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	return l.start.Add(l.window).Sub(l.now())
+}
+
+// Wrap returns a handler that counts every request by the key of the extractor, such as [middleware.RemoteIP].
+// Throttled requests are rejected with 429 Too Many Requests and a Retry-After header, or delayed when the limiter has a delay.
+// Requests without a key are always served.
+func (l *Limiter) Wrap(key middleware.Extractor, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		k, ok := key(r)
+		if !ok || l.Allow(k) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if l.delay > 0 {
+			if err := sleep(r.Context(), l.delay); err != nil {
+				return
+			}
+
+			next.ServeHTTP(w, r)
+
+			return
+		}
+
+		seconds := int(math.Ceil(l.retryAfter().Seconds()))
+		w.Header().Set("Retry-After", strconv.Itoa(max(seconds, 1)))
+		http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+	})
+}
+
+// sleep waits for the delay, or returns early with the error of the context once it is done.
+func sleep(ctx context.Context, delay time.Duration) error {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,170 @@
+package limiter
+
+import (
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/require"
+	"heavy-hitters/middleware"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// clock controls the time of a limiter.
+type clock struct {
+	now time.Time
+}
+
+func newLimiter(t *testing.T, c *clock, opts ...Option) *Limiter {
+	l, err := New(opts...)
+	require.NoError(t, err)
+
+	l.now = func() time.Time {
+		return c.now
+	}
+	l.start = c.now
+
+	return l
+}
+
+func TestLimiter(t *testing.T) {
+	c := &clock{now: time.Unix(0, 0)}
+	l := newLimiter(t, c, WithThreshold(0.2), WithMinHits(10))
+
+	// below the minimum hits, even the only key is allowed.
+	for i := 0; i < 9; i++ {
+		require.True(t, l.Allow("heavy"))
+	}
+
+	require.False(t, l.Allow("heavy"))
+	require.True(t, l.Throttled("heavy"))
+
+	for i := 0; i < 40; i++ {
+		require.True(t, l.Allow(fmt.Sprint(i)))
+	}
+
+	// 10 of 50 hits is not above the threshold.
+	require.False(t, l.Throttled("heavy"))
+	require.True(t, l.Allow("light"))
+
+	count, hits := l.Guaranteed("heavy")
+	require.Equal(t, 10, count)
+	require.Equal(t, 51, hits)
+
+	require.False(t, l.AllowN("heavy", 10))
+}
+
+func TestLimiter_Guaranteed(t *testing.T) {
+	c := &clock{now: time.Unix(0, 0)}
+	l := newLimiter(t, c, WithCapacity(2), WithThreshold(0.3), WithMinHits(4))
+
+	// every key replaces another in the summary, so the counts of the last keys exceed the threshold,
+	// but they are mostly error and none is guaranteed to be heavy.
+	for i := 0; i < 10; i++ {
+		require.True(t, l.Allow(fmt.Sprint("a", i)))
+		require.True(t, l.Allow(fmt.Sprint("b", i)))
+	}
+
+	estimate, _ := l.current.Get("b9")
+	require.Greater(t, estimate.Count, 6)
+
+	count, hits := l.Guaranteed("b9")
+	require.Equal(t, 1, count)
+	require.Equal(t, 20, hits)
+}
+
+func TestLimiter_Windows(t *testing.T) {
+	c := &clock{now: time.Unix(0, 0)}
+	l := newLimiter(t, c, WithWindow(time.Minute), WithThreshold(0.5), WithMinHits(4))
+
+	for i := 0; i < 4; i++ {
+		l.Allow("heavy")
+	}
+
+	require.True(t, l.Throttled("heavy"))
+
+	// the previous window still counts during the next one.
+	c.now = c.now.Add(90 * time.Second)
+	require.True(t, l.Throttled("heavy"))
+
+	for i := 0; i < 4; i++ {
+		l.Allow(fmt.Sprint(i))
+	}
+
+	require.False(t, l.Throttled("heavy"))
+
+	c.now = c.now.Add(time.Minute)
+	count, hits := l.Guaranteed("heavy")
+	require.Equal(t, 0, count)
+	require.Equal(t, 4, hits)
+
+	// a whole window without hits drops both windows.
+	c.now = c.now.Add(2 * time.Minute)
+	_, hits = l.Guaranteed("heavy")
+	require.Equal(t, 0, hits)
+}
+
+func TestLimiter_Wrap(t *testing.T) {
+	c := &clock{now: time.Unix(0, 0)}
+	l := newLimiter(t, c, WithThreshold(0.5), WithMinHits(2))
+
+	handler := l.Wrap(middleware.RemoteIP, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	serve := func(remoteAddr string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = remoteAddr
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		return w
+	}
+
+	require.Equal(t, http.StatusOK, serve("10.0.0.1:1").Code)
+
+	c.now = c.now.Add(20 * time.Second)
+	w := serve("10.0.0.1:2")
+	require.Equal(t, http.StatusTooManyRequests, w.Code)
+	require.Equal(t, "40", w.Header().Get("Retry-After"))
+
+	require.Equal(t, http.StatusOK, serve("10.0.0.2:1").Code)
+	require.Equal(t, http.StatusOK, serve("").Code)
+}
+
+func TestLimiter_WrapDelay(t *testing.T) {
+	l, err := New(WithThreshold(0.5), WithMinHits(1), WithDelay(10*time.Millisecond))
+	require.NoError(t, err)
+
+	served := 0
+	handler := l.Wrap(middleware.RemoteIP, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		served++
+	}))
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	start := time.Now()
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+	require.GreaterOrEqual(t, time.Since(start), 10*time.Millisecond)
+	require.Equal(t, 1, served)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r.WithContext(ctx))
+	require.Equal(t, 1, served)
+}
+
+func TestNew_Invalid(t *testing.T) {
+	for _, opts := range [][]Option{
+		{WithCapacity(0)},
+		{WithWindow(0)},
+		{WithThreshold(0)},
+		{WithThreshold(1)},
+		{WithMinHits(-1)},
+		{WithDelay(-time.Second)},
+	} {
+		_, err := New(opts...)
+		require.Error(t, err)
+	}
+}
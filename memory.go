@@ -0,0 +1,151 @@
+package heavy_hitters
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"reflect"
+	"slices"
+	"unsafe"
+)
+
+// MemoryUsage estimates the number of bytes used by the summary.
+// The estimate covers the counters, the buckets, the map of monitored elements and the bytes referenced by string keys.
+// Computing the bytes referenced by string keys requires visiting every monitored element.
+func (s *StreamSummary[T]) MemoryUsage() int {
+	var key T
+
+	return streamSummaryUsage[T](s.capacity, len(s.elements), s.buckets.Len(), mapKeyBytes(s.elements)) +
+		cap(s.top)*int(unsafe.Sizeof(key)) + mapUsage(len(s.topSet), unsafe.Sizeof(key), 0)
+}
+
+// MemoryUsage estimates the number of bytes used by the summary.
+// The estimate covers the counters, the buckets, the map of monitored elements and the bytes referenced by string keys.
+// Computing the bytes referenced by string keys requires visiting every monitored element.
+func (s *CompactStreamSummary[T]) MemoryUsage() int {
+	return compactStreamSummaryUsage[T](len(s.counters), mapKeyBytes(s.elements))
+}
+
+// MemoryUsage estimates the number of bytes used by the maps of frequencies, including the bytes referenced by string keys.
+func (n NaiveHeavyHitters[T]) MemoryUsage() int {
+	var key T
+
+	return int(unsafe.Sizeof(n)) +
+		mapUsage(len(n.counts), unsafe.Sizeof(key), unsafe.Sizeof(int(0))) +
+		mapUsage(len(n.arrivals), unsafe.Sizeof(key), unsafe.Sizeof(int(0))) +
+		mapKeyBytes(n.counts)
+}
+
+// NewWithMemoryBudget creates a new stream summary with the largest capacity that fits in the given number of bytes once full.
+// The average key size is the expected number of bytes referenced by each string key, and is ignored for other key types.
+func NewWithMemoryBudget[T cmp.Ordered](bytes int, averageKeySize int, opts ...Option[T]) (*StreamSummary[T], error) {
+	return New(append(slices.Clip(opts), WithMemoryBudget[T](bytes), WithAverageKeySize[T](averageKeySize))...)
+}
+
+// NewCompactWithMemoryBudget creates a new compact stream summary with the largest capacity that fits in the given number of bytes once full.
+// The average key size is the expected number of bytes referenced by each string key, and is ignored for other key types.
+func NewCompactWithMemoryBudget[T cmp.Ordered](bytes int, averageKeySize int) (*CompactStreamSummary[T], error) {
+	if averageKeySize < 0 {
+		return nil, fmt.Errorf("%w: average key size must not be negative, got %d", ErrInvalidOption, averageKeySize)
+	}
+
+	capacity := capacityForMemoryBudget(bytes, func(capacity int) int {
+		return compactStreamSummaryUsage[T](capacity, capacity*averageKeySize)
+	})
+
+	if capacity <= 0 {
+		return nil, fmt.Errorf("%w: memory budget of %d bytes does not fit a single counter", ErrInvalidOption, bytes)
+	}
+
+	return NewCompactStreamSummary[T](min(capacity, math.MaxInt32-1)), nil
+}
+
+// streamSummaryUsage estimates the bytes used by a StreamSummary with the given number of counters, map entries, buckets and bytes referenced by keys.
+func streamSummaryUsage[T cmp.Ordered](capacity, entries, buckets, keys int) int {
+	var s StreamSummary[T]
+	var counter Node[frequencyCounter[T]]
+	var bucket Node[frequencyBucket[T]]
+	var bucketList List[frequencyBucket[T]]
+	var counterList List[frequencyCounter[T]]
+	var key T
+
+	return int(unsafe.Sizeof(s)) +
+		capacity*int(unsafe.Sizeof(counter)) +
+		buckets*int(unsafe.Sizeof(bucket)+unsafe.Sizeof(counterList)) +
+		int(unsafe.Sizeof(bucketList)) +
+		mapUsage(entries, unsafe.Sizeof(key), unsafe.Sizeof(&counter)) +
+		keys
+}
+
+// compactStreamSummaryUsage estimates the bytes used by a CompactStreamSummary with the given capacity and bytes referenced by keys.
+// The map of monitored elements is pre-sized to the capacity, so it does not depend on the number of monitored elements.
+func compactStreamSummaryUsage[T cmp.Ordered](capacity, keys int) int {
+	var s CompactStreamSummary[T]
+	var counter compactCounter[T]
+	var bucket compactBucket
+	var key T
+
+	return int(unsafe.Sizeof(s)) +
+		capacity*int(unsafe.Sizeof(counter)) +
+		(capacity+1)*int(unsafe.Sizeof(bucket)) +
+		mapUsage(capacity, unsafe.Sizeof(key), unsafe.Sizeof(nilIndex)) +
+		keys
+}
+
+// mapUsage estimates the bytes used by a map with the given number of entries.
+// Maps keep at most 7/8 of their slots occupied and use a control byte per slot.
+func mapUsage(entries int, keySize, valueSize uintptr) int {
+	slots := (entries*8 + 6) / 7
+
+	return slots * int(keySize+valueSize+1)
+}
+
+// keyBytes counts the bytes referenced by a key outside of its own size, which is only non-zero for strings.
+func keyBytes[T cmp.Ordered](key T) int {
+	switch k := any(key).(type) {
+	case string:
+		return len(k)
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, uintptr, float32, float64:
+		return 0
+	}
+
+	// a named type, whose underlying type is a string if its kind is.
+	if reflect.TypeFor[T]().Kind() == reflect.String {
+		return len(*(*string)(unsafe.Pointer(&key)))
+	}
+
+	return 0
+}
+
+// mapKeyBytes counts the bytes referenced by the keys of a map, only visiting the keys if they are strings.
+func mapKeyBytes[T cmp.Ordered, V any](m map[T]V) int {
+	if reflect.TypeFor[T]().Kind() != reflect.String {
+		return 0
+	}
+
+	var keys int
+
+	for key := range m {
+		keys += keyBytes(key)
+	}
+
+	return keys
+}
+
+// capacityForMemoryBudget finds the largest capacity whose memory usage fits in the budget, or zero if none does.
+// The usage function must be monotonically increasing in the capacity.
+func capacityForMemoryBudget(budget int, usage func(capacity int) int) int {
+	low, high := 0, max(budget, 0)
+
+	for low < high {
+		middle := low + (high-low+1)/2
+
+		if usage(middle) <= budget {
+			low = middle
+		} else {
+			high = middle - 1
+		}
+	}
+
+	return low
+}
@@ -0,0 +1,106 @@
+package heavy_hitters
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestStreamSummary_MemoryUsage(t *testing.T) {
+	hh := NewStreamSummary[string](100)
+	empty := hh.MemoryUsage()
+
+	for i := 0; i < 1000; i++ {
+		hh.Hit(fmt.Sprintf("key-%04d", i%200))
+	}
+
+	// every monitored key references 8 bytes, which are accounted for on top of the counters and map entries.
+	require.Greater(t, hh.MemoryUsage(), empty+100*8)
+}
+
+func TestNewWithMemoryBudget(t *testing.T) {
+	const budget = 64 * 1024
+	const averageKeySize = 16
+
+	hh, err := NewWithMemoryBudget[string](budget, averageKeySize)
+	require.NoError(t, err)
+
+	for i := 0; i < 10*hh.capacity; i++ {
+		hh.Hit(fmt.Sprintf("%016d", i))
+	}
+
+	require.LessOrEqual(t, hh.MemoryUsage(), budget)
+
+	// one more counter would no longer fit in the budget once full.
+	larger := hh.capacity + 1
+	require.Greater(t, streamSummaryUsage[string](larger, larger, larger+1, larger*averageKeySize), budget)
+
+	_, err = NewWithMemoryBudget[string](1, averageKeySize)
+	require.ErrorIs(t, err, ErrInvalidOption)
+
+	_, err = NewWithMemoryBudget[string](budget, -1)
+	require.ErrorIs(t, err, ErrInvalidOption)
+}
+
+func TestNewCompactWithMemoryBudget(t *testing.T) {
+	const budget = 64 * 1024
+
+	compact, err := NewCompactWithMemoryBudget[uint64](budget, 0)
+	require.NoError(t, err)
+
+	for i := uint64(0); i < 10_000; i++ {
+		compact.Hit(i)
+	}
+
+	require.LessOrEqual(t, compact.MemoryUsage(), budget)
+
+	pointers, err := NewWithMemoryBudget[uint64](budget, 0)
+	require.NoError(t, err)
+
+	// the compact summary has no per-counter pointers, so more counters fit in the same budget.
+	require.Greater(t, len(compact.counters), pointers.capacity)
+
+	_, err = NewCompactWithMemoryBudget[uint64](1, 0)
+	require.ErrorIs(t, err, ErrInvalidOption)
+}
+
+func TestNaiveHeavyHitters_MemoryUsage(t *testing.T) {
+	hh := NewNaive[string]()
+	empty := hh.MemoryUsage()
+
+	hh.Hit("abcd")
+	hh.Hit("efgh")
+
+	require.Greater(t, hh.MemoryUsage(), empty+8)
+}
+
+// name is a named string type, whose keys still reference bytes.
+type name string
+
+func TestKeyBytes(t *testing.T) {
+	require.Equal(t, 5, keyBytes("hello"))
+	require.Equal(t, 4, keyBytes(name("nick")))
+	require.Equal(t, 0, keyBytes(12345))
+	require.Equal(t, 0, keyBytes(1.5))
+
+	require.Zero(t, testing.AllocsPerRun(100, func() {
+		keyBytes(uint64(1 << 40))
+		keyBytes(name("nick"))
+	}))
+}
+
+func TestNewWithMemoryBudget_Options(t *testing.T) {
+	opts := make([]Option[string], 1, 2)
+	opts[0] = WithTieBreak[string](TieBreakKey)
+	spare := opts[:2]
+	spare[1] = WithTieBreak[string](TieBreakArrival)
+
+	_, err := NewWithMemoryBudget[string](1<<20, 8, opts...)
+	require.NoError(t, err)
+
+	// the spare capacity of the options of the caller is not overwritten.
+	var o options[string]
+	spare[1](&o)
+	require.Equal(t, TieBreakArrival, o.tieBreak)
+	require.Zero(t, o.sizings)
+}
@@ -0,0 +1,103 @@
+package heavy_hitters
+
+// Merge combines the counters of another summary into this one, as if this summary had also counted the other's stream.
+// The merged summary keeps its own capacity, so the error of its approximations remains bounded by Hits / capacity.
+// Counters that no longer fit are dropped, and are reported to the eviction callback if they were monitored by this summary.
+func (s *StreamSummary[T]) Merge(other *StreamSummary[T]) {
+	s.merge(other.snapshot())
+}
+
+// MergeBinary combines the counters of a summary in the binary format into this one.
+// The encoded summary may have been written by any implementation of the SpaceSaving algorithm.
+func (s *StreamSummary[T]) MergeBinary(data []byte) error {
+	snap, err := decodeSnapshot[T](data, AlgorithmSpaceSaving)
+	if err != nil {
+		return err
+	}
+
+	s.merge(snap)
+
+	return nil
+}
+
+// merge combines the counters of two summaries following the mergeable summaries construction for SpaceSaving.
+// An element that is not monitored by a full summary may have been counted up to that summary's minimum count,
+// so the minimum count is added to both the count and the error of every element monitored by only one of the summaries.
+func (s *StreamSummary[T]) merge(other snapshot[T]) {
+	mine := s.snapshot()
+	mineMin, otherMin := mine.minimum(), other.minimum()
+
+	others := make(map[T]snapshotCounter[T], len(other.counters))
+	for _, c := range other.counters {
+		others[c.key] = c
+	}
+
+	counters := make([]snapshotCounter[T], 0, len(mine.counters)+len(other.counters))
+
+	for _, c := range mine.counters {
+		o, found := others[c.key]
+
+		if found {
+			delete(others, c.key)
+			c.count = saturatingAdd(c.count, o.count)
+			c.error = saturatingAdd(c.error, o.error)
+		} else {
+			c.count = saturatingAdd(c.count, otherMin)
+			c.error = saturatingAdd(c.error, otherMin)
+		}
+
+		counters = append(counters, c)
+	}
+
+	for _, o := range other.counters {
+		if _, remaining := others[o.key]; !remaining {
+			continue
+		}
+
+		counters = append(counters, snapshotCounter[T]{
+			key:     o.key,
+			count:   saturatingAdd(o.count, mineMin),
+			error:   saturatingAdd(o.error, mineMin),
+			arrival: saturatingAdd(mine.hits, o.arrival),
+		})
+	}
+
+	sortCounters(counters)
+
+	dropped := counters[min(len(counters), s.capacity):]
+	counters = counters[:min(len(counters), s.capacity)]
+
+	previous, previousSet := s.top, s.topSet
+
+	s.restore(snapshot[T]{
+		algorithm: AlgorithmSpaceSaving,
+		capacity:  s.capacity,
+		hits:      saturatingAdd(mine.hits, other.hits),
+		counters:  counters,
+	})
+
+	for _, c := range dropped {
+		if _, monitored := others[c.key]; !monitored {
+			s.evictions++
+
+			if s.onEvict != nil {
+				s.onEvict(c.key, Count{Count: c.count, Error: c.error})
+			}
+		}
+	}
+
+	if s.onTopKChange != nil {
+		s.top, s.topSet = previous, previousSet
+		s.updateTopK()
+	}
+}
+
+// minimum is the count an element that is not monitored by the snapshot may have been counted up to.
+// A summary with unused counters has counted every element of its stream exactly.
+func (s snapshot[T]) minimum() int {
+	if len(s.counters) < s.capacity || len(s.counters) == 0 {
+		return 0
+	}
+
+	return s.counters[len(s.counters)-1].count
+}
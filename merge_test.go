@@ -0,0 +1,79 @@
+package heavy_hitters
+
+import (
+	"github.com/stretchr/testify/require"
+	"math/rand"
+	"testing"
+)
+
+func TestStreamSummary_Merge(t *testing.T) {
+	generator := rand.NewZipf(rand.New(rand.NewSource(42)), 1.2, 2, 10_000)
+	exact := NewNaive[uint64]()
+	left := NewStreamSummary[uint64](20)
+	right := NewStreamSummary[uint64](20)
+
+	for i := 0; i < 20_000; i++ {
+		e := generator.Uint64()
+		exact.Hit(e)
+
+		if i%2 == 0 {
+			left.Hit(e)
+		} else {
+			right.Hit(e)
+		}
+	}
+
+	left.Merge(right)
+
+	require.Equal(t, exact.Hits(), left.Hits())
+	require.Len(t, left.elements, 20)
+
+	for e := range left.elements {
+		count, _ := left.Get(e)
+		actual, _ := exact.Get(e)
+
+		require.LessOrEqual(t, count.Count-count.Error, actual.Count)
+		require.GreaterOrEqual(t, count.Count, actual.Count)
+		require.LessOrEqual(t, count.Error, left.Hits()/20)
+	}
+
+	expected, _, _ := exact.Top(3)
+	top, _, _ := left.Top(3)
+	require.Equal(t, expected, top)
+}
+
+func TestStreamSummary_MergePartial(t *testing.T) {
+	var evicted []string
+
+	left := NewStreamSummary[string](2, WithOnEvict(func(key string, _ Count) {
+		evicted = append(evicted, key)
+	}))
+	right := NewStreamSummary[string](4)
+
+	for _, e := range []string{"a", "a", "b"} {
+		left.Hit(e)
+	}
+
+	for _, e := range []string{"c", "c", "c", "a"} {
+		right.Hit(e)
+	}
+
+	data, err := right.MarshalBinary()
+	require.NoError(t, err)
+	require.NoError(t, left.MergeBinary(data))
+
+	// the right summary has unused counters, so it counted every element exactly.
+	count, found := left.Get("a")
+	require.True(t, found)
+	require.Equal(t, Count{Count: 3}, count)
+
+	// the left summary is full, so "c" may have been counted up to its minimum count.
+	count, found = left.Get("c")
+	require.True(t, found)
+	require.Equal(t, Count{Count: 4, Error: 1}, count)
+
+	_, found = left.Get("b")
+	require.False(t, found)
+	require.Equal(t, []string{"b"}, evicted)
+	require.Equal(t, 7, left.Hits())
+}
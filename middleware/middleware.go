@@ -0,0 +1,317 @@
+// Package middleware tracks the heavy hitters of the requests served by a net/http server, such as its top clients, paths and user agents.
+//
+// A Tracker wraps a handler and counts every request in one summary per dimension, keyed by an Extractor:
+//
+//	tracker, _ := middleware.New()
+//	mux.Handle(middleware.DebugPath, tracker.Handler())
+//	http.ListenAndServe(":8080", tracker.Wrap(mux))
+//
+// The debug handler writes the current top-k of every dimension, like net/http/pprof writes profiles.
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	hh "heavy-hitters"
+	"heavy-hitters/report"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+// DebugPath is the conventional path of the debug handler.
+const DebugPath = "/debug/heavy-hitters"
+
+// Extractor finds the key of a request in a dimension, or returns false for requests that are not counted in it.
+type Extractor func(r *http.Request) (string, bool)
+
+// RemoteIP extracts the IP address of the client that sent the request, without its port.
+func RemoteIP(r *http.Request) (string, bool) {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr, r.RemoteAddr != ""
+	}
+
+	return host, true
+}
+
+// ForwardedIP extracts the first address of the X-Forwarded-For header, falling back to RemoteIP without one.
+// It must only be used behind a proxy that sets the header, since clients can forge it.
+func ForwardedIP(r *http.Request) (string, bool) {
+	if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+		first, _, _ := strings.Cut(forwarded, ",")
+		if first = strings.TrimSpace(first); first != "" {
+			return first, true
+		}
+	}
+
+	return RemoteIP(r)
+}
+
+// Path extracts the path of the request URL.
+func Path(r *http.Request) (string, bool) {
+	return r.URL.Path, true
+}
+
+// Route extracts the pattern of the mux that matches the request, such as "GET /items/{id}",
+// which keeps paths with identifiers from spreading the hits of a route over many keys.
+// Requests that match no pattern are not counted.
+func Route(mux *http.ServeMux) Extractor {
+	return func(r *http.Request) (string, bool) {
+		_, pattern := mux.Handler(r)
+		return pattern, pattern != ""
+	}
+}
+
+// UserAgent extracts the User-Agent header, skipping requests without one.
+func UserAgent(r *http.Request) (string, bool) {
+	agent := r.UserAgent()
+	return agent, agent != ""
+}
+
+// Weight is the amount a request adds to the count of its keys.
+type Weight int
+
+const (
+	// WeightRequests counts every request once.
+	WeightRequests Weight = iota
+	// WeightBytes counts the bytes of the response body.
+	WeightBytes
+	// WeightLatency counts the microseconds taken to serve the request.
+	WeightLatency
+)
+
+func (w Weight) String() string {
+	switch w {
+	case WeightRequests:
+		return "requests"
+	case WeightBytes:
+		return "bytes"
+	case WeightLatency:
+		return "latency"
+	default:
+		return fmt.Sprintf("Weight(%d)", int(w))
+	}
+}
+
+// Option configures a Tracker.
+type Option func(*options)
+
+type options struct {
+	capacity   int
+	weight     Weight
+	dimensions []dimension
+}
+
+// dimension is a named extractor.
+type dimension struct {
+	name string
+	key  Extractor
+}
+
+// WithCapacity sets the capacity of the summary of every dimension, 1000 by default.
+func WithCapacity(capacity int) Option {
+	return func(o *options) {
+		o.capacity = capacity
+	}
+}
+
+// WithWeight sets the weight of requests, WeightRequests by default.
+func WithWeight(weight Weight) Option {
+	return func(o *options) {
+		o.weight = weight
+	}
+}
+
+// WithDimension counts requests by the keys of the extractor, replacing the extractor of the dimension of the same name.
+// A nil extractor removes the dimension. The default dimensions are "clients" by RemoteIP, "paths" by Path and "user_agents" by UserAgent.
+func WithDimension(name string, key Extractor) Option {
+	return func(o *options) {
+		i := slices.IndexFunc(o.dimensions, func(d dimension) bool {
+			return d.name == name
+		})
+
+		switch {
+		case i < 0 && key != nil:
+			o.dimensions = append(o.dimensions, dimension{name: name, key: key})
+		case key != nil:
+			o.dimensions[i].key = key
+		case i >= 0:
+			o.dimensions = slices.Delete(o.dimensions, i, i+1)
+		}
+	}
+}
+
+// Tracker counts the requests of wrapped handlers, which is safe for concurrent use.
+type Tracker struct {
+	weight     Weight
+	dimensions []trackedDimension
+	// now is replaced by tests to control latencies.
+	now func() time.Time
+}
+
+// trackedDimension is a dimension along with its summary.
+type trackedDimension struct {
+	dimension
+	summary *hh.Synchronized[string]
+}
+
+// New creates a tracker with the default dimensions, unless replaced by the options.
+func New(opts ...Option) (*Tracker, error) {
+	o := options{
+		capacity: 1000,
+		weight:   WeightRequests,
+		dimensions: []dimension{
+			{name: "clients", key: RemoteIP},
+			{name: "paths", key: Path},
+			{name: "user_agents", key: UserAgent},
+		},
+	}
+
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	if o.capacity <= 0 {
+		return nil, fmt.Errorf("capacity must be positive, got %d", o.capacity)
+	}
+
+	if o.weight < WeightRequests || o.weight > WeightLatency {
+		return nil, fmt.Errorf("invalid weight %v", o.weight)
+	}
+
+	if len(o.dimensions) == 0 {
+		return nil, errors.New("no dimension to track")
+	}
+
+	t := &Tracker{weight: o.weight, now: time.Now}
+
+	for _, d := range o.dimensions {
+		t.dimensions = append(t.dimensions, trackedDimension{
+			dimension: d,
+			summary:   hh.NewSynchronized[string](hh.NewStreamSummary[string](o.capacity, hh.WithTieBreak[string](hh.TieBreakKey))),
+		})
+	}
+
+	return t, nil
+}
+
+// Wrap returns a handler that serves requests with next, then counts them in every dimension.
+func (t *Tracker) Wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := t.now()
+
+		var recorder *responseRecorder
+		if t.weight == WeightBytes {
+			recorder = &responseRecorder{ResponseWriter: w}
+			w = recorder
+		}
+
+		// requests are counted even when the handler panics, such as with http.ErrAbortHandler.
+		defer func() {
+			weight := 1
+
+			switch t.weight {
+			case WeightBytes:
+				weight = int(recorder.written)
+			case WeightLatency:
+				weight = int(t.now().Sub(start).Microseconds())
+			}
+
+			t.Track(r, weight)
+		}()
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Track counts a request with the given weight in every dimension, for requests that are not served by a wrapped handler.
+// Weights that are not positive are ignored.
+func (t *Tracker) Track(r *http.Request, weight int) {
+	if weight <= 0 {
+		return
+	}
+
+	for _, d := range t.dimensions {
+		if key, ok := d.key(r); ok {
+			d.summary.HitN(key, weight)
+		}
+	}
+}
+
+// Summary returns the summary of the dimension with the given name.
+func (t *Tracker) Summary(name string) (*hh.Synchronized[string], bool) {
+	for _, d := range t.dimensions {
+		if d.name == name {
+			return d.summary, true
+		}
+	}
+
+	return nil, false
+}
+
+// Top queries the top-k keys of every dimension, as results named by the dimensions.
+func (t *Tracker) Top(k int) []report.Result {
+	results := make([]report.Result, 0, len(t.dimensions))
+
+	for _, d := range t.dimensions {
+		d.summary.Do(func(summary hh.WeightedHeavyHitters[string]) {
+			result := report.Top[string](summary, k)
+			result.Name = d.name
+			results = append(results, result)
+		})
+	}
+
+	return results
+}
+
+// Handler returns the debug handler, which responds to GET requests with the top-k keys of every dimension.
+// The k query parameter defaults to 10 and is at most report.MaxK, the dimension query parameter restricts the response to a single dimension,
+// and the format query parameter names a format of the report package, table by default.
+func (t *Tracker) Handler() http.Handler {
+	return http.HandlerFunc(t.serveDebug)
+}
+
+func (t *Tracker) serveDebug(w http.ResponseWriter, r *http.Request) {
+	results := t.Top
+
+	if name := r.URL.Query().Get("dimension"); name != "" {
+		if !slices.ContainsFunc(t.dimensions, func(d trackedDimension) bool { return d.name == name }) {
+			http.Error(w, fmt.Sprintf("unknown dimension %q", name), http.StatusNotFound)
+			return
+		}
+
+		results = func(k int) []report.Result {
+			return slices.DeleteFunc(t.Top(k), func(result report.Result) bool {
+				return result.Name != name
+			})
+		}
+	}
+
+	report.Handler(results, "table", report.WithPreamble(fmt.Sprintf("Weight: %v\n\n", t.weight))).ServeHTTP(w, r)
+}
+
+// responseRecorder counts the bytes written to the body of a response.
+type responseRecorder struct {
+	http.ResponseWriter
+	written int64
+}
+
+func (r *responseRecorder) Write(p []byte) (int, error) {
+	n, err := r.ResponseWriter.Write(p)
+	r.written += int64(n)
+
+	return n, err
+}
+
+// Unwrap exposes the wrapped writer to http.ResponseController, for flushing and hijacking.
+func (r *responseRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// Flush implements http.Flusher, which handlers often check for directly rather than through http.ResponseController.
+func (r *responseRecorder) Flush() {
+	_ = http.NewResponseController(r.ResponseWriter).Flush()
+}
@@ -0,0 +1,211 @@
+package middleware
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func serve(t *testing.T, handler http.Handler, remoteAddr, path, agent string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, path, nil)
+	r.RemoteAddr = remoteAddr
+	r.Header.Set("User-Agent", agent)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	return w
+}
+
+func top(t *testing.T, tracker *Tracker, name string, k int) []string {
+	summary, found := tracker.Summary(name)
+	require.True(t, found)
+
+	keys, _, _ := summary.Top(k)
+
+	return keys
+}
+
+func TestTracker(t *testing.T) {
+	tracker, err := New()
+	require.NoError(t, err)
+
+	handler := tracker.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}))
+
+	serve(t, handler, "10.0.0.1:1234", "/a", "curl/8")
+	serve(t, handler, "10.0.0.1:1235", "/b", "curl/8")
+	serve(t, handler, "10.0.0.2:1234", "/a", "")
+	require.Equal(t, "ok", serve(t, handler, "10.0.0.1:1236", "/a", "Go-http-client/1.1").Body.String())
+
+	require.Equal(t, []string{"10.0.0.1", "10.0.0.2"}, top(t, tracker, "clients", 2))
+	require.Equal(t, []string{"/a", "/b"}, top(t, tracker, "paths", 2))
+	require.Equal(t, []string{"curl/8", "Go-http-client/1.1"}, top(t, tracker, "user_agents", 2))
+
+	summary, _ := tracker.Summary("user_agents")
+	require.Equal(t, 3, summary.Hits())
+
+	_, found := tracker.Summary("unknown")
+	require.False(t, found)
+}
+
+func TestTracker_WeightBytes(t *testing.T) {
+	tracker, err := New(WithWeight(WeightBytes))
+	require.NoError(t, err)
+
+	handler := tracker.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(strings.Repeat("x", len(r.URL.Path))))
+		http.NewResponseController(w).Flush()
+	}))
+
+	serve(t, handler, "10.0.0.1:1", "/large", "")
+	serve(t, handler, "10.0.0.2:1", "/a", "")
+	serve(t, handler, "10.0.0.2:1", "/b", "")
+	w := serve(t, handler, "10.0.0.2:1", "/", "")
+	require.True(t, w.Flushed)
+
+	summary, _ := tracker.Summary("clients")
+	count, _ := summary.Get("10.0.0.1")
+	require.Equal(t, 6, count.Count)
+	count, _ = summary.Get("10.0.0.2")
+	require.Equal(t, 5, count.Count)
+	require.Equal(t, 11, summary.Hits())
+}
+
+func TestTracker_WeightLatency(t *testing.T) {
+	tracker, err := New(WithWeight(WeightLatency))
+	require.NoError(t, err)
+
+	clock := time.Unix(0, 0)
+	tracker.now = func() time.Time {
+		return clock
+	}
+
+	handler := tracker.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			clock = clock.Add(3 * time.Millisecond)
+		} else {
+			clock = clock.Add(time.Millisecond)
+		}
+	}))
+
+	serve(t, handler, "10.0.0.1:1", "/slow", "")
+	serve(t, handler, "10.0.0.1:1", "/fast", "")
+	serve(t, handler, "10.0.0.1:1", "/fast", "")
+
+	summary, _ := tracker.Summary("paths")
+	count, _ := summary.Get("/slow")
+	require.Equal(t, 3000, count.Count)
+	count, _ = summary.Get("/fast")
+	require.Equal(t, 2000, count.Count)
+}
+
+func TestTracker_Panic(t *testing.T) {
+	tracker, err := New()
+	require.NoError(t, err)
+
+	handler := tracker.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	}))
+
+	require.Panics(t, func() {
+		serve(t, handler, "10.0.0.1:1", "/", "")
+	})
+	require.Equal(t, []string{"10.0.0.1"}, top(t, tracker, "clients", 1))
+}
+
+func TestTracker_Dimensions(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /items/{id}", func(w http.ResponseWriter, r *http.Request) {})
+
+	tracker, err := New(
+		WithDimension("user_agents", nil),
+		WithDimension("clients", ForwardedIP),
+		WithDimension("routes", Route(mux)),
+	)
+	require.NoError(t, err)
+
+	handler := tracker.Wrap(mux)
+
+	r := httptest.NewRequest(http.MethodGet, "/items/1", nil)
+	r.Header.Set("X-Forwarded-For", "192.0.2.1, 10.0.0.1")
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+	serve(t, handler, "10.0.0.2:1", "/items/2", "")
+	serve(t, handler, "10.0.0.2:1", "/missing", "")
+
+	require.Equal(t, []string{"10.0.0.2", "192.0.2.1"}, top(t, tracker, "clients", 2))
+	require.Equal(t, []string{"GET /items/{id}"}, top(t, tracker, "routes", 2))
+
+	_, found := tracker.Summary("user_agents")
+	require.False(t, found)
+
+	var names []string
+	for _, result := range tracker.Top(1) {
+		names = append(names, result.Name)
+	}
+
+	require.Equal(t, []string{"clients", "paths", "routes"}, names)
+}
+
+func TestTracker_Handler(t *testing.T) {
+	tracker, err := New()
+	require.NoError(t, err)
+
+	tracker.Track(httptest.NewRequest(http.MethodGet, "/a", nil), 2)
+	tracker.Track(httptest.NewRequest(http.MethodGet, "/b", nil), 1)
+	tracker.Track(httptest.NewRequest(http.MethodGet, "/c", nil), 0)
+
+	mux := http.NewServeMux()
+	mux.Handle(DebugPath, tracker.Handler())
+
+	w := serve(t, mux, "", DebugPath+"?dimension=paths&k=1&format=json", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+	var results []struct {
+		Name string
+		Hits int
+		Rows []struct {
+			Key   string
+			Count int
+		}
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &results))
+	require.Len(t, results, 1)
+	require.Equal(t, "paths", results[0].Name)
+	require.Equal(t, 3, results[0].Hits)
+	require.Len(t, results[0].Rows, 1)
+	require.Equal(t, "/a", results[0].Rows[0].Key)
+	require.Equal(t, 2, results[0].Rows[0].Count)
+
+	w = serve(t, mux, "", DebugPath, "")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.True(t, strings.HasPrefix(w.Body.String(), "Weight: requests\n"))
+	require.Contains(t, w.Body.String(), "user_agents")
+
+	require.Equal(t, http.StatusNotFound, serve(t, mux, "", DebugPath+"?dimension=unknown", "").Code)
+	require.Equal(t, http.StatusBadRequest, serve(t, mux, "", DebugPath+"?k=0", "").Code)
+	require.Equal(t, http.StatusBadRequest, serve(t, mux, "", DebugPath+"?k=1099511627776", "").Code)
+	require.Equal(t, http.StatusBadRequest, serve(t, mux, "", DebugPath+"?format=xml", "").Code)
+
+	r := httptest.NewRequest(http.MethodPost, DebugPath, nil)
+	recorder := httptest.NewRecorder()
+	mux.ServeHTTP(recorder, r)
+	require.Equal(t, http.StatusMethodNotAllowed, recorder.Code)
+}
+
+func TestNew_Invalid(t *testing.T) {
+	_, err := New(WithCapacity(0))
+	require.Error(t, err)
+
+	_, err = New(WithWeight(Weight(7)))
+	require.Error(t, err)
+
+	_, err = New(WithDimension("clients", nil), WithDimension("paths", nil), WithDimension("user_agents", nil))
+	require.Error(t, err)
+}
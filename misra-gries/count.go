@@ -4,6 +4,7 @@ import (
 	"container/list"
 	"errors"
 	"math"
+	"unsafe"
 )
 
 type pair struct {
@@ -33,6 +34,21 @@ func (m *MisraGries) Hits() int {
 	return int(m.hits)
 }
 
+// MemoryUsage estimates the number of bytes used by the counters, including the bytes referenced by their keys.
+func (m *MisraGries) MemoryUsage() int {
+	var element list.Element
+	var p pair
+
+	usage := int(unsafe.Sizeof(*m))
+
+	for e := m.pairs.Front(); e != nil; e = e.Next() {
+		// each pair is boxed in an interface value on the heap.
+		usage += int(unsafe.Sizeof(element)+unsafe.Sizeof(p)) + len(e.Value.(pair).key)
+	}
+
+	return usage
+}
+
 func (m *MisraGries) Query(key string) (int, int) {
 	element := m.find(key)
 	err := int(math.Ceil(m.epsilon * m.hits))
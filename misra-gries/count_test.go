@@ -27,3 +27,13 @@ func TestMisraGries(t *testing.T) {
 	require.Equal(t, 1, low)
 	require.Equal(t, 3, high)
 }
+
+func TestMisraGries_MemoryUsage(t *testing.T) {
+	hh, err := NewMisraGries(0.125)
+	require.NoError(t, err)
+
+	empty := hh.MemoryUsage()
+
+	hh.Hit("abcd")
+	require.Greater(t, hh.MemoryUsage(), empty+4)
+}
@@ -2,18 +2,39 @@ package heavy_hitters
 
 import (
 	"cmp"
+	"fmt"
 	"math"
+	"slices"
 	"sort"
 )
 
 // NaiveHeavyHitters tracks the frequency of elements in a stream by storing all frequencies in a map.
 type NaiveHeavyHitters[T cmp.Ordered] struct {
 	counts map[T]int
+	// The order of elements with the same frequency in the results of Top and Frequent.
+	tieBreak TieBreak
+	// The order in which elements were first counted, only maintained for TieBreakArrival.
+	arrivals map[T]int
 }
 
 func (n NaiveHeavyHitters[T]) Hit(t T) Count {
+	return n.HitN(t, 1)
+}
+
+func (n NaiveHeavyHitters[T]) HitN(t T, weight int) Count {
 	count, _ := n.counts[t]
-	n.counts[t] = count + 1
+
+	if weight <= 0 {
+		return Count{
+			Count: count,
+		}
+	}
+
+	n.counts[t] = saturatingAdd(count, weight)
+
+	if n.arrivals != nil && count == 0 {
+		n.arrivals[t] = len(n.arrivals)
+	}
 
 	return Count{
 		Count: n.counts[t],
@@ -24,7 +45,7 @@ func (n NaiveHeavyHitters[T]) Hits() int {
 	var hits int
 
 	for _, count := range n.counts {
-		hits += count
+		hits = saturatingAdd(hits, count)
 	}
 
 	return hits
@@ -47,9 +68,7 @@ func (n NaiveHeavyHitters[T]) Frequent(phi float64) ([]T, bool) {
 		}
 	}
 
-	sort.Slice(frequent, func(i, j int) bool {
-		return n.counts[frequent[i]] > n.counts[frequent[j]]
-	})
+	n.sort(frequent)
 
 	return frequent, true
 }
@@ -61,15 +80,61 @@ func (n NaiveHeavyHitters[T]) Top(k int) ([]T, bool, bool) {
 		top = append(top, element)
 	}
 
-	sort.Slice(top, func(i, j int) bool {
-		return n.counts[top[i]] > n.counts[top[j]]
-	})
+	n.sort(top)
 
 	return top[0:min(k, len(top))], true, true
 }
 
-func NewNaive[T cmp.Ordered]() NaiveHeavyHitters[T] {
-	return NaiveHeavyHitters[T]{
-		counts: make(map[T]int),
+// sort orders the elements in descending order of frequency, breaking ties according to the tie-break policy.
+func (n NaiveHeavyHitters[T]) sort(elements []T) {
+	if n.tieBreak == TieBreakNone {
+		sort.Slice(elements, func(i, j int) bool {
+			return n.counts[elements[i]] > n.counts[elements[j]]
+		})
+
+		return
+	}
+
+	slices.SortFunc(elements, func(a, b T) int {
+		if c := cmp.Compare(n.counts[b], n.counts[a]); c != 0 {
+			return c
+		}
+
+		// the error is always zero, so both TieBreakKey and TieBreakError fall back to the key.
+		if n.tieBreak == TieBreakArrival {
+			return cmp.Compare(n.arrivals[a], n.arrivals[b])
+		}
+
+		return cmp.Compare(a, b)
+	})
+}
+
+// NewNaive creates a new instance of a naive heavy hitters implementation.
+// Only the [WithTieBreak] option applies to NaiveHeavyHitters.
+// NewNaive panics with an error wrapping [ErrInvalidOption] if the tie-break policy is unknown or another option is given,
+// consistently with [NewStreamSummary].
+func NewNaive[T cmp.Ordered](opts ...Option[T]) NaiveHeavyHitters[T] {
+	var o options[T]
+
+	for _, opt := range opts {
+		opt(&o)
 	}
+
+	switch {
+	case !o.tieBreak.valid():
+		panic(fmt.Errorf("%w: unknown tie-break policy %d", ErrInvalidOption, o.tieBreak))
+	case o.sizings > 0 || o.averageKeySize != 0 || o.onEvict != nil || o.onTopKChange != nil:
+		panic(fmt.Errorf("%w: only WithTieBreak applies to NaiveHeavyHitters", ErrInvalidOption))
+	}
+
+	n := NaiveHeavyHitters[T]{
+		counts:   make(map[T]int),
+		tieBreak: o.tieBreak,
+	}
+
+	if o.tieBreak == TieBreakArrival {
+		n.arrivals = make(map[T]int)
+	}
+
+	return n
 }
@@ -77,6 +77,65 @@ func TestNaiveHeavyHitters(t *testing.T) {
 	require.Equal(t, Count{Count: 0, Error: 0}, count)
 }
 
+func TestNaiveHeavyHitters_TieBreak(t *testing.T) {
+	policies := map[TieBreak][]string{
+		TieBreakKey:     {"a", "b", "c"},
+		TieBreakArrival: {"c", "b", "a"},
+		TieBreakError:   {"a", "b", "c"},
+	}
+
+	for policy, expected := range policies {
+		t.Run(policy.String(), func(t *testing.T) {
+			hh := NewNaive[string](WithTieBreak[string](policy))
+
+			for _, e := range []string{"c", "b", "a", "a", "b", "c", "d"} {
+				hh.Hit(e)
+			}
+
+			top, _, _ := hh.Top(3)
+			require.Equal(t, expected, top)
+
+			frequent, _ := hh.Frequent(0.1)
+			require.Equal(t, expected, frequent)
+		})
+	}
+}
+
+func TestNewNaive_Invalid(t *testing.T) {
+	invalid := map[string][]Option[string]{
+		"tie-break": {WithTieBreak[string](99)},
+		"capacity":  {WithCapacity[string](10)},
+		"evict":     {WithOnEvict(func(string, Count) {})},
+	}
+
+	for name, opts := range invalid {
+		t.Run(name, func(t *testing.T) {
+			defer func() {
+				err, _ := recover().(error)
+				require.ErrorIs(t, err, ErrInvalidOption)
+			}()
+
+			NewNaive[string](opts...)
+		})
+	}
+}
+
+func TestNaiveHeavyHitters_HitN(t *testing.T) {
+	hh := NewNaive[string]()
+
+	require.Equal(t, Count{Count: 3}, hh.HitN("a", 3))
+	require.Equal(t, Count{Count: 4}, hh.HitN("a", 1))
+	require.Equal(t, Count{Count: 0}, hh.HitN("b", -1))
+	require.Equal(t, 4, hh.Hits())
+
+	_, found := hh.counts["b"]
+	require.False(t, found)
+
+	require.Equal(t, Count{Count: math.MaxInt}, hh.HitN("b", math.MaxInt))
+	require.Equal(t, Count{Count: math.MaxInt}, hh.HitN("a", math.MaxInt))
+	require.Equal(t, math.MaxInt, hh.Hits())
+}
+
 func BenchmarkNaive(b *testing.B) {
 	seed := time.Now().UTC().UnixNano()
 
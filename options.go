@@ -0,0 +1,145 @@
+package heavy_hitters
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// ErrInvalidOption is returned by New when the given options do not describe a valid summary.
+var ErrInvalidOption = errors.New("invalid option")
+
+// MaxCapacity is the largest capacity of a summary created by [New], which already takes gigabytes of memory once full.
+// It keeps a tiny epsilon or a mistyped capacity from exhausting memory or overflowing the capacity.
+const MaxCapacity = 1 << 24
+
+// Option configures optional behavior of a [StreamSummary] at construction time.
+type Option[T cmp.Ordered] func(*options[T])
+
+// options holds the configuration collected from a list of Option values.
+type options[T cmp.Ordered] struct {
+	// The number of sizing options that were applied; exactly one is required.
+	sizings int
+	// capacity computes the capacity described by the sizing option.
+	capacity       func() (int, error)
+	averageKeySize int
+	tieBreak       TieBreak
+	onEvict        func(T, Count)
+	topK           int
+	onTopKChange   func(entered, left []T)
+}
+
+// WithCapacity sizes the summary to monitor at most capacity elements, up to [MaxCapacity].
+func WithCapacity[T cmp.Ordered](capacity int) Option[T] {
+	return func(o *options[T]) {
+		o.sizings++
+		o.capacity = func() (int, error) {
+			if capacity <= 0 {
+				return 0, fmt.Errorf("%w: capacity must be positive, got %d", ErrInvalidOption, capacity)
+			}
+
+			return capacity, nil
+		}
+	}
+}
+
+// WithEpsilon sizes the summary such that the error for frequency approximations is bounded by epsilon * Hits.
+// The resulting capacity is ceil(1 / epsilon), which must not exceed [MaxCapacity].
+func WithEpsilon[T cmp.Ordered](epsilon float64) Option[T] {
+	return func(o *options[T]) {
+		o.sizings++
+		o.capacity = func() (int, error) {
+			if !(epsilon > 0 && epsilon <= 1) {
+				return 0, fmt.Errorf("%w: epsilon must be in the range (0, 1], got %v", ErrInvalidOption, epsilon)
+			}
+
+			// the capacity is checked before converting it, since a tiny epsilon overflows an int.
+			capacity := math.Ceil(1.0 / epsilon)
+			if capacity > MaxCapacity {
+				return 0, fmt.Errorf("%w: epsilon %v needs a capacity of %v, above the maximum of %d", ErrInvalidOption, epsilon, capacity, MaxCapacity)
+			}
+
+			return int(capacity), nil
+		}
+	}
+}
+
+// WithMemoryBudget sizes the summary to the largest capacity whose [StreamSummary.MemoryUsage] fits in the given number of bytes once full,
+// up to [MaxCapacity].
+// Use [WithAverageKeySize] to account for the bytes referenced by string keys.
+func WithMemoryBudget[T cmp.Ordered](bytes int) Option[T] {
+	return func(o *options[T]) {
+		o.sizings++
+		o.capacity = func() (int, error) {
+			if o.averageKeySize < 0 {
+				return 0, fmt.Errorf("%w: average key size must not be negative, got %d", ErrInvalidOption, o.averageKeySize)
+			}
+
+			capacity := capacityForMemoryBudget(bytes, func(capacity int) int {
+				return streamSummaryUsage[T](capacity, capacity, capacity+1, capacity*o.averageKeySize)
+			})
+
+			if capacity <= 0 {
+				return 0, fmt.Errorf("%w: memory budget of %d bytes does not fit a single counter", ErrInvalidOption, bytes)
+			}
+
+			return min(capacity, MaxCapacity), nil
+		}
+	}
+}
+
+// WithAverageKeySize sets the expected number of bytes referenced by each key when sizing the summary with [WithMemoryBudget].
+// Only string keys reference bytes outside of the counters; the size of other keys is already accounted for.
+func WithAverageKeySize[T cmp.Ordered](bytes int) Option[T] {
+	return func(o *options[T]) {
+		o.averageKeySize = bytes
+	}
+}
+
+// WithTieBreak selects the order of elements with the same frequency in the results of Top and Frequent.
+// Any policy other than [TieBreakNone] makes the results deterministic for a given input stream.
+func WithTieBreak[T cmp.Ordered](policy TieBreak) Option[T] {
+	return func(o *options[T]) {
+		o.tieBreak = policy
+	}
+}
+
+// WithOnEvict registers a callback that is invoked synchronously whenever Hit replaces a monitored element.
+// The callback receives the evicted element along with the count it had at the time of eviction.
+func WithOnEvict[T cmp.Ordered](fn func(key T, count Count)) Option[T] {
+	return func(o *options[T]) {
+		o.onEvict = fn
+	}
+}
+
+// WithOnTopKChange registers a callback that is invoked synchronously whenever the set of top-k elements changes.
+// The entered slice holds the elements that joined the top-k in descending order of frequency.
+// The left slice holds the elements that dropped out of the top-k in the order they were previously ranked.
+func WithOnTopKChange[T cmp.Ordered](k int, fn func(entered, left []T)) Option[T] {
+	return func(o *options[T]) {
+		o.topK = k
+		o.onTopKChange = fn
+	}
+}
+
+// validate checks the collected options for consistency and returns the capacity they describe.
+func (o *options[T]) validate() (int, error) {
+	switch {
+	case o.sizings == 0:
+		return 0, fmt.Errorf("%w: one of WithCapacity, WithEpsilon or WithMemoryBudget is required", ErrInvalidOption)
+	case o.sizings > 1:
+		return 0, fmt.Errorf("%w: only one of WithCapacity, WithEpsilon or WithMemoryBudget may be given", ErrInvalidOption)
+	case !o.tieBreak.valid():
+		return 0, fmt.Errorf("%w: unknown tie-break policy %d", ErrInvalidOption, o.tieBreak)
+	case o.onTopKChange != nil && o.topK <= 0:
+		return 0, fmt.Errorf("%w: k for top-k change notifications must be positive, got %d", ErrInvalidOption, o.topK)
+	}
+
+	capacity, err := o.capacity()
+	if err == nil && capacity > MaxCapacity {
+		err = fmt.Errorf("%w: capacity %d is above the maximum of %d", ErrInvalidOption, capacity, MaxCapacity)
+	}
+
+	return capacity, err
+}
@@ -0,0 +1,50 @@
+package heavy_hitters
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestNew_Sizing(t *testing.T) {
+	hh, err := New(WithCapacity[string](3))
+	require.NoError(t, err)
+	require.Equal(t, 3, hh.buckets.Tail().Value.counts.Len())
+
+	hh, err = New(WithEpsilon[string](0.3))
+	require.NoError(t, err)
+	require.Equal(t, 4, hh.buckets.Tail().Value.counts.Len())
+
+	hh, err = New(WithMemoryBudget[string](streamSummaryUsage[string](10, 10, 11, 0)))
+	require.NoError(t, err)
+	require.Equal(t, 10, hh.buckets.Tail().Value.counts.Len())
+}
+
+func TestNew_Invalid(t *testing.T) {
+	invalid := map[string][]Option[int]{
+		"none":              nil,
+		"zero capacity":     {WithCapacity[int](0)},
+		"negative capacity": {WithCapacity[int](-1)},
+		"zero epsilon":      {WithEpsilon[int](0)},
+		"large epsilon":     {WithEpsilon[int](1.5)},
+		"tiny epsilon":      {WithEpsilon[int](1e-9)},
+		"overflow epsilon":  {WithEpsilon[int](1e-300)},
+		"large capacity":    {WithCapacity[int](MaxCapacity + 1)},
+		"small budget":      {WithMemoryBudget[int](1)},
+		"conflicting":       {WithCapacity[int](1), WithEpsilon[int](0.5)},
+		"top-k":             {WithCapacity[int](1), WithOnTopKChange(0, func(_, _ []int) {})},
+	}
+
+	for name, opts := range invalid {
+		t.Run(name, func(t *testing.T) {
+			hh, err := New(opts...)
+			require.ErrorIs(t, err, ErrInvalidOption)
+			require.Nil(t, hh)
+		})
+	}
+}
+
+func TestNewStreamSummary_Invalid(t *testing.T) {
+	require.Panics(t, func() {
+		NewStreamSummary[int](0)
+	})
+}
@@ -0,0 +1,143 @@
+package pcap
+
+import (
+	"fmt"
+	hh "heavy-hitters"
+	"io"
+)
+
+// Weight is what each packet counts for in the summaries of an Analyzer.
+type Weight int
+
+const (
+	// Packets counts each packet once.
+	Packets Weight = iota
+	// Bytes counts each packet as its length on the wire.
+	Bytes
+)
+
+// ParseWeight parses the name of a weight, as returned by [Weight.String].
+func ParseWeight(name string) (Weight, error) {
+	for _, w := range []Weight{Packets, Bytes} {
+		if w.String() == name {
+			return w, nil
+		}
+	}
+
+	return 0, fmt.Errorf("unknown weight %q", name)
+}
+
+func (w Weight) String() string {
+	switch w {
+	case Packets:
+		return "packets"
+	case Bytes:
+		return "bytes"
+	default:
+		return fmt.Sprintf("Weight(%d)", int(w))
+	}
+}
+
+// Stats reports the number of packets processed from a capture.
+type Stats struct {
+	// Packets is the number of packets counted by the summaries.
+	Packets int64
+	// Bytes is the total length on the wire of the packets counted by the summaries.
+	Bytes int64
+	// Skipped is the number of packets that are not IPv4 or IPv6 packets, or whose headers are truncated.
+	Skipped int64
+}
+
+// Add accumulates the stats of another capture.
+func (s *Stats) Add(other Stats) {
+	s.Packets += other.Packets
+	s.Bytes += other.Bytes
+	s.Skipped += other.Skipped
+}
+
+// Analyzer summarizes the flows of packets along with their source and destination addresses.
+// Each of them is summarized by its own StreamSummary, keyed by the string form of the flow or address.
+type Analyzer struct {
+	weight       Weight
+	flows        *hh.StreamSummary[string]
+	sources      *hh.StreamSummary[string]
+	destinations *hh.StreamSummary[string]
+}
+
+// NewAnalyzer creates an analyzer whose summaries are created with the options.
+func NewAnalyzer(weight Weight, opts ...hh.Option[string]) (*Analyzer, error) {
+	if weight != Packets && weight != Bytes {
+		return nil, fmt.Errorf("unknown weight %d", int(weight))
+	}
+
+	a := &Analyzer{weight: weight}
+
+	for _, summary := range []**hh.StreamSummary[string]{&a.flows, &a.sources, &a.destinations} {
+		s, err := hh.New(opts...)
+		if err != nil {
+			return nil, err
+		}
+
+		*summary = s
+	}
+
+	return a, nil
+}
+
+// Flows returns the summary of flows, formatted by [Flow.String].
+func (a *Analyzer) Flows() *hh.StreamSummary[string] {
+	return a.flows
+}
+
+// Sources returns the summary of source addresses.
+func (a *Analyzer) Sources() *hh.StreamSummary[string] {
+	return a.sources
+}
+
+// Destinations returns the summary of destination addresses.
+func (a *Analyzer) Destinations() *hh.StreamSummary[string] {
+	return a.destinations
+}
+
+// Analyze reads every packet of the capture and adds it to the summaries.
+// The stats cover everything processed up to the point an error occurred, if any.
+func (a *Analyzer) Analyze(r *Reader) (Stats, error) {
+	var stats Stats
+
+	for {
+		packet, err := r.ReadPacket()
+		if err == io.EOF {
+			return stats, nil
+		} else if err != nil {
+			return stats, err
+		}
+
+		if a.Add(packet) {
+			stats.Packets++
+			stats.Bytes += int64(packet.Length)
+		} else {
+			stats.Skipped++
+		}
+	}
+}
+
+// Add decodes the flow of a packet and hits the summaries with it.
+// The boolean is false iff the flow of the packet cannot be decoded, in which case nothing is counted.
+func (a *Analyzer) Add(packet Packet) bool {
+	flow, ok := Decode(packet.LinkType, packet.Data)
+	if !ok {
+		return false
+	}
+
+	weight := 1
+
+	if a.weight == Bytes {
+		weight = packet.Length
+	}
+
+	a.flows.HitN(flow.String(), weight)
+	a.sources.HitN(flow.Source.Addr().String(), weight)
+	a.destinations.HitN(flow.Destination.Addr().String(), weight)
+
+	return true
+}
@@ -0,0 +1,78 @@
+package pcap
+
+import (
+	"github.com/stretchr/testify/require"
+	hh "heavy-hitters"
+	"os"
+	"testing"
+)
+
+func analyze(t *testing.T, path string, weight Weight) (*Analyzer, Stats) {
+	t.Helper()
+
+	f, err := os.Open(path)
+	require.NoError(t, err)
+
+	defer f.Close()
+
+	r, err := NewReader(f)
+	require.NoError(t, err)
+
+	analyzer, err := NewAnalyzer(weight, hh.WithCapacity[string](10), hh.WithTieBreak[string](hh.TieBreakKey))
+	require.NoError(t, err)
+
+	stats, err := analyzer.Analyze(r)
+	require.NoError(t, err)
+
+	return analyzer, stats
+}
+
+func TestAnalyzer_Packets(t *testing.T) {
+	analyzer, stats := analyze(t, "testdata/flows.pcap", Packets)
+	require.Equal(t, Stats{Packets: 13, Bytes: 7724, Skipped: 1}, stats)
+
+	top, _, _ := analyzer.Flows().Top(3)
+	require.Equal(t, []string{"udp 10.0.0.3:5353 > 224.0.0.251:5353", "tcp 10.0.0.1:51234 > 10.0.0.2:443", "tcp 10.0.0.2:443 > 10.0.0.1:51234"}, top)
+
+	top, _, _ = analyzer.Sources().Top(3)
+	require.Equal(t, []string{"10.0.0.3", "10.0.0.1", "10.0.0.2"}, top)
+
+	top, _, _ = analyzer.Destinations().Top(1)
+	require.Equal(t, []string{"224.0.0.251"}, top)
+	require.Equal(t, 13, analyzer.Flows().Hits())
+}
+
+func TestAnalyzer_Bytes(t *testing.T) {
+	analyzer, stats := analyze(t, "testdata/flows.pcap", Bytes)
+	require.Equal(t, int64(7724), stats.Bytes)
+
+	top, _, _ := analyzer.Flows().Top(2)
+	require.Equal(t, []string{"tcp 10.0.0.1:51234 > 10.0.0.2:443", "tcp 10.0.0.2:443 > 10.0.0.1:51234"}, top)
+
+	// truncated packets are weighted by their length on the wire.
+	count, _ := analyzer.Flows().Get("tcp 10.0.0.2:443 > 10.0.0.1:51234")
+	require.Equal(t, hh.Count{Count: 3028}, count)
+	require.Equal(t, 7724, analyzer.Sources().Hits())
+}
+
+func TestAnalyzer_Pcapng(t *testing.T) {
+	analyzer, stats := analyze(t, "testdata/flows.pcapng", Packets)
+	require.Equal(t, int64(6), stats.Packets)
+
+	top, _, _ := analyzer.Sources().Top(3)
+	require.Equal(t, []string{"2001:db8::1", "10.0.0.1", "192.0.2.1"}, top)
+}
+
+func TestParseWeight(t *testing.T) {
+	for _, w := range []Weight{Packets, Bytes} {
+		parsed, err := ParseWeight(w.String())
+		require.NoError(t, err)
+		require.Equal(t, w, parsed)
+	}
+
+	_, err := ParseWeight("flows")
+	require.Error(t, err)
+
+	_, err = NewAnalyzer(Weight(42), hh.WithCapacity[string](1))
+	require.Error(t, err)
+}
@@ -0,0 +1,226 @@
+package pcap
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net/netip"
+)
+
+// LinkType is the type of the link-layer header of a packet, as registered at https://www.tcpdump.org/linktypes.html
+type LinkType uint32
+
+// The link types that can be decoded.
+const (
+	// LinkTypeNull is the BSD loopback header, holding the address family in host byte order.
+	LinkTypeNull LinkType = 0
+	// LinkTypeEthernet is an Ethernet header, optionally followed by 802.1Q VLAN tags.
+	LinkTypeEthernet LinkType = 1
+	// LinkTypeRaw is a raw IPv4 or IPv6 packet without a link-layer header.
+	LinkTypeRaw LinkType = 101
+	// LinkTypeLinuxSLL is the Linux "cooked" capture header used when capturing on all interfaces.
+	LinkTypeLinuxSLL LinkType = 113
+	// LinkTypeIPv4 is a raw IPv4 packet without a link-layer header.
+	LinkTypeIPv4 LinkType = 228
+	// LinkTypeIPv6 is a raw IPv6 packet without a link-layer header.
+	LinkTypeIPv6 LinkType = 229
+)
+
+// The protocol numbers of the transport protocols with ports.
+const (
+	ProtocolTCP = 6
+	ProtocolUDP = 17
+)
+
+// The EtherTypes of the network and VLAN headers.
+const (
+	etherTypeIPv4 = 0x0800
+	etherTypeIPv6 = 0x86dd
+	etherTypeVLAN = 0x8100
+	etherTypeQinQ = 0x88a8
+)
+
+// Flow is the 5-tuple of a packet: its transport protocol along with its source and destination addresses and ports.
+// The ports are zero for protocols other than TCP and UDP, and for fragments after the first one.
+type Flow struct {
+	Protocol    uint8
+	Source      netip.AddrPort
+	Destination netip.AddrPort
+}
+
+// String formats the flow as its protocol followed by its source and destination, such as "tcp 10.0.0.1:51234 > 10.0.0.2:443".
+func (f Flow) String() string {
+	switch f.Protocol {
+	case ProtocolTCP:
+		return fmt.Sprintf("tcp %s > %s", f.Source, f.Destination)
+	case ProtocolUDP:
+		return fmt.Sprintf("udp %s > %s", f.Source, f.Destination)
+	case 1:
+		return fmt.Sprintf("icmp %s > %s", f.Source.Addr(), f.Destination.Addr())
+	case 58:
+		return fmt.Sprintf("icmpv6 %s > %s", f.Source.Addr(), f.Destination.Addr())
+	default:
+		return fmt.Sprintf("ip-proto-%d %s > %s", f.Protocol, f.Source.Addr(), f.Destination.Addr())
+	}
+}
+
+// Decode parses the link-layer, network and transport headers of a packet into its flow.
+// The boolean is false iff the packet is not an IPv4 or IPv6 packet, or its headers are truncated.
+func Decode(linkType LinkType, data []byte) (Flow, bool) {
+	switch linkType {
+	case LinkTypeEthernet:
+		if len(data) < 14 {
+			return Flow{}, false
+		}
+
+		etherType := binary.BigEndian.Uint16(data[12:])
+		data = data[14:]
+
+		for etherType == etherTypeVLAN || etherType == etherTypeQinQ {
+			if len(data) < 4 {
+				return Flow{}, false
+			}
+
+			etherType = binary.BigEndian.Uint16(data[2:])
+			data = data[4:]
+		}
+
+		return decodeEtherType(etherType, data)
+	case LinkTypeLinuxSLL:
+		if len(data) < 16 {
+			return Flow{}, false
+		}
+
+		return decodeEtherType(binary.BigEndian.Uint16(data[14:]), data[16:])
+	case LinkTypeNull:
+		if len(data) < 4 {
+			return Flow{}, false
+		}
+
+		// the address family is in the byte order of the capturing host, and is small enough to fit a single byte.
+		family := min(binary.LittleEndian.Uint32(data), binary.BigEndian.Uint32(data))
+
+		switch family {
+		case 2:
+			return decodeIPv4(data[4:])
+		case 24, 28, 30:
+			// the value of AF_INET6 differs between BSDs.
+			return decodeIPv6(data[4:])
+		default:
+			return Flow{}, false
+		}
+	case LinkTypeRaw:
+		if len(data) == 0 {
+			return Flow{}, false
+		}
+
+		if data[0]>>4 == 6 {
+			return decodeIPv6(data)
+		}
+
+		return decodeIPv4(data)
+	case LinkTypeIPv4:
+		return decodeIPv4(data)
+	case LinkTypeIPv6:
+		return decodeIPv6(data)
+	default:
+		return Flow{}, false
+	}
+}
+
+func decodeEtherType(etherType uint16, data []byte) (Flow, bool) {
+	switch etherType {
+	case etherTypeIPv4:
+		return decodeIPv4(data)
+	case etherTypeIPv6:
+		return decodeIPv6(data)
+	default:
+		return Flow{}, false
+	}
+}
+
+func decodeIPv4(data []byte) (Flow, bool) {
+	if len(data) < 20 || data[0]>>4 != 4 {
+		return Flow{}, false
+	}
+
+	headerLength := int(data[0]&0x0f) * 4
+	if headerLength < 20 || len(data) < headerLength {
+		return Flow{}, false
+	}
+
+	protocol := data[9]
+	source := netip.AddrFrom4([4]byte(data[12:16]))
+	destination := netip.AddrFrom4([4]byte(data[16:20]))
+
+	// only the first fragment holds the transport header.
+	first := binary.BigEndian.Uint16(data[6:])&0x1fff == 0
+
+	return decodeTransport(protocol, source, destination, data[headerLength:], first), true
+}
+
+func decodeIPv6(data []byte) (Flow, bool) {
+	if len(data) < 40 || data[0]>>4 != 6 {
+		return Flow{}, false
+	}
+
+	protocol := data[6]
+	source := netip.AddrFrom16([16]byte(data[8:24]))
+	destination := netip.AddrFrom16([16]byte(data[24:40]))
+	data = data[40:]
+	first := true
+
+	// skip the extension headers to find the transport protocol.
+	for {
+		var length int
+
+		switch protocol {
+		case 0, 43, 60:
+			// hop-by-hop options, routing and destination options.
+			if len(data) < 2 {
+				return Flow{}, false
+			}
+
+			length = (int(data[1]) + 1) * 8
+		case 44:
+			// fragment, where only the first fragment holds the transport header.
+			if len(data) < 8 {
+				return Flow{}, false
+			}
+
+			length = 8
+			first = first && binary.BigEndian.Uint16(data[2:])&0xfff8 == 0
+		case 51:
+			// authentication header.
+			if len(data) < 2 {
+				return Flow{}, false
+			}
+
+			length = (int(data[1]) + 2) * 4
+		default:
+			return decodeTransport(protocol, source, destination, data, first), true
+		}
+
+		if len(data) < length {
+			return Flow{}, false
+		}
+
+		protocol = data[0]
+		data = data[length:]
+	}
+}
+
+// decodeTransport builds the flow of a packet, with the ports of its transport header if it has one.
+func decodeTransport(protocol uint8, source netip.Addr, destination netip.Addr, data []byte, first bool) Flow {
+	var sourcePort, destinationPort uint16
+
+	if (protocol == ProtocolTCP || protocol == ProtocolUDP) && first && len(data) >= 4 {
+		sourcePort = binary.BigEndian.Uint16(data)
+		destinationPort = binary.BigEndian.Uint16(data[2:])
+	}
+
+	return Flow{
+		Protocol:    protocol,
+		Source:      netip.AddrPortFrom(source, sourcePort),
+		Destination: netip.AddrPortFrom(destination, destinationPort),
+	}
+}
@@ -0,0 +1,95 @@
+package pcap
+
+import (
+	"github.com/stretchr/testify/require"
+	"net/netip"
+	"testing"
+)
+
+func TestDecode(t *testing.T) {
+	packets := readAll(t, "testdata/flows.pcap")
+
+	flow, ok := Decode(packets[0].LinkType, packets[0].Data)
+	require.True(t, ok)
+	require.Equal(t, Flow{
+		Protocol:    ProtocolTCP,
+		Source:      netip.MustParseAddrPort("10.0.0.1:51234"),
+		Destination: netip.MustParseAddrPort("10.0.0.2:443"),
+	}, flow)
+
+	expected := []string{
+		"tcp 10.0.0.1:51234 > 10.0.0.2:443",
+		"udp 10.0.0.3:5353 > 224.0.0.251:5353",
+		// tagged with a VLAN.
+		"udp 10.0.0.3:5353 > 224.0.0.251:5353",
+		// a fragment without a transport header.
+		"udp 10.0.0.3:0 > 224.0.0.251:0",
+		"icmp 10.0.0.1 > 10.0.0.2",
+		// truncated to its headers.
+		"tcp 10.0.0.2:443 > 10.0.0.1:51234",
+	}
+
+	for i, packet := range []Packet{packets[0], packets[4], packets[8], packets[9], packets[10], packets[12]} {
+		flow, ok := Decode(packet.LinkType, packet.Data)
+		require.True(t, ok)
+		require.Equal(t, expected[i], flow.String())
+	}
+
+	// ARP is not an IP protocol.
+	_, ok = Decode(packets[11].LinkType, packets[11].Data)
+	require.False(t, ok)
+}
+
+func TestDecode_IPv6(t *testing.T) {
+	packets := readAll(t, "testdata/flows.pcapng")
+
+	expected := []string{
+		"tcp [2001:db8::1]:40000 > [2001:db8::2]:80",
+		"tcp [2001:db8::1]:40000 > [2001:db8::2]:80",
+		// behind a hop-by-hop options header.
+		"tcp [2001:db8::1]:40000 > [2001:db8::2]:80",
+		"tcp 192.0.2.1:1234 > 192.0.2.2:22",
+		"udp 10.0.0.1:53000 > 10.0.0.53:53",
+		"udp 10.0.0.1:53000 > 10.0.0.53:53",
+	}
+
+	for i, packet := range packets {
+		flow, ok := Decode(packet.LinkType, packet.Data)
+		require.True(t, ok)
+		require.Equal(t, expected[i], flow.String())
+	}
+}
+
+func TestDecode_LinkTypes(t *testing.T) {
+	packets := readAll(t, "testdata/flows.pcap")
+	frame := packets[0].Data
+	ip := frame[14:]
+
+	tests := []struct {
+		name     string
+		linkType LinkType
+		data     []byte
+		ok       bool
+	}{
+		{"null", LinkTypeNull, append([]byte{2, 0, 0, 0}, ip...), true},
+		{"null big-endian", LinkTypeNull, append([]byte{0, 0, 0, 2}, ip...), true},
+		{"linux sll", LinkTypeLinuxSLL, append([]byte{0, 0, 0, 1, 0, 6, 2, 0, 0, 0, 0, 1, 0, 0, 0x08, 0x00}, ip...), true},
+		{"raw", LinkTypeRaw, ip, true},
+		{"ipv4", LinkTypeIPv4, ip, true},
+		{"qinq", LinkTypeEthernet, append(append(append([]byte{}, frame[:12]...), 0x88, 0xa8, 0, 1, 0x81, 0x00, 0, 2, 0x08, 0x00), ip...), true},
+		{"not ipv6", LinkTypeIPv6, ip, false},
+		{"truncated", LinkTypeIPv4, ip[:19], false},
+		{"unknown", LinkType(147), ip, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			flow, ok := Decode(test.linkType, test.data)
+			require.Equal(t, test.ok, ok)
+
+			if ok {
+				require.Equal(t, "tcp 10.0.0.1:51234 > 10.0.0.2:443", flow.String())
+			}
+		})
+	}
+}
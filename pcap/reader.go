@@ -0,0 +1,317 @@
+// Package pcap finds the heaviest flows in captured network traffic.
+//
+// A Reader reads the packets of classic pcap and pcapng capture files, Decode parses their headers into 5-tuple flows,
+// and an Analyzer summarizes the flows and their addresses, weighted by packets or by bytes.
+package pcap
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/bits"
+	"time"
+)
+
+// ErrFormat is returned when a capture file is not a valid pcap or pcapng file.
+var ErrFormat = errors.New("invalid capture file")
+
+// maxPacketSize bounds the captured length of a packet, so a corrupt length cannot exhaust memory.
+const maxPacketSize = 1 << 20
+
+// The magic numbers at the start of capture files.
+const (
+	pcapMicroseconds = 0xa1b2c3d4
+	pcapNanoseconds  = 0xa1b23c4d
+	pcapngSection    = 0x0a0d0d0a
+	pcapngByteOrder  = 0x1a2b3c4d
+)
+
+// The types of pcapng blocks holding packets or describing interfaces.
+const (
+	blockInterface      = 1
+	blockPacket         = 2
+	blockSimplePacket   = 3
+	blockEnhancedPacket = 6
+)
+
+// Packet is a packet read from a capture file.
+type Packet struct {
+	// LinkType is the link-layer header type of the data.
+	LinkType LinkType
+	// Timestamp is the time the packet was captured.
+	Timestamp time.Time
+	// Length is the length of the packet on the wire, which is larger than the data if the capture truncated it.
+	Length int
+	// Data holds the captured bytes of the packet, starting with its link-layer header.
+	Data []byte
+}
+
+// Reader reads the packets of a classic pcap or pcapng capture file.
+type Reader struct {
+	r     *bufio.Reader
+	order binary.ByteOrder
+	// The packet data is read into the buffer, which is reused by every packet.
+	buffer []byte
+	header [16]byte
+	// pcapng files can have several sections and interfaces, while classic pcap files have a single interface.
+	pcapng     bool
+	interfaces []captureInterface
+}
+
+// captureInterface describes the interface that captured packets.
+type captureInterface struct {
+	linkType LinkType
+	snapLen  int
+	// The number of timestamp units per second.
+	resolution uint64
+}
+
+// NewReader creates a reader of a capture file, detecting its format from the header.
+func NewReader(r io.Reader) (*Reader, error) {
+	reader := &Reader{r: bufio.NewReader(r)}
+
+	magic, err := reader.r.Peek(4)
+	if err != nil {
+		return nil, fmt.Errorf("%w: missing header", ErrFormat)
+	}
+
+	if binary.LittleEndian.Uint32(magic) == pcapngSection {
+		reader.pcapng = true
+		return reader, nil
+	}
+
+	header := make([]byte, 24)
+	if _, err := io.ReadFull(reader.r, header); err != nil {
+		return nil, fmt.Errorf("%w: missing header", ErrFormat)
+	}
+
+	iface := captureInterface{resolution: 1_000_000}
+
+	for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		switch order.Uint32(header) {
+		case pcapMicroseconds:
+			reader.order = order
+		case pcapNanoseconds:
+			reader.order = order
+			iface.resolution = 1_000_000_000
+		}
+	}
+
+	if reader.order == nil {
+		return nil, fmt.Errorf("%w: unknown magic number %#x", ErrFormat, binary.BigEndian.Uint32(header))
+	}
+
+	iface.snapLen = int(reader.order.Uint32(header[16:]))
+	// the upper bits of the link type may hold the length of the frame check sequence.
+	iface.linkType = LinkType(reader.order.Uint32(header[20:]) & 0x0fffffff)
+	reader.interfaces = []captureInterface{iface}
+
+	return reader, nil
+}
+
+// ReadPacket reads the next packet, or returns io.EOF at the end of the file.
+// The data of the packet is only valid until the next call to ReadPacket.
+func (r *Reader) ReadPacket() (Packet, error) {
+	if r.pcapng {
+		return r.readBlocks()
+	}
+
+	header := r.header[:16]
+	if _, err := io.ReadFull(r.r, header); err == io.EOF {
+		return Packet{}, io.EOF
+	} else if err != nil {
+		return Packet{}, fmt.Errorf("%w: truncated packet header", ErrFormat)
+	}
+
+	iface := r.interfaces[0]
+	seconds, fraction := r.order.Uint32(header), r.order.Uint32(header[4:])
+	captured, length := r.order.Uint32(header[8:]), r.order.Uint32(header[12:])
+
+	if captured > maxPacketSize {
+		return Packet{}, fmt.Errorf("%w: packet of %d bytes", ErrFormat, captured)
+	}
+
+	data, err := r.read(int(captured))
+	if err != nil {
+		return Packet{}, err
+	}
+
+	return Packet{
+		LinkType:  iface.linkType,
+		Timestamp: time.Unix(int64(seconds), int64(fraction)*int64(time.Second)/int64(iface.resolution)),
+		Length:    int(max(length, captured)),
+		Data:      data,
+	}, nil
+}
+
+// read reads n bytes into the buffer.
+func (r *Reader) read(n int) ([]byte, error) {
+	if cap(r.buffer) < n {
+		r.buffer = make([]byte, n)
+	}
+
+	if _, err := io.ReadFull(r.r, r.buffer[:n]); err != nil {
+		return nil, fmt.Errorf("%w: truncated packet", ErrFormat)
+	}
+
+	return r.buffer[:n], nil
+}
+
+// readBlocks reads pcapng blocks until the next packet.
+func (r *Reader) readBlocks() (Packet, error) {
+	for {
+		header := r.header[:8]
+		if _, err := io.ReadFull(r.r, header); err == io.EOF {
+			return Packet{}, io.EOF
+		} else if err != nil {
+			return Packet{}, fmt.Errorf("%w: truncated block header", ErrFormat)
+		}
+
+		blockType := binary.LittleEndian.Uint32(header)
+
+		if blockType == pcapngSection {
+			// a section starts with its byte order, so the length of the block can only be decoded after it.
+			magic, err := r.r.Peek(4)
+			if err != nil {
+				return Packet{}, fmt.Errorf("%w: truncated section header", ErrFormat)
+			}
+
+			switch {
+			case binary.LittleEndian.Uint32(magic) == pcapngByteOrder:
+				r.order = binary.LittleEndian
+			case binary.BigEndian.Uint32(magic) == pcapngByteOrder:
+				r.order = binary.BigEndian
+			default:
+				return Packet{}, fmt.Errorf("%w: unknown byte order magic %#x", ErrFormat, magic)
+			}
+
+			r.interfaces = r.interfaces[:0]
+		} else if r.order == nil {
+			return Packet{}, fmt.Errorf("%w: block of type %#x before the section header", ErrFormat, blockType)
+		} else {
+			blockType = r.order.Uint32(header)
+		}
+
+		length := r.order.Uint32(header[4:])
+		if length < 12 || length%4 != 0 || length > maxPacketSize {
+			return Packet{}, fmt.Errorf("%w: block of %d bytes", ErrFormat, length)
+		}
+
+		body, err := r.read(int(length) - 8)
+		if err != nil {
+			return Packet{}, err
+		}
+
+		// the body ends with a copy of the length of the block.
+		body = body[:len(body)-4]
+
+		packet, ok, err := r.decodeBlock(blockType, body)
+		if err != nil || ok {
+			return packet, err
+		}
+	}
+}
+
+// decodeBlock decodes the body of a pcapng block.
+// The boolean is true iff the block holds a packet; blocks of other types are skipped.
+func (r *Reader) decodeBlock(blockType uint32, body []byte) (Packet, bool, error) {
+	var interfaceID, timestampHigh, timestampLow, captured, length uint32
+	var data []byte
+
+	switch blockType {
+	case blockInterface:
+		if len(body) < 8 {
+			return Packet{}, false, fmt.Errorf("%w: truncated interface description", ErrFormat)
+		}
+
+		r.interfaces = append(r.interfaces, captureInterface{
+			linkType:   LinkType(r.order.Uint16(body)),
+			snapLen:    int(r.order.Uint32(body[4:])),
+			resolution: r.resolution(body[8:]),
+		})
+
+		return Packet{}, false, nil
+	case blockEnhancedPacket:
+		if len(body) < 20 {
+			return Packet{}, false, fmt.Errorf("%w: truncated enhanced packet", ErrFormat)
+		}
+
+		interfaceID = r.order.Uint32(body)
+		timestampHigh, timestampLow = r.order.Uint32(body[4:]), r.order.Uint32(body[8:])
+		captured, length = r.order.Uint32(body[12:]), r.order.Uint32(body[16:])
+		data = body[20:]
+	case blockPacket:
+		if len(body) < 20 {
+			return Packet{}, false, fmt.Errorf("%w: truncated packet", ErrFormat)
+		}
+
+		interfaceID = uint32(r.order.Uint16(body))
+		timestampHigh, timestampLow = r.order.Uint32(body[4:]), r.order.Uint32(body[8:])
+		captured, length = r.order.Uint32(body[12:]), r.order.Uint32(body[16:])
+		data = body[20:]
+	case blockSimplePacket:
+		if len(body) < 4 || len(r.interfaces) == 0 {
+			return Packet{}, false, fmt.Errorf("%w: invalid simple packet", ErrFormat)
+		}
+
+		// simple packets do not record their captured length, which is bounded by the snapshot length of the first interface.
+		length = r.order.Uint32(body)
+		data = body[4:]
+		captured = length
+
+		if snapLen := r.interfaces[0].snapLen; snapLen > 0 {
+			captured = min(captured, uint32(snapLen))
+		}
+	default:
+		return Packet{}, false, nil
+	}
+
+	if int(interfaceID) >= len(r.interfaces) || uint64(captured) > uint64(len(data)) {
+		return Packet{}, false, fmt.Errorf("%w: invalid packet of %d bytes on interface %d", ErrFormat, captured, interfaceID)
+	}
+
+	iface := r.interfaces[interfaceID]
+	timestamp := uint64(timestampHigh)<<32 | uint64(timestampLow)
+	// the fraction of a second is smaller than the resolution, so the quotient of its nanoseconds cannot overflow.
+	hi, lo := bits.Mul64(timestamp%iface.resolution, uint64(time.Second))
+	nanoseconds, _ := bits.Div64(hi, lo, iface.resolution)
+
+	return Packet{
+		LinkType:  iface.linkType,
+		Timestamp: time.Unix(int64(timestamp/iface.resolution), int64(nanoseconds)),
+		Length:    int(max(length, captured)),
+		Data:      data[:captured],
+	}, true, nil
+}
+
+// resolution finds the number of timestamp units per second in the options of an interface description, a million by default.
+func (r *Reader) resolution(options []byte) uint64 {
+	for len(options) >= 4 {
+		code, length := r.order.Uint16(options), int(r.order.Uint16(options[2:]))
+		padded := (length + 3) &^ 3
+
+		if code == 0 || len(options) < 4+padded {
+			break
+		}
+
+		// the if_tsresol option is a power of 10, or a power of 2 if its most significant bit is set.
+		if code == 9 && length == 1 {
+			exponent := options[4]
+
+			if exponent&0x80 != 0 && exponent&0x7f < 64 {
+				return 1 << (exponent & 0x7f)
+			}
+
+			if exponent <= 19 {
+				return uint64(math.Pow10(int(exponent)))
+			}
+		}
+
+		options = options[4+padded:]
+	}
+
+	return 1_000_000
+}
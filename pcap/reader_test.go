@@ -0,0 +1,106 @@
+package pcap
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/require"
+	"io"
+	"os"
+	"testing"
+	"time"
+)
+
+func readAll(t *testing.T, path string) []Packet {
+	t.Helper()
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	r, err := NewReader(bytes.NewReader(data))
+	require.NoError(t, err)
+
+	var packets []Packet
+
+	for {
+		packet, err := r.ReadPacket()
+		if err == io.EOF {
+			return packets
+		}
+
+		require.NoError(t, err)
+
+		packet.Data = bytes.Clone(packet.Data)
+		packets = append(packets, packet)
+	}
+}
+
+func TestReader_Pcap(t *testing.T) {
+	packets := readAll(t, "testdata/flows.pcap")
+	require.Len(t, packets, 14)
+
+	require.Equal(t, LinkTypeEthernet, packets[0].LinkType)
+	require.Equal(t, time.Unix(1760781600, 500_000_000), packets[0].Timestamp)
+	require.Equal(t, 1000, packets[0].Length)
+	require.Len(t, packets[0].Data, 1000)
+
+	// the last packets are truncated to their headers.
+	require.Equal(t, 1514, packets[13].Length)
+	require.Len(t, packets[13].Data, 54)
+}
+
+func TestReader_Pcapng(t *testing.T) {
+	packets := readAll(t, "testdata/flows.pcapng")
+	require.Len(t, packets, 6)
+
+	require.Equal(t, LinkTypeEthernet, packets[0].LinkType)
+	require.Equal(t, time.Unix(1760781600, 250), packets[0].Timestamp)
+	require.Equal(t, LinkTypeRaw, packets[3].LinkType)
+	require.Equal(t, time.Unix(0, 0), packets[3].Timestamp)
+
+	// simple packets have no timestamp and are captured on the first interface.
+	require.Equal(t, LinkTypeEthernet, packets[4].LinkType)
+	require.Equal(t, len(packets[4].Data), packets[4].Length)
+
+	// the second section is little-endian with microsecond timestamps.
+	require.Equal(t, time.Unix(1, 0), packets[5].Timestamp)
+}
+
+func TestReader_BigEndianNanoseconds(t *testing.T) {
+	frame := []byte{0x45, 0, 0, 20}
+
+	var file bytes.Buffer
+	file.Write([]byte{0xa1, 0xb2, 0x3c, 0x4d, 0, 2, 0, 4, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0xff, 0xff, 0, 0, 0, 101})
+	file.Write([]byte{0, 0, 0, 7, 0, 0, 0, 9, 0, 0, 0, 4, 0, 0, 0, 20})
+	file.Write(frame)
+
+	r, err := NewReader(&file)
+	require.NoError(t, err)
+
+	packet, err := r.ReadPacket()
+	require.NoError(t, err)
+	require.Equal(t, Packet{LinkType: LinkTypeRaw, Timestamp: time.Unix(7, 9), Length: 20, Data: frame}, packet)
+
+	_, err = r.ReadPacket()
+	require.Equal(t, io.EOF, err)
+}
+
+func TestReader_Invalid(t *testing.T) {
+	_, err := NewReader(bytes.NewReader([]byte("not a capture file at all")))
+	require.ErrorIs(t, err, ErrFormat)
+
+	_, err = NewReader(bytes.NewReader(nil))
+	require.ErrorIs(t, err, ErrFormat)
+
+	for _, path := range []string{"testdata/flows.pcap", "testdata/flows.pcapng"} {
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+
+		r, err := NewReader(bytes.NewReader(data[:len(data)-1]))
+		require.NoError(t, err)
+
+		for err == nil {
+			_, err = r.ReadPacket()
+		}
+
+		require.ErrorIs(t, err, ErrFormat, path)
+	}
+}
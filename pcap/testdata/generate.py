@@ -0,0 +1,119 @@
+#!/usr/bin/env python3
+"""Generates the capture files used by the tests of the pcap package.
+
+The files are assembled by hand following the pcap and pcapng specifications:
+https://www.ietf.org/archive/id/draft-ietf-opsawg-pcap-04.html
+https://www.ietf.org/archive/id/draft-ietf-opsawg-pcapng-01.html
+"""
+
+import ipaddress
+import struct
+
+TIMESTAMP = 1760781600
+
+
+def ipv4(protocol, source, destination, payload, fragment=0):
+    header = struct.pack(
+        ">BBHHHBBH4s4s", 0x45, 0, 20 + len(payload), 0, fragment, 64, protocol, 0,
+        ipaddress.IPv4Address(source).packed, ipaddress.IPv4Address(destination).packed,
+    )
+    return header + payload
+
+
+def ipv6(protocol, source, destination, payload, extension=b""):
+    next_header = protocol
+    if extension:
+        # a hop-by-hop options header of 8 bytes, padded with a PadN option.
+        extension = struct.pack(">BB", protocol, 0) + b"\x01\x04\x00\x00\x00\x00"
+        next_header = 0
+    header = struct.pack(
+        ">IHBB16s16s", 6 << 28, len(extension) + len(payload), next_header, 64,
+        ipaddress.IPv6Address(source).packed, ipaddress.IPv6Address(destination).packed,
+    )
+    return header + extension + payload
+
+
+def tcp(source, destination, length):
+    return struct.pack(">HHIIBBHHH", source, destination, 0, 0, 5 << 4, 0x10, 65535, 0, 0) + bytes(length)
+
+
+def udp(source, destination, length):
+    return struct.pack(">HHHH", source, destination, 8 + length, 0) + bytes(length)
+
+
+def icmp(length):
+    return struct.pack(">BBHI", 8, 0, 0, 0) + bytes(length)
+
+
+def ethernet(ether_type, payload, vlan=None):
+    header = bytes.fromhex("020000000002") + bytes.fromhex("020000000001")
+    if vlan is not None:
+        header += struct.pack(">HH", 0x8100, vlan)
+    return header + struct.pack(">H", ether_type) + payload
+
+
+def write_pcap(path):
+    https = ethernet(0x0800, ipv4(6, "10.0.0.1", "10.0.0.2", tcp(51234, 443, 946)))
+    response = ethernet(0x0800, ipv4(6, "10.0.0.2", "10.0.0.1", tcp(443, 51234, 1460)))
+    mdns = ethernet(0x0800, ipv4(17, "10.0.0.3", "224.0.0.251", udp(5353, 5353, 58)))
+    tagged = ethernet(0x0800, ipv4(17, "10.0.0.3", "224.0.0.251", udp(5353, 5353, 54)), vlan=42)
+    ping = ethernet(0x0800, ipv4(1, "10.0.0.1", "10.0.0.2", icmp(56)))
+    fragment = ethernet(0x0800, ipv4(17, "10.0.0.3", "224.0.0.251", bytes(64), fragment=185))
+    arp = ethernet(0x0806, bytes(28))
+
+    # (frame, bytes captured) where the response is truncated to its headers.
+    packets = [(https, None)] * 4 + [(mdns, None)] * 4 + [(tagged, None), (fragment, None), (ping, None), (arp, None)]
+    packets += [(response, 54)] * 2
+
+    with open(path, "wb") as f:
+        f.write(struct.pack("<IHHiIII", 0xA1B2C3D4, 2, 4, 0, 0, 65535, 1))
+        for i, (frame, captured) in enumerate(packets):
+            data = frame[:captured] if captured else frame
+            f.write(struct.pack("<IIII", TIMESTAMP + i, 500000, len(data), len(frame)))
+            f.write(data)
+
+
+def block(order, block_type, body):
+    body += bytes(-len(body) % 4)
+    length = 12 + len(body)
+    return struct.pack(order + "II", block_type, length) + body + struct.pack(order + "I", length)
+
+
+def option(order, code, value):
+    return struct.pack(order + "HH", code, len(value)) + value + bytes(-len(value) % 4)
+
+
+def write_pcapng(path):
+    web = ethernet(0x86DD, ipv6(6, "2001:db8::1", "2001:db8::2", tcp(40000, 80, 100)))
+    hop = ethernet(0x86DD, ipv6(6, "2001:db8::1", "2001:db8::2", tcp(40000, 80, 100), extension=True))
+    dns = ethernet(0x0800, ipv4(17, "10.0.0.1", "10.0.0.53", udp(53000, 53, 40)))
+    raw = ipv4(6, "192.0.2.1", "192.0.2.2", tcp(1234, 22, 0))
+
+    # a big-endian section with an Ethernet interface with nanosecond timestamps and a raw interface.
+    order = ">"
+    out = block(order, 0x0A0D0D0A, struct.pack(">IHHq", 0x1A2B3C4D, 1, 0, -1))
+    out += block(order, 1, struct.pack(">HHI", 1, 0, 0) + option(order, 9, b"\x09") + option(order, 0, b""))
+    out += block(order, 1, struct.pack(">HHI", 101, 0, 0))
+    # a name resolution block, which is skipped.
+    out += block(order, 4, struct.pack(">HH", 0, 0))
+
+    for i, frame in enumerate([web, web, hop]):
+        timestamp = (TIMESTAMP + i) * 1_000_000_000 + 250
+        out += block(order, 6, struct.pack(">IIIII", 0, timestamp >> 32, timestamp & 0xFFFFFFFF, len(frame), len(frame)) + frame)
+
+    out += block(order, 6, struct.pack(">IIIII", 1, 0, 0, len(raw), len(raw)) + raw)
+    out += block(order, 3, struct.pack(">I", len(dns)) + dns)
+
+    # a little-endian section with an interface with microsecond timestamps.
+    order = "<"
+    out += block(order, 0x0A0D0D0A, struct.pack("<IHHq", 0x1A2B3C4D, 1, 0, -1))
+    out += block(order, 1, struct.pack("<HHI", 1, 0, 0))
+    out += block(order, 6, struct.pack("<IIIII", 0, 0, 1_000_000, len(dns), len(dns)) + dns)
+
+    with open(path, "wb") as f:
+        f.write(out)
+
+
+if __name__ == "__main__":
+    write_pcap("flows.pcap")
+    write_pcapng("flows.pcapng")
@@ -0,0 +1,259 @@
+// Package promexport renders the top-k elements of a set of summaries in the Prometheus text exposition format, for dashboards such as Grafana.
+//
+// Every summary is registered under a name, which labels its samples. For a namespace "heavy_hitters", an Exporter writes:
+//
+//	heavy_hitters_count{summary="...",key="..."}      approximate frequency of each of the top-k elements
+//	heavy_hitters_error{summary="...",key="..."}      maximum overestimation of that frequency
+//	heavy_hitters_hits_total{summary="..."}           total number of hits
+//	heavy_hitters_capacity{summary="..."}             maximum number of monitored elements
+//	heavy_hitters_min_count{summary="..."}            smallest count of a monitored element
+//	heavy_hitters_evictions_total{summary="..."}      number of monitored elements that were replaced, whose rate is the eviction rate
+//
+// Only the top-k elements of each summary are written, which bounds the cardinality of the series.
+// The capacity, minimum count and evictions are written for summaries with Capacity, MinCount and Evictions methods,
+// such as [hh.StreamSummary] and [hh.CompactStreamSummary], including when they are wrapped by [hh.Synchronized].
+//
+// Write renders samples collected by other means in the same format, such as the results of the report package.
+package promexport
+
+import (
+	"bufio"
+	"cmp"
+	"fmt"
+	hh "heavy-hitters"
+	"io"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ContentType is the media type of the text exposition format written by an Exporter.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// metricName matches the valid names of Prometheus metrics.
+var metricName = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+
+// Exporter writes the top-k elements and meta-metrics of registered summaries, which is safe for concurrent use.
+// The summaries must be safe to query concurrently with their other uses, such as by wrapping them with [hh.Synchronized].
+type Exporter struct {
+	namespace string
+	k         int
+	mutex     sync.Mutex
+	summaries map[string]func(k int) Sample
+}
+
+// Sample is the state of a summary at the time of a scrape.
+type Sample struct {
+	// Name labels the samples of the summary.
+	Name string
+	Rows []Row
+	Hits int
+	// The meta-metrics, which are only written for summaries that report them.
+	Capacity, MinCount, Evictions *int
+}
+
+// Row is the count of one of the top-k elements of a summary.
+type Row struct {
+	Key          string
+	Count, Error int
+}
+
+// New creates an exporter whose metrics are prefixed by the namespace, writing the top-k elements of every summary.
+func New(namespace string, k int) (*Exporter, error) {
+	if !metricName.MatchString(namespace) {
+		return nil, fmt.Errorf("invalid namespace %q", namespace)
+	}
+
+	if k <= 0 {
+		return nil, fmt.Errorf("k must be positive, got %d", k)
+	}
+
+	return &Exporter{namespace: namespace, k: k, summaries: make(map[string]func(int) Sample)}, nil
+}
+
+// Register adds a summary under the given name, replacing any summary previously registered under the same name.
+// The keys of the summary are formatted with fmt.Sprint.
+func Register[T cmp.Ordered](e *Exporter, name string, summary hh.HeavyHitters[T]) {
+	query := func(k int) Sample {
+		return collect(summary, k)
+	}
+
+	if synchronized, ok := summary.(*hh.Synchronized[T]); ok {
+		// the top-k and meta-metrics are collected under a single lock, so they are consistent with each other.
+		query = func(k int) (s Sample) {
+			synchronized.Do(func(summary hh.WeightedHeavyHitters[T]) {
+				s = collect[T](summary, k)
+			})
+
+			return s
+		}
+	}
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	e.summaries[name] = query
+}
+
+// Unregister removes the summary registered under the given name, reporting whether there was one.
+func (e *Exporter) Unregister(name string) bool {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	_, found := e.summaries[name]
+	delete(e.summaries, name)
+
+	return found
+}
+
+// collect queries the top-k elements and meta-metrics of a summary.
+func collect[T cmp.Ordered](summary hh.HeavyHitters[T], k int) Sample {
+	top, _, _ := summary.Top(k)
+
+	s := Sample{Rows: make([]Row, len(top)), Hits: summary.Hits()}
+
+	for i, e := range top {
+		count, _ := summary.Get(e)
+		s.Rows[i] = Row{Key: fmt.Sprint(e), Count: count.Count, Error: count.Error}
+	}
+
+	if c, ok := summary.(interface{ Capacity() int }); ok {
+		s.Capacity = ptr(c.Capacity())
+	}
+
+	if m, ok := summary.(interface{ MinCount() int }); ok {
+		s.MinCount = ptr(m.MinCount())
+	}
+
+	if e, ok := summary.(interface{ Evictions() int }); ok {
+		s.Evictions = ptr(e.Evictions())
+	}
+
+	return s
+}
+
+func ptr(n int) *int {
+	return &n
+}
+
+// family is a metric family written for every summary.
+type family struct {
+	name, help, kind string
+	// samples writes the samples of the family for a summary, with the given labels.
+	samples func(w *bufio.Writer, name, labels string, s Sample)
+}
+
+// families are the metric families written by Write, in order.
+var families = []family{
+	{"count", "Approximate frequency of a top-k element, overestimating the actual frequency by at most its error.", "gauge",
+		func(w *bufio.Writer, name, labels string, s Sample) {
+			for _, r := range s.Rows {
+				writeSample(w, name, labels+`,key="`+escapeLabel(r.Key)+`"`, r.Count)
+			}
+		}},
+	{"error", "Maximum overestimation of the frequency of a top-k element.", "gauge",
+		func(w *bufio.Writer, name, labels string, s Sample) {
+			for _, r := range s.Rows {
+				writeSample(w, name, labels+`,key="`+escapeLabel(r.Key)+`"`, r.Error)
+			}
+		}},
+	{"hits_total", "Total number of hits of a summary.", "counter",
+		func(w *bufio.Writer, name, labels string, s Sample) {
+			writeSample(w, name, labels, s.Hits)
+		}},
+	{"capacity", "Maximum number of elements monitored by a summary.", "gauge",
+		func(w *bufio.Writer, name, labels string, s Sample) {
+			writeOptionalSample(w, name, labels, s.Capacity)
+		}},
+	{"min_count", "Smallest count of an element monitored by a summary, which bounds the count of any element that is not monitored once it is full.", "gauge",
+		func(w *bufio.Writer, name, labels string, s Sample) {
+			writeOptionalSample(w, name, labels, s.MinCount)
+		}},
+	{"evictions_total", "Number of monitored elements replaced by other elements.", "counter",
+		func(w *bufio.Writer, name, labels string, s Sample) {
+			writeOptionalSample(w, name, labels, s.Evictions)
+		}},
+}
+
+// WriteTo writes the metrics of every summary, ordered by the name of the summary within each metric family.
+func (e *Exporter) WriteTo(w io.Writer) (int64, error) {
+	e.mutex.Lock()
+	names := make([]string, 0, len(e.summaries))
+	collectors := make(map[string]func(int) Sample, len(e.summaries))
+
+	for name, query := range e.summaries {
+		names = append(names, name)
+		collectors[name] = query
+	}
+
+	e.mutex.Unlock()
+
+	slices.Sort(names)
+
+	samples := make([]Sample, len(names))
+	for i, name := range names {
+		samples[i] = collectors[name](e.k)
+		samples[i].Name = name
+	}
+
+	counter := &countingWriter{w: w}
+	err := Write(counter, e.namespace, samples...)
+
+	return counter.n, err
+}
+
+// Write writes the samples in the text exposition format, with the metrics prefixed by the namespace and labelled by the names of the samples.
+// The samples are written in the given order within each metric family.
+func Write(w io.Writer, namespace string, samples ...Sample) error {
+	b := bufio.NewWriter(w)
+
+	for _, f := range families {
+		name := namespace + "_" + f.name
+		fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s %s\n", name, f.help, name, f.kind)
+
+		for _, s := range samples {
+			f.samples(b, name, `summary="`+escapeLabel(s.Name)+`"`, s)
+		}
+	}
+
+	return b.Flush()
+}
+
+// ServeHTTP writes the metrics in response to a scrape.
+func (e *Exporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", ContentType)
+	_, _ = e.WriteTo(w)
+}
+
+func writeSample(w *bufio.Writer, name, labels string, value int) {
+	w.WriteString(name + "{" + labels + "} " + strconv.Itoa(value) + "\n")
+}
+
+func writeOptionalSample(w *bufio.Writer, name, labels string, value *int) {
+	if value != nil {
+		writeSample(w, name, labels, *value)
+	}
+}
+
+// labelEscaper escapes a label value of the text exposition format.
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(value string) string {
+	return labelEscaper.Replace(value)
+}
+
+// countingWriter counts the bytes written through it, for WriteTo.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+
+	return n, err
+}
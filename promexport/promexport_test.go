@@ -0,0 +1,91 @@
+package promexport
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/require"
+	hh "heavy-hitters"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func readGolden(t *testing.T, name string) string {
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	require.NoError(t, err)
+
+	return string(data)
+}
+
+func TestExporter(t *testing.T) {
+	e, err := New("heavy_hitters", 2)
+	require.NoError(t, err)
+
+	paths := hh.NewStreamSummary[string](3, hh.WithTieBreak[string](hh.TieBreakKey))
+	for _, path := range []string{"/", "/login", "/login", `/search?q="a\b"`, `/search?q="a\b"`, `/search?q="a\b"`, "/about\n"} {
+		paths.Hit(path)
+	}
+
+	ports := hh.NewSynchronized[int](hh.NewStreamSummary[int](4))
+	for _, port := range []int{443, 443, 80} {
+		ports.Hit(port)
+	}
+
+	users := hh.NewNaive[string]()
+	users.Hit("alice")
+
+	Register[string](e, "paths", paths)
+	Register[int](e, "ports", ports)
+	Register[string](e, "users", users)
+	Register[string](e, "removed", users)
+	require.True(t, e.Unregister("removed"))
+	require.False(t, e.Unregister("removed"))
+
+	var b bytes.Buffer
+
+	n, err := e.WriteTo(&b)
+	require.NoError(t, err)
+	require.Equal(t, int64(b.Len()), n)
+	require.Equal(t, readGolden(t, "exporter.prom"), b.String())
+}
+
+func TestExporter_Empty(t *testing.T) {
+	e, err := New("hh", 5)
+	require.NoError(t, err)
+
+	var b bytes.Buffer
+
+	_, err = e.WriteTo(&b)
+	require.NoError(t, err)
+	require.Equal(t, readGolden(t, "empty.prom"), b.String())
+}
+
+func TestExporter_ServeHTTP(t *testing.T) {
+	e, err := New("hh", 1)
+	require.NoError(t, err)
+
+	summary := hh.NewStreamSummary[string](2)
+	summary.HitN("a", 2)
+	summary.Hit("b")
+	Register[string](e, "letters", summary)
+
+	recorder := httptest.NewRecorder()
+	e.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	require.Equal(t, http.StatusOK, recorder.Code)
+	require.Equal(t, ContentType, recorder.Header().Get("Content-Type"))
+	require.Contains(t, recorder.Body.String(), "hh_count{summary=\"letters\",key=\"a\"} 2\n")
+	require.NotContains(t, recorder.Body.String(), `key="b"`)
+}
+
+func TestNew_Invalid(t *testing.T) {
+	_, err := New("heavy-hitters", 10)
+	require.Error(t, err)
+
+	_, err = New("", 10)
+	require.Error(t, err)
+
+	_, err = New("hh", 0)
+	require.Error(t, err)
+}
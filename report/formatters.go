@@ -0,0 +1,196 @@
+package report
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"heavy-hitters/promexport"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// Table writes each result as a title line followed by a table with aligned columns, for reading in a terminal.
+func Table() Formatter {
+	return FormatterFunc(func(w io.Writer, results ...Result) error {
+		for i, result := range results {
+			if i > 0 {
+				fmt.Fprintln(w)
+			}
+
+			fmt.Fprintf(w, "%s (hits: %d, guaranteed: %v, ordered: %v)\n", result.Name, result.Hits, result.Guaranteed, result.Ordered)
+
+			table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(table, "RANK\tKEY\tCOUNT\tERROR\tLOWER BOUND\tSHARE")
+
+			for _, row := range result.Rows {
+				fmt.Fprintf(table, "%d\t%s\t%d\t%d\t%d\t%.2f%%\n", row.Rank, row.Key, row.Count, row.Error, row.LowerBound, row.Share*100)
+			}
+
+			if err := table.Flush(); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// jsonResult is the JSON encoding of a result.
+type jsonResult struct {
+	Name       string    `json:"name"`
+	Hits       int       `json:"hits"`
+	Guaranteed bool      `json:"guaranteed"`
+	Ordered    bool      `json:"ordered"`
+	Rows       []jsonRow `json:"rows"`
+}
+
+// jsonRow is the JSON encoding of a row.
+type jsonRow struct {
+	Rank       int     `json:"rank"`
+	Key        string  `json:"key"`
+	Count      int     `json:"count"`
+	Error      int     `json:"error"`
+	LowerBound int     `json:"lower_bound"`
+	Share      float64 `json:"share"`
+}
+
+// JSON writes the results as a JSON array of objects, each holding the rows of a result.
+func JSON() Formatter {
+	return FormatterFunc(func(w io.Writer, results ...Result) error {
+		encoded := make([]jsonResult, len(results))
+
+		for i, result := range results {
+			encoded[i] = jsonResult{
+				Name:       result.Name,
+				Hits:       result.Hits,
+				Guaranteed: result.Guaranteed,
+				Ordered:    result.Ordered,
+				Rows:       make([]jsonRow, len(result.Rows)),
+			}
+
+			for j, row := range result.Rows {
+				encoded[i].Rows[j] = jsonRow(row)
+			}
+		}
+
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+
+		return encoder.Encode(encoded)
+	})
+}
+
+// CSV writes the rows of all results as CSV records, after a header record, with the name of its result first in each record.
+func CSV() Formatter {
+	return FormatterFunc(func(w io.Writer, results ...Result) error {
+		writer := csv.NewWriter(w)
+
+		if err := writer.Write([]string{"result", "rank", "key", "count", "error", "lower_bound", "share"}); err != nil {
+			return err
+		}
+
+		for _, result := range results {
+			for _, row := range result.Rows {
+				err := writer.Write([]string{
+					result.Name,
+					strconv.Itoa(row.Rank),
+					row.Key,
+					strconv.Itoa(row.Count),
+					strconv.Itoa(row.Error),
+					strconv.Itoa(row.LowerBound),
+					strconv.FormatFloat(row.Share, 'g', -1, 64),
+				})
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		writer.Flush()
+
+		return writer.Error()
+	})
+}
+
+// Prometheus writes the results in the Prometheus text exposition format of [promexport.Write], with metrics named after the namespace.
+// Every result is written as a summary labelled with the name of the result, whose top-k elements are its rows.
+func Prometheus(namespace string) Formatter {
+	return FormatterFunc(func(w io.Writer, results ...Result) error {
+		samples := make([]promexport.Sample, len(results))
+
+		for i, result := range results {
+			samples[i] = promexport.Sample{Name: result.Name, Rows: make([]promexport.Row, len(result.Rows)), Hits: result.Hits}
+
+			for j, row := range result.Rows {
+				samples[i].Rows[j] = promexport.Row{Key: row.Key, Count: row.Count, Error: row.Error}
+			}
+		}
+
+		return promexport.Write(w, namespace, samples...)
+	})
+}
+
+// Markdown writes each result as a heading followed by a table, for posting in chats and documents.
+func Markdown() Formatter {
+	return FormatterFunc(func(w io.Writer, results ...Result) error {
+		for i, result := range results {
+			if i > 0 {
+				fmt.Fprintln(w)
+			}
+
+			fmt.Fprintf(w, "### %s\n\n", escapeMarkdown(result.Name))
+			fmt.Fprintf(w, "Hits: %d, guaranteed: %v, ordered: %v\n\n", result.Hits, result.Guaranteed, result.Ordered)
+			fmt.Fprintln(w, "| Rank | Key | Count | Error | Lower bound | Share |")
+			fmt.Fprintln(w, "| ---: | --- | ---: | ---: | ---: | ---: |")
+
+			for _, row := range result.Rows {
+				_, err := fmt.Fprintf(w, "| %d | %s | %d | %d | %d | %.2f%% |\n", row.Rank, markdownCode(row.Key), row.Count, row.Error, row.LowerBound, row.Share*100)
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	})
+}
+
+// markdownEscaper escapes the characters of plain text that would break a Markdown table cell or start a code span.
+var markdownEscaper = strings.NewReplacer("|", `\|`, "`", "\\`", "\n", " ")
+
+// escapeMarkdown escapes the characters of plain text that would break a Markdown table cell or start a code span.
+func escapeMarkdown(value string) string {
+	return markdownEscaper.Replace(value)
+}
+
+// markdownCellEscaper escapes the characters of a code span that would break a Markdown table cell.
+var markdownCellEscaper = strings.NewReplacer("|", `\|`, "\n", " ")
+
+// markdownCode writes a key as a code span in a table cell.
+// Backslashes do not escape backticks in a code span, so the span is fenced by one more backtick than the longest run in the key,
+// and padded with spaces that Markdown strips when the key would otherwise merge with the fence or lose its own spaces.
+func markdownCode(value string) string {
+	value = markdownCellEscaper.Replace(value)
+
+	longest, run := 0, 0
+
+	for _, r := range value {
+		if r == '`' {
+			run++
+			longest = max(longest, run)
+		} else {
+			run = 0
+		}
+	}
+
+	if strings.HasPrefix(value, "`") || strings.HasSuffix(value, "`") ||
+		(strings.HasPrefix(value, " ") && strings.HasSuffix(value, " ") && strings.Trim(value, " ") != "") {
+		value = " " + value + " "
+	}
+
+	fence := strings.Repeat("`", longest+1)
+
+	return fence + value + fence
+}
@@ -0,0 +1,125 @@
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+var results = []Result{
+	{
+		Name:       "top",
+		Hits:       8,
+		Guaranteed: true,
+		Ordered:    true,
+		Rows: []Row{
+			{Rank: 1, Key: "/index.html", Count: 4, LowerBound: 4, Share: 0.5},
+			{Rank: 2, Key: `a|"b"`, Count: 2, Error: 1, LowerBound: 1, Share: 0.25},
+		},
+	},
+	{
+		Name: "frequent",
+		Hits: 8,
+		Rows: []Row{
+			{Rank: 1, Key: "/index.html", Count: 4, LowerBound: 4, Share: 0.5},
+		},
+	},
+}
+
+func format(t *testing.T, formatter Formatter) string {
+	t.Helper()
+
+	var b bytes.Buffer
+	require.NoError(t, formatter.Format(&b, results...))
+
+	return b.String()
+}
+
+func TestTable(t *testing.T) {
+	require.Equal(t, `top (hits: 8, guaranteed: true, ordered: true)
+RANK  KEY          COUNT  ERROR  LOWER BOUND  SHARE
+1     /index.html  4      0      4            50.00%
+2     a|"b"        2      1      1            25.00%
+
+frequent (hits: 8, guaranteed: false, ordered: false)
+RANK  KEY          COUNT  ERROR  LOWER BOUND  SHARE
+1     /index.html  4      0      4            50.00%
+`, format(t, Table()))
+}
+
+func TestJSON(t *testing.T) {
+	var decoded []map[string]any
+	require.NoError(t, json.Unmarshal([]byte(format(t, JSON())), &decoded))
+
+	require.Len(t, decoded, 2)
+	require.Equal(t, "top", decoded[0]["name"])
+	require.Equal(t, true, decoded[0]["ordered"])
+	require.Equal(t, map[string]any{
+		"rank":        2.0,
+		"key":         `a|"b"`,
+		"count":       2.0,
+		"error":       1.0,
+		"lower_bound": 1.0,
+		"share":       0.25,
+	}, decoded[0]["rows"].([]any)[1])
+}
+
+func TestCSV(t *testing.T) {
+	require.Equal(t, `result,rank,key,count,error,lower_bound,share
+top,1,/index.html,4,0,4,0.5
+top,2,"a|""b""",2,1,1,0.25
+frequent,1,/index.html,4,0,4,0.5
+`, format(t, CSV()))
+}
+
+func TestPrometheus(t *testing.T) {
+	require.Equal(t, `# HELP hh_count Approximate frequency of a top-k element, overestimating the actual frequency by at most its error.
+# TYPE hh_count gauge
+hh_count{summary="top",key="/index.html"} 4
+hh_count{summary="top",key="a|\"b\""} 2
+hh_count{summary="frequent",key="/index.html"} 4
+# HELP hh_error Maximum overestimation of the frequency of a top-k element.
+# TYPE hh_error gauge
+hh_error{summary="top",key="/index.html"} 0
+hh_error{summary="top",key="a|\"b\""} 1
+hh_error{summary="frequent",key="/index.html"} 0
+# HELP hh_hits_total Total number of hits of a summary.
+# TYPE hh_hits_total counter
+hh_hits_total{summary="top"} 8
+hh_hits_total{summary="frequent"} 8
+# HELP hh_capacity Maximum number of elements monitored by a summary.
+# TYPE hh_capacity gauge
+# HELP hh_min_count Smallest count of an element monitored by a summary, which bounds the count of any element that is not monitored once it is full.
+# TYPE hh_min_count gauge
+# HELP hh_evictions_total Number of monitored elements replaced by other elements.
+# TYPE hh_evictions_total counter
+`, format(t, Prometheus("hh")))
+}
+
+func TestMarkdown(t *testing.T) {
+	require.Equal(t, "### top\n\n"+
+		"Hits: 8, guaranteed: true, ordered: true\n\n"+
+		"| Rank | Key | Count | Error | Lower bound | Share |\n"+
+		"| ---: | --- | ---: | ---: | ---: | ---: |\n"+
+		"| 1 | `/index.html` | 4 | 0 | 4 | 50.00% |\n"+
+		"| 2 | `a\\|\"b\"` | 2 | 1 | 1 | 25.00% |\n"+
+		"\n"+
+		"### frequent\n\n"+
+		"Hits: 8, guaranteed: false, ordered: false\n\n"+
+		"| Rank | Key | Count | Error | Lower bound | Share |\n"+
+		"| ---: | --- | ---: | ---: | ---: | ---: |\n"+
+		"| 1 | `/index.html` | 4 | 0 | 4 | 50.00% |\n", format(t, Markdown()))
+}
+
+func TestEscapeMarkdown(t *testing.T) {
+	require.Equal(t, "a\\`b\\|c d", escapeMarkdown("a`b|c\nd"))
+}
+
+func TestMarkdownCode(t *testing.T) {
+	require.Equal(t, "`/index.html`", markdownCode("/index.html"))
+	require.Equal(t, "``a`b\\|c d``", markdownCode("a`b|c\nd"))
+	require.Equal(t, "``` ``a ```", markdownCode("``a"))
+	require.Equal(t, "`  a  `", markdownCode(" a "))
+	require.Equal(t, "` `", markdownCode(" "))
+}
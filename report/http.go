@@ -0,0 +1,111 @@
+package report
+
+import (
+	"bytes"
+	"fmt"
+	"heavy-hitters/promexport"
+	"net/http"
+	"strconv"
+)
+
+// MaxK is the largest k accepted by a handler returned by [Handler], so a client cannot request an arbitrarily large result.
+const MaxK = 10000
+
+// HandlerOption configures a handler returned by [Handler].
+type HandlerOption func(*handler)
+
+// WithPreamble writes text before the output of the table and markdown formats, which are read by people rather than programs.
+func WithPreamble(text string) HandlerOption {
+	return func(h *handler) {
+		h.preamble = text
+	}
+}
+
+// WithErrorWriter replaces http.Error for writing the errors of invalid requests, such as an unknown format.
+func WithErrorWriter(fn func(w http.ResponseWriter, status int, err error)) HandlerOption {
+	return func(h *handler) {
+		h.writeError = fn
+	}
+}
+
+type handler struct {
+	results       func(k int) []Result
+	defaultFormat string
+	preamble      string
+	writeError    func(w http.ResponseWriter, status int, err error)
+}
+
+// Handler returns a handler that responds to GET requests with the results for the k query parameter, 10 by default and at most [MaxK],
+// rendered in the format named by the format query parameter, defaultFormat by default.
+// The response is buffered, so an error while formatting is reported with a status rather than a truncated body.
+func Handler(results func(k int) []Result, defaultFormat string, opts ...HandlerOption) http.Handler {
+	h := &handler{
+		results:       results,
+		defaultFormat: defaultFormat,
+		writeError: func(w http.ResponseWriter, status int, err error) {
+			http.Error(w, err.Error(), status)
+		},
+	}
+
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	return h
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		h.writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+
+		return
+	}
+
+	k := 10
+
+	if value := r.URL.Query().Get("k"); value != "" {
+		var err error
+		if k, err = strconv.Atoi(value); err != nil || k <= 0 || k > MaxK {
+			h.writeError(w, http.StatusBadRequest, fmt.Errorf("invalid k %q, expected a positive integer up to %d", value, MaxK))
+			return
+		}
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = h.defaultFormat
+	}
+
+	formatter, err := ByName(format)
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	var b bytes.Buffer
+
+	if h.preamble != "" && (format == "table" || format == "markdown") {
+		b.WriteString(h.preamble)
+	}
+
+	if err := formatter.Format(&b, h.results(k)...); err != nil {
+		h.writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", ContentType(format))
+	_, _ = w.Write(b.Bytes())
+}
+
+// ContentType returns the media type of the output of the formatter with the given name, one of [Formats].
+func ContentType(format string) string {
+	switch format {
+	case "json":
+		return "application/json"
+	case "prom":
+		return promexport.ContentType
+	default:
+		return "text/plain; charset=utf-8"
+	}
+}
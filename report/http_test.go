@@ -0,0 +1,70 @@
+package report
+
+import (
+	"github.com/stretchr/testify/require"
+	"heavy-hitters/promexport"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestHandler(t *testing.T) {
+	var requested int
+
+	handler := Handler(func(k int) []Result {
+		requested = k
+		return results
+	}, "json", WithPreamble("Weight: bytes\n\n"))
+
+	get := func(target string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+
+		return w
+	}
+
+	w := get("/")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	require.Equal(t, 10, requested)
+	require.True(t, strings.HasPrefix(w.Body.String(), "["))
+
+	w = get("/?k=3&format=prom")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, promexport.ContentType, w.Header().Get("Content-Type"))
+	require.Equal(t, 3, requested)
+
+	w = get("/?format=table")
+	require.Equal(t, "text/plain; charset=utf-8", w.Header().Get("Content-Type"))
+	require.True(t, strings.HasPrefix(w.Body.String(), "Weight: bytes\n\ntop "))
+
+	require.Equal(t, http.StatusBadRequest, get("/?k=0").Code)
+	require.Equal(t, http.StatusBadRequest, get("/?k=1099511627776").Code)
+
+	w = get("/?k=" + strconv.Itoa(MaxK))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, MaxK, requested)
+
+	require.Equal(t, http.StatusBadRequest, get("/?format=xml").Code)
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", nil))
+	require.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	require.Equal(t, "GET, HEAD", w.Header().Get("Allow"))
+}
+
+func TestHandler_ErrorWriter(t *testing.T) {
+	var reported error
+
+	handler := Handler(func(int) []Result { return results }, "json", WithErrorWriter(func(w http.ResponseWriter, status int, err error) {
+		reported = err
+		w.WriteHeader(status)
+	}))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/?k=x", nil))
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	require.ErrorContains(t, reported, `invalid k "x"`)
+}
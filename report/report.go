@@ -0,0 +1,127 @@
+// Package report renders the results of heavy hitters summaries as tables, JSON, CSV, Prometheus metrics or Markdown.
+//
+// A Result holds the rows of a Top or Frequent query, each with the count, error, guaranteed lower bound and share of an element.
+// A Formatter writes several results at once, such as the top-k of every dimension of an access log,
+// and Handler serves them over HTTP in the format named by the request.
+package report
+
+import (
+	"cmp"
+	"fmt"
+	hh "heavy-hitters"
+	"io"
+)
+
+// Result is the outcome of a Top or Frequent query of a summary.
+type Result struct {
+	// Name identifies the result among the results written together, such as "top" or the name of a dimension.
+	Name string
+	// Hits is the total number of hits of the summary.
+	Hits int
+	// Guaranteed is true iff the rows are guaranteed to be the actual result, irrespective of the errors.
+	Guaranteed bool
+	// Ordered is true iff the rows are guaranteed to be in the correct order, which is only reported by Top.
+	Ordered bool
+	// Rows lists the elements in descending order of frequency.
+	Rows []Row
+}
+
+// Row is an element of a result.
+type Row struct {
+	// Rank is the 1-based position of the element in the result.
+	Rank int
+	// Key is the element, formatted with fmt.Sprint.
+	Key string
+	// Count is the approximate frequency of the element, which overestimates the actual frequency by at most Error.
+	Count int
+	Error int
+	// LowerBound is the guaranteed minimum frequency of the element, its count minus its error.
+	LowerBound int
+	// Share is the fraction of all hits contributed by the element, based on its count.
+	Share float64
+}
+
+// Top queries the top-k elements of the summary.
+func Top[T cmp.Ordered](summary hh.HeavyHitters[T], k int) Result {
+	top, ordered, guaranteed := summary.Top(k)
+
+	return Result{
+		Name:       "top",
+		Hits:       summary.Hits(),
+		Guaranteed: guaranteed,
+		Ordered:    ordered,
+		Rows:       rows(summary, top),
+	}
+}
+
+// Frequent queries the elements of the summary that contribute more than phi * Hits of the total frequency.
+func Frequent[T cmp.Ordered](summary hh.HeavyHitters[T], phi float64) Result {
+	frequent, guaranteed := summary.Frequent(phi)
+
+	return Result{
+		Name:       "frequent",
+		Hits:       summary.Hits(),
+		Guaranteed: guaranteed,
+		Rows:       rows(summary, frequent),
+	}
+}
+
+// rows looks up the counts of the elements in the summary.
+func rows[T cmp.Ordered](summary hh.HeavyHitters[T], elements []T) []Row {
+	hits := summary.Hits()
+	rows := make([]Row, len(elements))
+
+	for i, e := range elements {
+		count, _ := summary.Get(e)
+
+		rows[i] = Row{
+			Rank:       i + 1,
+			Key:        fmt.Sprint(e),
+			Count:      count.Count,
+			Error:      count.Error,
+			LowerBound: count.Count - count.Error,
+		}
+
+		if hits > 0 {
+			rows[i].Share = float64(count.Count) / float64(hits)
+		}
+	}
+
+	return rows
+}
+
+// Formatter writes results in a particular format.
+type Formatter interface {
+	// Format writes the results in the order they are given.
+	Format(w io.Writer, results ...Result) error
+}
+
+// FormatterFunc adapts a function to the Formatter interface.
+type FormatterFunc func(w io.Writer, results ...Result) error
+
+// Format calls f(w, results...).
+func (f FormatterFunc) Format(w io.Writer, results ...Result) error {
+	return f(w, results...)
+}
+
+// Formats lists the names of the formatters accepted by [ByName].
+var Formats = []string{"table", "json", "csv", "prom", "markdown"}
+
+// ByName returns the formatter with the given name, one of [Formats].
+// The prom formatter names its metrics with the "heavy_hitters" namespace.
+func ByName(name string) (Formatter, error) {
+	switch name {
+	case "table":
+		return Table(), nil
+	case "json":
+		return JSON(), nil
+	case "csv":
+		return CSV(), nil
+	case "prom":
+		return Prometheus("heavy_hitters"), nil
+	case "markdown":
+		return Markdown(), nil
+	default:
+		return nil, fmt.Errorf("unknown format %q, expected one of %q", name, Formats)
+	}
+}
@@ -0,0 +1,53 @@
+package report
+
+import (
+	"github.com/stretchr/testify/require"
+	hh "heavy-hitters"
+	"testing"
+)
+
+func TestTop(t *testing.T) {
+	summary := hh.NewStreamSummary[int](2, hh.WithTieBreak[int](hh.TieBreakKey))
+
+	for _, e := range []int{1, 1, 1, 2, 3, 3} {
+		summary.Hit(e)
+	}
+
+	require.Equal(t, Result{
+		Name:       "top",
+		Hits:       6,
+		Guaranteed: false,
+		Ordered:    true,
+		Rows: []Row{
+			{Rank: 1, Key: "1", Count: 3, LowerBound: 3, Share: 0.5},
+			{Rank: 2, Key: "3", Count: 3, Error: 1, LowerBound: 2, Share: 0.5},
+		},
+	}, Top[int](summary, 2))
+
+	require.Equal(t, Result{
+		Name:       "frequent",
+		Hits:       6,
+		Guaranteed: true,
+		Rows: []Row{
+			{Rank: 1, Key: "1", Count: 3, LowerBound: 3, Share: 0.5},
+			{Rank: 2, Key: "3", Count: 3, Error: 1, LowerBound: 2, Share: 0.5},
+		},
+	}, Frequent[int](summary, 0.3))
+}
+
+func TestTop_Empty(t *testing.T) {
+	result := Top[string](hh.NewNaive[string](), 3)
+	require.Empty(t, result.Rows)
+	require.Equal(t, 0, result.Hits)
+}
+
+func TestByName(t *testing.T) {
+	for _, name := range Formats {
+		formatter, err := ByName(name)
+		require.NoError(t, err)
+		require.NotNil(t, formatter)
+	}
+
+	_, err := ByName("xml")
+	require.Error(t, err)
+}
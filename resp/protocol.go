@@ -0,0 +1,133 @@
+package resp
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// maxBulkLength bounds the length of a bulk string, which is allocated before it is read.
+// Redis accepts up to 512 MiB, but the keys and items of top-k summaries are much shorter.
+const maxBulkLength = 4 << 20
+
+// maxLineLength bounds the length of a line, such as an inline command, as Redis does.
+const maxLineLength = 64 << 10
+
+// maxArrayLength bounds the number of arguments of a command, so a malformed length cannot exhaust memory up front.
+const maxArrayLength = 1 << 20
+
+// errProtocol is returned when a client sends data that is not a valid command.
+var errProtocol = errors.New("protocol error")
+
+// reader reads commands sent by a client, either as RESP arrays of bulk strings or as inline commands separated by spaces.
+type reader struct {
+	*bufio.Reader
+}
+
+// readCommand reads the arguments of the next command, which may be empty for an empty inline command.
+func (r reader) readCommand() ([]string, error) {
+	line, err := r.readLine()
+	if err != nil {
+		return nil, err
+	}
+
+	if !strings.HasPrefix(line, "*") {
+		return strings.Fields(line), nil
+	}
+
+	n, err := strconv.Atoi(line[1:])
+	if err != nil || n > maxArrayLength {
+		return nil, fmt.Errorf("%w: invalid multibulk length", errProtocol)
+	}
+
+	args := make([]string, 0, max(n, 0))
+
+	for range n {
+		line, err := r.readLine()
+		if err != nil {
+			return nil, err
+		}
+
+		if !strings.HasPrefix(line, "$") {
+			return nil, fmt.Errorf("%w: expected '$', got %q", errProtocol, line)
+		}
+
+		length, err := strconv.Atoi(line[1:])
+		if err != nil || length < 0 || length > maxBulkLength {
+			return nil, fmt.Errorf("%w: invalid bulk length", errProtocol)
+		}
+
+		bulk := make([]byte, length+2)
+		if _, err := io.ReadFull(r, bulk); err != nil {
+			return nil, err
+		}
+
+		if string(bulk[length:]) != "\r\n" {
+			return nil, fmt.Errorf("%w: bulk string not terminated by CRLF", errProtocol)
+		}
+
+		args = append(args, string(bulk[:length]))
+	}
+
+	return args, nil
+}
+
+// readLine reads a line terminated by CRLF, or by LF alone as clients such as telnet may send for inline commands.
+func (r reader) readLine() (string, error) {
+	var line []byte
+
+	for {
+		chunk, err := r.ReadSlice('\n')
+		if len(line)+len(chunk) > maxLineLength {
+			return "", fmt.Errorf("%w: line longer than %d bytes", errProtocol, maxLineLength)
+		}
+
+		line = append(line, chunk...)
+
+		if err == bufio.ErrBufferFull {
+			continue
+		} else if err != nil {
+			if err == io.EOF && len(line) > 0 {
+				err = io.ErrUnexpectedEOF
+			}
+
+			return "", err
+		}
+
+		return strings.TrimSuffix(strings.TrimSuffix(string(line), "\n"), "\r"), nil
+	}
+}
+
+// writer writes RESP2 replies.
+type writer struct {
+	*bufio.Writer
+}
+
+func (w writer) simple(s string) {
+	w.WriteString("+" + s + "\r\n")
+}
+
+func (w writer) error(s string) {
+	w.WriteString("-" + s + "\r\n")
+}
+
+func (w writer) integer(n int) {
+	w.WriteString(":" + strconv.Itoa(n) + "\r\n")
+}
+
+func (w writer) bulk(s string) {
+	w.WriteString("$" + strconv.Itoa(len(s)) + "\r\n" + s + "\r\n")
+}
+
+// null writes the null bulk string.
+func (w writer) null() {
+	w.WriteString("$-1\r\n")
+}
+
+// array writes the header of an array, which must be followed by n replies.
+func (w writer) array(n int) {
+	w.WriteString("*" + strconv.Itoa(n) + "\r\n")
+}
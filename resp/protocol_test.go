@@ -0,0 +1,55 @@
+package resp
+
+import (
+	"bufio"
+	"github.com/stretchr/testify/require"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestReader(t *testing.T) {
+	r := reader{bufio.NewReader(strings.NewReader("*2\r\n$4\r\nPING\r\n$5\r\na\r\nb \r\n  PING  hello \r\n\r\n*0\r\n"))}
+
+	for _, expected := range [][]string{{"PING", "a\r\nb "}, {"PING", "hello"}, {}, {}} {
+		args, err := r.readCommand()
+		require.NoError(t, err)
+		require.Equal(t, expected, args)
+	}
+
+	_, err := r.readCommand()
+	require.ErrorIs(t, err, io.EOF)
+}
+
+func TestReader_Invalid(t *testing.T) {
+	for input, expected := range map[string]error{
+		"*x\r\n":                                 errProtocol,
+		"*1\r\n+PING\r\n":                        errProtocol,
+		"*1\r\n$-1\r\n":                          errProtocol,
+		"*1\r\n$4\r\nPINGxx":                     errProtocol,
+		"*1\r\n$4\r\nPI":                         io.ErrUnexpectedEOF,
+		"*2\r\n$4\r\nPING\r\n":                   io.EOF,
+		"PING":                                   io.ErrUnexpectedEOF,
+		"*1\r\n$999999999999\r\n":                errProtocol,
+		"*1\r\n$4194305\r\n":                     errProtocol,
+		strings.Repeat("PING ", 20_000) + "\r\n": errProtocol,
+	} {
+		_, err := reader{bufio.NewReader(strings.NewReader(input))}.readCommand()
+		require.ErrorIs(t, err, expected, "%q", input)
+	}
+}
+
+func TestWriter(t *testing.T) {
+	var b strings.Builder
+	w := writer{bufio.NewWriter(&b)}
+
+	w.array(5)
+	w.simple("OK")
+	w.error("ERR failed")
+	w.integer(-3)
+	w.bulk("a\r\nb")
+	w.null()
+	require.NoError(t, w.Flush())
+
+	require.Equal(t, "*5\r\n+OK\r\n-ERR failed\r\n:-3\r\n$4\r\na\r\nb\r\n$-1\r\n", b.String())
+}
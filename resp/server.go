@@ -0,0 +1,535 @@
+// Package resp serves top-k summaries over the Redis serialization protocol (RESP2), compatible with the TOPK commands of RedisBloom.
+//
+// Clients of RedisBloom can use the server in its place for the following commands:
+//
+//	TOPK.RESERVE key topk [width depth decay]
+//	TOPK.ADD key item [item ...]
+//	TOPK.INCRBY key item increment [item increment ...]
+//	TOPK.QUERY key item [item ...]
+//	TOPK.COUNT key item [item ...]
+//	TOPK.LIST key [WITHCOUNT]
+//	TOPK.INFO key
+//
+// along with PING, INFO and QUIT for health checks and scripts.
+//
+// Every key is a StreamSummary[string] reporting its top-k elements.
+// RedisBloom approximates the top-k with a HeavyKeeper sketch of width * depth counters, whereas the summary monitors width * depth elements,
+// or 10 * topk elements without a width and depth, but never fewer than topk elements.
+// The decay is only reported by TOPK.INFO, since the counts of the summary do not decay.
+package resp
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	hh "heavy-hitters"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// maxCapacity bounds the number of counters of a key, so a client cannot exhaust the memory of the server with a single command.
+const maxCapacity = 1 << 24
+
+// Option configures a Server.
+type Option func(*Server)
+
+// WithMaxCounters bounds the number of counters of all keys together, 1<<22 by default,
+// so clients cannot exhaust the memory of the server by reserving many keys. The maximum must be positive.
+func WithMaxCounters(counters int) Option {
+	return func(s *Server) {
+		s.maxCounters = counters
+	}
+}
+
+// ErrServerClosed is returned by Serve after a call to Close.
+var ErrServerClosed = errors.New("resp: server closed")
+
+// Server serves top-k summaries to RESP2 clients, which is safe for concurrent use.
+type Server struct {
+	mutex     sync.Mutex
+	keys      map[string]*topK
+	listeners map[net.Listener]struct{}
+	conns     map[net.Conn]struct{}
+	closed    bool
+	started   time.Time
+	commands  atomic.Int64
+	// The number of counters reserved by all keys, and its maximum.
+	counters    int
+	maxCounters int
+}
+
+// topK is a summary reserved by TOPK.RESERVE along with its parameters.
+type topK struct {
+	*hh.Synchronized[string]
+	k, width, depth int
+	decay           float64
+	// The elements that left the top-k during the latest hit, guarded by the mutex of the summary.
+	left []string
+}
+
+// New creates a server without any keys.
+// It panics if the maximum number of counters is not positive.
+func New(opts ...Option) *Server {
+	s := &Server{
+		keys:        make(map[string]*topK),
+		listeners:   make(map[net.Listener]struct{}),
+		conns:       make(map[net.Conn]struct{}),
+		started:     time.Now(),
+		maxCounters: 1 << 22,
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	if s.maxCounters <= 0 {
+		panic(fmt.Sprintf("resp: maximum number of counters must be positive, got %d", s.maxCounters))
+	}
+
+	return s
+}
+
+// Serve accepts connections on the listener and serves each of them in a new goroutine, until the listener fails or the server is closed.
+// It always returns a non-nil error, which is [ErrServerClosed] after Close.
+func (s *Server) Serve(l net.Listener) error {
+	s.mutex.Lock()
+	if s.closed {
+		s.mutex.Unlock()
+		return ErrServerClosed
+	}
+
+	s.listeners[l] = struct{}{}
+	s.mutex.Unlock()
+
+	defer func() {
+		s.mutex.Lock()
+		delete(s.listeners, l)
+		s.mutex.Unlock()
+	}()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			s.mutex.Lock()
+			closed := s.closed
+			s.mutex.Unlock()
+
+			if closed {
+				return ErrServerClosed
+			}
+
+			return err
+		}
+
+		go s.ServeConn(conn)
+	}
+}
+
+// Close closes the listeners and connections of the server.
+// The keys are kept, so the server can be queried in-process after Close.
+func (s *Server) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.closed = true
+
+	var errs []error
+
+	for l := range s.listeners {
+		errs = append(errs, l.Close())
+	}
+
+	for conn := range s.conns {
+		errs = append(errs, conn.Close())
+	}
+
+	return errors.Join(errs...)
+}
+
+// ServeConn serves the commands of a single client until it quits or the connection fails, then closes the connection.
+// Replies to pipelined commands are written together once every buffered command is executed.
+func (s *Server) ServeConn(conn net.Conn) {
+	s.mutex.Lock()
+	if s.closed {
+		s.mutex.Unlock()
+		conn.Close()
+
+		return
+	}
+
+	s.conns[conn] = struct{}{}
+	s.mutex.Unlock()
+
+	defer func() {
+		s.mutex.Lock()
+		delete(s.conns, conn)
+		s.mutex.Unlock()
+
+		conn.Close()
+	}()
+
+	r := reader{bufio.NewReader(conn)}
+	w := writer{bufio.NewWriter(conn)}
+
+	for {
+		args, err := r.readCommand()
+		if err != nil {
+			if errors.Is(err, errProtocol) {
+				w.error("ERR " + err.Error())
+				w.Flush()
+			}
+
+			return
+		}
+
+		if len(args) == 0 {
+			continue
+		}
+
+		quit := s.execute(w, args)
+
+		if r.Buffered() == 0 || quit {
+			if err := w.Flush(); err != nil {
+				return
+			}
+		}
+
+		if quit {
+			return
+		}
+	}
+}
+
+// command executes a command with the arguments following its name.
+type command struct {
+	// The minimum and maximum number of arguments following the name, where a negative maximum is unbounded.
+	min, max int
+	execute  func(s *Server, w writer, args []string)
+}
+
+// commands are the supported commands, by upper case name.
+// CLIENT, COMMAND and HELLO are accepted so that clients which send them on connecting fall back to RESP2 without them.
+var commands = map[string]command{
+	"PING":         {0, 1, ping},
+	"INFO":         {0, -1, info},
+	"QUIT":         {0, 0, func(s *Server, w writer, args []string) { w.simple("OK") }},
+	"CLIENT":       {1, -1, func(s *Server, w writer, args []string) { w.simple("OK") }},
+	"COMMAND":      {0, -1, func(s *Server, w writer, args []string) { w.array(0) }},
+	"HELLO":        {0, -1, func(s *Server, w writer, args []string) { w.error("NOPROTO this server only supports RESP2") }},
+	"TOPK.RESERVE": {2, 5, reserve},
+	"TOPK.ADD":     {2, -1, add},
+	"TOPK.INCRBY":  {3, -1, incrBy},
+	"TOPK.QUERY":   {2, -1, query},
+	"TOPK.COUNT":   {2, -1, count},
+	"TOPK.LIST":    {1, 2, list},
+	"TOPK.INFO":    {1, 1, topKInfo},
+}
+
+// execute executes the command, reporting whether the client quit.
+func (s *Server) execute(w writer, args []string) bool {
+	s.commands.Add(1)
+
+	name := strings.ToUpper(args[0])
+	c, found := commands[name]
+
+	switch {
+	case !found:
+		w.error(fmt.Sprintf("ERR unknown command '%s'", args[0]))
+	case len(args)-1 < c.min, c.max >= 0 && len(args)-1 > c.max:
+		w.error(fmt.Sprintf("ERR wrong number of arguments for '%s' command", strings.ToLower(args[0])))
+	default:
+		c.execute(s, w, args[1:])
+	}
+
+	return name == "QUIT"
+}
+
+func ping(s *Server, w writer, args []string) {
+	if len(args) == 0 {
+		w.simple("PONG")
+	} else {
+		w.bulk(args[0])
+	}
+}
+
+// info describes the server, its clients and its keys, ignoring the requested sections.
+func info(s *Server, w writer, args []string) {
+	s.mutex.Lock()
+	clients, keys := len(s.conns), len(s.keys)
+	s.mutex.Unlock()
+
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "# Server\r\nserver_name:heavy-hitters\r\nredis_mode:standalone\r\nuptime_in_seconds:%d\r\n\r\n", int(time.Since(s.started).Seconds()))
+	fmt.Fprintf(&b, "# Clients\r\nconnected_clients:%d\r\n\r\n", clients)
+	fmt.Fprintf(&b, "# Stats\r\ntotal_commands_processed:%d\r\n\r\n", s.commands.Load())
+	fmt.Fprintf(&b, "# Keyspace\r\ntopk_keys:%d\r\n", keys)
+
+	w.bulk(b.String())
+}
+
+// reserve creates a key, as TOPK.RESERVE key topk [width depth decay].
+func reserve(s *Server, w writer, args []string) {
+	if len(args) != 2 && len(args) != 5 {
+		w.error("ERR wrong number of arguments for 'topk.reserve' command")
+		return
+	}
+
+	t := &topK{width: 8, depth: 7, decay: 0.9}
+
+	var err error
+
+	if t.k, err = strconv.Atoi(args[1]); err != nil || t.k < 1 {
+		w.error("ERR TopK: invalid k")
+		return
+	}
+
+	capacity := 10 * t.k
+
+	if len(args) == 5 {
+		t.width, err = strconv.Atoi(args[2])
+		if err != nil || t.width < 1 {
+			w.error("ERR TopK: invalid width")
+			return
+		}
+
+		t.depth, err = strconv.Atoi(args[3])
+		if err != nil || t.depth < 1 {
+			w.error("ERR TopK: invalid depth")
+			return
+		}
+
+		// the dimensions are bounded before they are multiplied, so their product cannot overflow past the budget.
+		if t.width > maxCapacity/t.depth {
+			w.error("ERR TopK: too many counters")
+			return
+		}
+
+		t.decay, err = strconv.ParseFloat(args[4], 64)
+		if err != nil || t.decay <= 0 || t.decay > 1 {
+			w.error("ERR TopK: invalid decay value. must be '<= 1' & '> 0'")
+			return
+		}
+
+		capacity = max(t.k, t.width*t.depth)
+	}
+
+	if t.k > maxCapacity || capacity > maxCapacity {
+		w.error("ERR TopK: too many counters")
+		return
+	}
+
+	// the summary is allocated while holding the mutex, so concurrent reservations cannot exceed the budget.
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, exists := s.keys[args[0]]; exists {
+		w.error("ERR TopK: key already exists")
+		return
+	}
+
+	if capacity > s.maxCounters-s.counters {
+		w.error("ERR TopK: too many counters")
+		return
+	}
+
+	t.Synchronized = hh.NewSynchronized[string](hh.NewStreamSummary[string](capacity,
+		hh.WithTieBreak[string](hh.TieBreakKey),
+		hh.WithOnTopKChange(t.k, func(_, left []string) { t.left = append(t.left, left...) })))
+
+	s.keys[args[0]] = t
+	s.counters += capacity
+
+	w.simple("OK")
+}
+
+// key finds the summary of a key, writing an error if it does not exist.
+func (s *Server) key(w writer, name string) (*topK, bool) {
+	s.mutex.Lock()
+	t, found := s.keys[name]
+	s.mutex.Unlock()
+
+	if !found {
+		w.error("ERR TopK: key does not exist")
+	}
+
+	return t, found
+}
+
+// add counts items once each, as TOPK.ADD key item [item ...].
+func add(s *Server, w writer, args []string) {
+	items := args[1:]
+	increments := make([]int, len(items))
+
+	for i := range increments {
+		increments[i] = 1
+	}
+
+	s.increment(w, args[0], items, increments)
+}
+
+// incrBy counts items by increments, as TOPK.INCRBY key item increment [item increment ...].
+func incrBy(s *Server, w writer, args []string) {
+	if len(args)%2 != 1 {
+		w.error("ERR wrong number of arguments for 'topk.incrby' command")
+		return
+	}
+
+	var items []string
+	var increments []int
+
+	for i := 1; i < len(args); i += 2 {
+		increment, err := strconv.Atoi(args[i+1])
+		if err != nil || increment < 1 {
+			w.error("ERR TopK: increment must be an integer greater or equal to 1")
+			return
+		}
+
+		items = append(items, args[i])
+		increments = append(increments, increment)
+	}
+
+	s.increment(w, args[0], items, increments)
+}
+
+// increment counts the items by their increments, replying for every item with the element that left the top-k because of it, or null.
+func (s *Server) increment(w writer, key string, items []string, increments []int) {
+	t, found := s.key(w, key)
+	if !found {
+		return
+	}
+
+	expelled := make([]*string, len(items))
+
+	t.Do(func(summary hh.WeightedHeavyHitters[string]) {
+		for i, item := range items {
+			t.left = t.left[:0]
+			summary.HitN(item, increments[i])
+
+			if len(t.left) > 0 {
+				expelled[i] = &t.left[0]
+				t.left = nil
+			}
+		}
+	})
+
+	w.array(len(expelled))
+
+	for _, e := range expelled {
+		if e == nil {
+			w.null()
+		} else {
+			w.bulk(*e)
+		}
+	}
+}
+
+// query replies for every item whether it is in the top-k, as TOPK.QUERY key item [item ...].
+func query(s *Server, w writer, args []string) {
+	t, found := s.key(w, args[0])
+	if !found {
+		return
+	}
+
+	top, _, _ := t.Top(t.k)
+
+	w.array(len(args) - 1)
+
+	for _, item := range args[1:] {
+		if slices.Contains(top, item) {
+			w.integer(1)
+		} else {
+			w.integer(0)
+		}
+	}
+}
+
+// count replies with the approximate count of every item, or zero if it is not monitored, as TOPK.COUNT key item [item ...].
+func count(s *Server, w writer, args []string) {
+	t, found := s.key(w, args[0])
+	if !found {
+		return
+	}
+
+	counts := make([]int, len(args)-1)
+
+	t.Do(func(summary hh.WeightedHeavyHitters[string]) {
+		for i, item := range args[1:] {
+			c, _ := summary.Get(item)
+			counts[i] = c.Count
+		}
+	})
+
+	w.array(len(counts))
+
+	for _, c := range counts {
+		w.integer(c)
+	}
+}
+
+// list replies with the top-k elements in descending order of count, followed by their counts with WITHCOUNT, as TOPK.LIST key [WITHCOUNT].
+func list(s *Server, w writer, args []string) {
+	withCount := len(args) == 2 && strings.EqualFold(args[1], "WITHCOUNT")
+
+	if len(args) == 2 && !withCount {
+		w.error("ERR syntax error")
+		return
+	}
+
+	t, found := s.key(w, args[0])
+	if !found {
+		return
+	}
+
+	var top []string
+	var counts []int
+
+	t.Do(func(summary hh.WeightedHeavyHitters[string]) {
+		top, _, _ = summary.Top(t.k)
+
+		for _, e := range top {
+			c, _ := summary.Get(e)
+			counts = append(counts, c.Count)
+		}
+	})
+
+	if !withCount {
+		w.array(len(top))
+
+		for _, e := range top {
+			w.bulk(e)
+		}
+
+		return
+	}
+
+	w.array(2 * len(top))
+
+	for i, e := range top {
+		w.bulk(e)
+		w.integer(counts[i])
+	}
+}
+
+// topKInfo replies with the parameters of a key, as TOPK.INFO key.
+func topKInfo(s *Server, w writer, args []string) {
+	t, found := s.key(w, args[0])
+	if !found {
+		return
+	}
+
+	w.array(8)
+	w.bulk("k")
+	w.integer(t.k)
+	w.bulk("width")
+	w.integer(t.width)
+	w.bulk("depth")
+	w.integer(t.depth)
+	w.bulk("decay")
+	w.bulk(strconv.FormatFloat(t.decay, 'f', -1, 64))
+}
@@ -0,0 +1,199 @@
+package resp
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// client sends commands to a server as a RESP2 client does.
+type client struct {
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// serve starts serving on a loopback listener and connects a client to it.
+func serve(t *testing.T, opts ...Option) (*Server, *client) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	s := New(opts...)
+	done := make(chan error)
+
+	go func() {
+		done <- s.Serve(listener)
+	}()
+
+	t.Cleanup(func() {
+		require.NoError(t, s.Close())
+		require.ErrorIs(t, <-done, ErrServerClosed)
+	})
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	require.NoError(t, err)
+
+	return s, &client{conn: conn, reader: bufio.NewReader(conn)}
+}
+
+// do sends a command and reads its reply.
+func (c *client) do(t *testing.T, args ...string) any {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+
+	for _, arg := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+
+	_, err := io.WriteString(c.conn, b.String())
+	require.NoError(t, err)
+
+	return c.reply(t)
+}
+
+// replyError is an error reply.
+type replyError string
+
+// reply reads a reply, decoding simple strings and bulk strings as strings, integers as ints, the null bulk string as nil and arrays as slices.
+func (c *client) reply(t *testing.T) any {
+	line, err := c.reader.ReadString('\n')
+	require.NoError(t, err)
+
+	line = strings.TrimSuffix(line, "\r\n")
+
+	switch line[0] {
+	case '+':
+		return line[1:]
+	case '-':
+		return replyError(line[1:])
+	case ':':
+		n, err := strconv.Atoi(line[1:])
+		require.NoError(t, err)
+
+		return n
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		require.NoError(t, err)
+
+		if n < 0 {
+			return nil
+		}
+
+		bulk := make([]byte, n+2)
+		_, err = io.ReadFull(c.reader, bulk)
+		require.NoError(t, err)
+
+		return string(bulk[:n])
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		require.NoError(t, err)
+
+		array := make([]any, n)
+		for i := range array {
+			array[i] = c.reply(t)
+		}
+
+		return array
+	default:
+		t.Fatalf("unexpected reply %q", line)
+		return nil
+	}
+}
+
+func TestServer_TopK(t *testing.T) {
+	_, c := serve(t)
+
+	require.Equal(t, "OK", c.do(t, "TOPK.RESERVE", "paths", "2", "3", "4", "0.5"))
+	require.Equal(t, replyError("ERR TopK: key already exists"), c.do(t, "TOPK.RESERVE", "paths", "2"))
+	require.Equal(t, []any{"k", 2, "width", 3, "depth", 4, "decay", "0.5"}, c.do(t, "TOPK.INFO", "paths"))
+
+	require.Equal(t, []any{nil, nil, nil}, c.do(t, "TOPK.ADD", "paths", "/a", "/b", "/a"))
+	// /c enters the top-2 in place of /b.
+	require.Equal(t, []any{"/b"}, c.do(t, "topk.incrby", "paths", "/c", "3"))
+	require.Equal(t, []any{nil, nil}, c.do(t, "TOPK.INCRBY", "paths", "/a", "1", "/d", "1"))
+
+	require.Equal(t, []any{"/a", "/c"}, c.do(t, "TOPK.LIST", "paths"))
+	require.Equal(t, []any{"/a", 3, "/c", 3}, c.do(t, "TOPK.LIST", "paths", "withcount"))
+	require.Equal(t, []any{1, 0, 1}, c.do(t, "TOPK.QUERY", "paths", "/a", "/b", "/c"))
+	require.Equal(t, []any{3, 1, 0}, c.do(t, "TOPK.COUNT", "paths", "/a", "/b", "/e"))
+
+	require.Equal(t, "OK", c.do(t, "TOPK.RESERVE", "agents", "1"))
+	require.Equal(t, []any{"k", 1, "width", 8, "depth", 7, "decay", "0.9"}, c.do(t, "TOPK.INFO", "agents"))
+	require.Equal(t, []any{}, c.do(t, "TOPK.LIST", "agents"))
+}
+
+func TestServer_Errors(t *testing.T) {
+	_, c := serve(t)
+
+	require.Equal(t, "OK", c.do(t, "TOPK.RESERVE", "paths", "2"))
+
+	for _, test := range []struct {
+		args []string
+		err  replyError
+	}{
+		{[]string{"GET", "paths"}, "ERR unknown command 'GET'"},
+		{[]string{"TOPK.ADD", "paths"}, "ERR wrong number of arguments for 'topk.add' command"},
+		{[]string{"TOPK.ADD", "missing", "a"}, "ERR TopK: key does not exist"},
+		{[]string{"TOPK.INCRBY", "paths", "a", "0"}, "ERR TopK: increment must be an integer greater or equal to 1"},
+		{[]string{"TOPK.INCRBY", "paths", "a", "1", "b"}, "ERR wrong number of arguments for 'topk.incrby' command"},
+		{[]string{"TOPK.RESERVE", "other", "0"}, "ERR TopK: invalid k"},
+		{[]string{"TOPK.RESERVE", "other", "1", "8"}, "ERR wrong number of arguments for 'topk.reserve' command"},
+		{[]string{"TOPK.RESERVE", "other", "1", "8", "7", "2"}, "ERR TopK: invalid decay value. must be '<= 1' & '> 0'"},
+		{[]string{"TOPK.RESERVE", "other", "100000000"}, "ERR TopK: too many counters"},
+		{[]string{"TOPK.RESERVE", "other", "1", "4294967296", "4294967296", "0.9"}, "ERR TopK: too many counters"},
+		{[]string{"TOPK.RESERVE", "other", "1", "65536", "65536", "0.9"}, "ERR TopK: too many counters"},
+		{[]string{"TOPK.LIST", "paths", "WITHCOUNTS"}, "ERR syntax error"},
+	} {
+		require.Equal(t, test.err, c.do(t, test.args...), "%q", test.args)
+	}
+
+	// an invalid increment does not count any of the items.
+	require.Equal(t, []any{0}, c.do(t, "TOPK.COUNT", "paths", "a"))
+}
+
+func TestServer_MaxCounters(t *testing.T) {
+	_, c := serve(t, WithMaxCounters(100))
+
+	require.Equal(t, "OK", c.do(t, "TOPK.RESERVE", "paths", "5"))
+	require.Equal(t, "OK", c.do(t, "TOPK.RESERVE", "agents", "1", "5", "8", "0.9"))
+	require.Equal(t, replyError("ERR TopK: too many counters"), c.do(t, "TOPK.RESERVE", "clients", "2"))
+	require.Equal(t, "OK", c.do(t, "TOPK.RESERVE", "clients", "1"))
+
+	require.Panics(t, func() { New(WithMaxCounters(0)) })
+}
+
+func TestServer_Health(t *testing.T) {
+	s, c := serve(t)
+
+	require.Equal(t, "PONG", c.do(t, "PING"))
+	require.Equal(t, "hello", c.do(t, "PING", "hello"))
+	require.Equal(t, "OK", c.do(t, "TOPK.RESERVE", "paths", "2"))
+
+	info := c.do(t, "INFO").(string)
+	require.Contains(t, info, "connected_clients:1\r\n")
+	require.Contains(t, info, "total_commands_processed:4\r\n")
+	require.Contains(t, info, "topk_keys:1\r\n")
+
+	// inline commands and pipelined commands are replied to in order.
+	_, err := io.WriteString(c.conn, "PING\r\nTOPK.ADD paths a\nQUIT\r\n")
+	require.NoError(t, err)
+	require.Equal(t, "PONG", c.reply(t))
+	require.Equal(t, []any{nil}, c.reply(t))
+	require.Equal(t, "OK", c.reply(t))
+
+	_, err = c.reader.ReadByte()
+	require.ErrorIs(t, err, io.EOF)
+
+	require.Eventually(t, func() bool {
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+
+		return len(s.conns) == 0
+	}, time.Second, time.Millisecond)
+}
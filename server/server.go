@@ -0,0 +1,469 @@
+// Package server exposes named summaries of string keys over HTTP, for running a summary as a sidecar of another process.
+//
+// Every endpoint acts on the summary named by the summary query parameter, or on the summary named "default" without one:
+//
+//	POST /hit                 counts a JSON hit {"key": "a", "weight": 2}, or an array of them; the weight is 1 by default
+//	GET  /top?k=10            queries the top-k elements, with k up to report.MaxK
+//	GET  /frequent?phi=0.01   queries the elements that contribute more than phi of all hits
+//	GET  /count?key=a         queries the count of an element
+//	GET  /snapshot            encodes the summary in the binary format
+//	PUT  /snapshot            replaces the summary with one in the binary format
+//
+// The results of /top and /frequent are encoded by the report package, in JSON unless the format query parameter names another format.
+// Summaries are listed by GET /summaries, created by PUT /summaries/{name} with an optional JSON body {"capacity": 1000},
+// and deleted by DELETE /summaries/{name}. Summaries and snapshots above the maximum capacity are rejected.
+// Errors are reported with a JSON body {"error": "..."}.
+package server
+
+import (
+	"bytes"
+	"cmp"
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	hh "heavy-hitters"
+	"heavy-hitters/report"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+)
+
+// DefaultSummary is the name of the summary created by New, which is used by requests that do not name a summary.
+const DefaultSummary = "default"
+
+// Option configures a Server.
+type Option func(*options)
+
+type options struct {
+	capacity     int
+	maxCapacity  int
+	maxBodyBytes int64
+}
+
+// WithCapacity sets the capacity of the default summary and of the summaries created without a capacity, 1000 by default.
+func WithCapacity(capacity int) Option {
+	return func(o *options) {
+		o.capacity = capacity
+	}
+}
+
+// WithMaxCapacity limits the capacity of the summaries created by requests and restored from snapshots, 1<<20 counters by default,
+// since the memory of a summary grows with its capacity.
+func WithMaxCapacity(capacity int) Option {
+	return func(o *options) {
+		o.maxCapacity = capacity
+	}
+}
+
+// WithMaxBodyBytes limits the size of request bodies, such as batches of hits and snapshots, 32 MiB by default.
+func WithMaxBodyBytes(bytes int64) Option {
+	return func(o *options) {
+		o.maxBodyBytes = bytes
+	}
+}
+
+// Server is an http.Handler serving named summaries, which is safe for concurrent use.
+type Server struct {
+	options
+	mutex     sync.RWMutex
+	summaries map[string]*summary
+	mux       *http.ServeMux
+}
+
+// summary is a named summary along with its capacity.
+type summary struct {
+	*hh.Synchronized[string]
+	capacity int
+}
+
+// New creates a server with a single summary named [DefaultSummary].
+func New(opts ...Option) (*Server, error) {
+	o := options{capacity: 1000, maxCapacity: 1 << 20, maxBodyBytes: 32 << 20}
+
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	if o.capacity <= 0 || o.maxBodyBytes <= 0 {
+		return nil, errors.New("capacity and maximum body size must be positive")
+	}
+
+	if o.capacity > o.maxCapacity {
+		return nil, fmt.Errorf("capacity %d exceeds the maximum capacity %d", o.capacity, o.maxCapacity)
+	}
+
+	s := &Server{
+		options:   o,
+		summaries: make(map[string]*summary),
+		mux:       http.NewServeMux(),
+	}
+
+	if err := s.Create(DefaultSummary, o.capacity); err != nil {
+		return nil, err
+	}
+
+	s.mux.HandleFunc("POST /hit", s.hit)
+	s.mux.HandleFunc("GET /top", s.top)
+	s.mux.HandleFunc("GET /frequent", s.frequent)
+	s.mux.HandleFunc("GET /count", s.count)
+	s.mux.HandleFunc("GET /snapshot", s.getSnapshot)
+	s.mux.HandleFunc("PUT /snapshot", s.putSnapshot)
+	s.mux.HandleFunc("GET /summaries", s.list)
+	s.mux.HandleFunc("PUT /summaries/{name}", s.create)
+	s.mux.HandleFunc("DELETE /summaries/{name}", s.delete)
+
+	return s, nil
+}
+
+// ServeHTTP dispatches the request to the endpoint matching its method and path.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// errExists is returned when creating a summary with the name of an existing summary.
+var errExists = errors.New("summary already exists")
+
+// Create adds an empty summary with the given name and capacity, which must not exceed the maximum capacity.
+func (s *Server) Create(name string, capacity int) error {
+	if name == "" || capacity <= 0 {
+		return fmt.Errorf("invalid summary %q with capacity %d", name, capacity)
+	}
+
+	if capacity > s.maxCapacity {
+		return fmt.Errorf("capacity %d exceeds the maximum capacity %d", capacity, s.maxCapacity)
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, exists := s.summaries[name]; exists {
+		return fmt.Errorf("%w: %q", errExists, name)
+	}
+
+	s.summaries[name] = &summary{
+		Synchronized: hh.NewSynchronized[string](hh.NewStreamSummary[string](capacity, hh.WithTieBreak[string](hh.TieBreakKey))),
+		capacity:     capacity,
+	}
+
+	return nil
+}
+
+// summary finds the summary named by the request, writing an error if it does not exist.
+func (s *Server) summary(w http.ResponseWriter, r *http.Request) (*summary, bool) {
+	name := r.URL.Query().Get("summary")
+	if name == "" {
+		name = DefaultSummary
+	}
+
+	s.mutex.RLock()
+	named, found := s.summaries[name]
+	s.mutex.RUnlock()
+
+	if !found {
+		writeError(w, http.StatusNotFound, fmt.Errorf("unknown summary %q", name))
+	}
+
+	return named, found
+}
+
+// jsonHit is the JSON encoding of a hit.
+type jsonHit struct {
+	Key    string `json:"key"`
+	Weight *int   `json:"weight"`
+}
+
+// jsonCount is the JSON encoding of the count of an element.
+type jsonCount struct {
+	Key       string `json:"key"`
+	Count     int    `json:"count"`
+	Error     int    `json:"error"`
+	Monitored bool   `json:"monitored"`
+}
+
+// hit counts a single hit or a batch of hits, responding with the counts of their elements after the hits.
+// A batch is counted atomically, so queries never observe part of it.
+func (s *Server) hit(w http.ResponseWriter, r *http.Request) {
+	named, found := s.summary(w, r)
+	if !found {
+		return
+	}
+
+	body, ok := s.readBody(w, r)
+	if !ok {
+		return
+	}
+
+	body = bytes.TrimSpace(body)
+	batch := len(body) > 0 && body[0] == '['
+
+	var hits []jsonHit
+	var err error
+
+	if batch {
+		err = json.Unmarshal(body, &hits)
+	} else {
+		hits = make([]jsonHit, 1)
+		err = json.Unmarshal(body, &hits[0])
+	}
+
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid hits: %w", err))
+		return
+	}
+
+	for _, h := range hits {
+		if h.Weight != nil && *h.Weight <= 0 {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid weight %d for key %q, expected a positive weight", *h.Weight, h.Key))
+			return
+		}
+	}
+
+	counts := make([]jsonCount, len(hits))
+
+	named.Do(func(summary hh.WeightedHeavyHitters[string]) {
+		for _, h := range hits {
+			weight := 1
+			if h.Weight != nil {
+				weight = *h.Weight
+			}
+
+			summary.HitN(h.Key, weight)
+		}
+
+		// the counts are looked up once the whole batch is counted, since an element hit early in the batch may have been evicted later.
+		for i, h := range hits {
+			count, monitored := summary.Get(h.Key)
+			counts[i] = jsonCount{Key: h.Key, Count: count.Count, Error: count.Error, Monitored: monitored}
+		}
+	})
+
+	if batch {
+		writeJSON(w, http.StatusOK, counts)
+	} else {
+		writeJSON(w, http.StatusOK, counts[0])
+	}
+}
+
+// top responds with the top-k elements, 10 by default.
+func (s *Server) top(w http.ResponseWriter, r *http.Request) {
+	s.query(w, r, func(summary hh.WeightedHeavyHitters[string], k int) report.Result {
+		return report.Top[string](summary, k)
+	})
+}
+
+// frequent responds with the elements that contribute more than phi of all hits, 0.01 by default.
+func (s *Server) frequent(w http.ResponseWriter, r *http.Request) {
+	phi := 0.01
+
+	if value := r.URL.Query().Get("phi"); value != "" {
+		var err error
+		if phi, err = strconv.ParseFloat(value, 64); err != nil || !(phi > 0 && phi <= 1) {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid phi %q, expected a number in the range (0, 1]", value))
+			return
+		}
+	}
+
+	// every frequent element is returned, so k does not limit the result.
+	s.query(w, r, func(summary hh.WeightedHeavyHitters[string], _ int) report.Result {
+		return report.Frequent[string](summary, phi)
+	})
+}
+
+// query responds with the result of a query of the summary for the k query parameter, rendered in the format named by the request.
+func (s *Server) query(w http.ResponseWriter, r *http.Request, query func(summary hh.WeightedHeavyHitters[string], k int) report.Result) {
+	named, found := s.summary(w, r)
+	if !found {
+		return
+	}
+
+	results := func(k int) []report.Result {
+		var result report.Result
+
+		named.Do(func(summary hh.WeightedHeavyHitters[string]) {
+			result = query(summary, k)
+		})
+
+		return []report.Result{result}
+	}
+
+	report.Handler(results, "json", report.WithErrorWriter(writeError)).ServeHTTP(w, r)
+}
+
+// count responds with the count of the element given by the key query parameter.
+func (s *Server) count(w http.ResponseWriter, r *http.Request) {
+	if !r.URL.Query().Has("key") {
+		writeError(w, http.StatusBadRequest, errors.New("missing key"))
+		return
+	}
+
+	named, found := s.summary(w, r)
+	if !found {
+		return
+	}
+
+	key := r.URL.Query().Get("key")
+	count, monitored := named.Get(key)
+
+	writeJSON(w, http.StatusOK, jsonCount{Key: key, Count: count.Count, Error: count.Error, Monitored: monitored})
+}
+
+// getSnapshot responds with the summary in the binary format.
+func (s *Server) getSnapshot(w http.ResponseWriter, r *http.Request) {
+	named, found := s.summary(w, r)
+	if !found {
+		return
+	}
+
+	var data []byte
+	var err error
+
+	named.Do(func(summary hh.WeightedHeavyHitters[string]) {
+		data, err = summary.(encoding.BinaryMarshaler).MarshalBinary()
+	})
+
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	_, _ = w.Write(data)
+}
+
+// putSnapshot replaces the summary with the one in the binary format in the body of the request, including its capacity.
+// Snapshots above the maximum capacity are rejected from their header, before decoding them.
+func (s *Server) putSnapshot(w http.ResponseWriter, r *http.Request) {
+	named, found := s.summary(w, r)
+	if !found {
+		return
+	}
+
+	data, ok := s.readBody(w, r)
+	if !ok {
+		return
+	}
+
+	header, err := hh.DecodeHeader(data)
+	if err == nil && header.Capacity > s.maxCapacity {
+		err = fmt.Errorf("capacity %d exceeds the maximum capacity %d", header.Capacity, s.maxCapacity)
+	}
+
+	if err == nil {
+		named.Do(func(summary hh.WeightedHeavyHitters[string]) {
+			err = summary.(encoding.BinaryUnmarshaler).UnmarshalBinary(data)
+		})
+	}
+
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid snapshot: %w", err))
+		return
+	}
+
+	s.mutex.Lock()
+	named.capacity = header.Capacity
+	s.mutex.Unlock()
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// jsonSummary is the JSON encoding of a named summary.
+type jsonSummary struct {
+	Name     string `json:"name"`
+	Capacity int    `json:"capacity"`
+	Hits     int    `json:"hits"`
+}
+
+// list responds with every summary, ordered by name.
+func (s *Server) list(w http.ResponseWriter, r *http.Request) {
+	s.mutex.RLock()
+	summaries := make([]jsonSummary, 0, len(s.summaries))
+
+	for name, named := range s.summaries {
+		summaries = append(summaries, jsonSummary{Name: name, Capacity: named.capacity, Hits: named.Hits()})
+	}
+
+	s.mutex.RUnlock()
+
+	slices.SortFunc(summaries, func(a, b jsonSummary) int {
+		return cmp.Compare(a.Name, b.Name)
+	})
+
+	writeJSON(w, http.StatusOK, summaries)
+}
+
+// create adds a summary with the capacity in the optional JSON body of the request.
+func (s *Server) create(w http.ResponseWriter, r *http.Request) {
+	request := struct {
+		Capacity int `json:"capacity"`
+	}{Capacity: s.capacity}
+
+	body, ok := s.readBody(w, r)
+	if !ok {
+		return
+	}
+
+	var err error
+
+	if len(bytes.TrimSpace(body)) > 0 {
+		err = json.Unmarshal(body, &request)
+	}
+
+	if err == nil {
+		err = s.Create(r.PathValue("name"), request.Capacity)
+	}
+
+	switch {
+	case errors.Is(err, errExists):
+		writeError(w, http.StatusConflict, err)
+	case err != nil:
+		writeError(w, http.StatusBadRequest, err)
+	default:
+		writeJSON(w, http.StatusCreated, jsonSummary{Name: r.PathValue("name"), Capacity: request.Capacity})
+	}
+}
+
+// delete removes a summary.
+func (s *Server) delete(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+
+	s.mutex.Lock()
+	_, found := s.summaries[name]
+	delete(s.summaries, name)
+	s.mutex.Unlock()
+
+	if !found {
+		writeError(w, http.StatusNotFound, fmt.Errorf("unknown summary %q", name))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// readBody reads the body of the request up to the maximum size, writing an error if it cannot be read.
+func (s *Server) readBody(w http.ResponseWriter, r *http.Request) ([]byte, bool) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, s.maxBodyBytes))
+
+	var tooLarge *http.MaxBytesError
+
+	switch {
+	case errors.As(err, &tooLarge):
+		writeError(w, http.StatusRequestEntityTooLarge, err)
+	case err != nil:
+		writeError(w, http.StatusBadRequest, err)
+	}
+
+	return body, err == nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,178 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/stretchr/testify/require"
+	hh "heavy-hitters"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// do sends a request to the server, returning the status and body of the response.
+func do(t *testing.T, s *Server, method, target, body string) (int, string) {
+	recorder := httptest.NewRecorder()
+	s.ServeHTTP(recorder, httptest.NewRequest(method, target, strings.NewReader(body)))
+
+	response, err := io.ReadAll(recorder.Result().Body)
+	require.NoError(t, err)
+
+	return recorder.Code, string(response)
+}
+
+func TestServer(t *testing.T) {
+	s, err := New(WithCapacity(10))
+	require.NoError(t, err)
+
+	status, body := do(t, s, http.MethodPost, "/hit", `{"key": "a"}`)
+	require.Equal(t, http.StatusOK, status)
+	require.JSONEq(t, `{"key": "a", "count": 1, "error": 0, "monitored": true}`, body)
+
+	status, body = do(t, s, http.MethodPost, "/hit", `[{"key": "b", "weight": 3}, {"key": "a"}]`)
+	require.Equal(t, http.StatusOK, status)
+	require.JSONEq(t, `[{"key": "b", "count": 3, "error": 0, "monitored": true}, {"key": "a", "count": 2, "error": 0, "monitored": true}]`, body)
+
+	status, body = do(t, s, http.MethodGet, "/top?k=1", "")
+	require.Equal(t, http.StatusOK, status)
+	require.JSONEq(t, `[{"name": "top", "hits": 5, "guaranteed": true, "ordered": true,
+		"rows": [{"rank": 1, "key": "b", "count": 3, "error": 0, "lower_bound": 3, "share": 0.6}]}]`, body)
+
+	status, body = do(t, s, http.MethodGet, "/frequent?phi=0.2&format=csv", "")
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, "result,rank,key,count,error,lower_bound,share\nfrequent,1,b,3,0,3,0.6\nfrequent,2,a,2,0,2,0.4\n", body)
+
+	status, body = do(t, s, http.MethodGet, "/count?key=a", "")
+	require.Equal(t, http.StatusOK, status)
+	require.JSONEq(t, `{"key": "a", "count": 2, "error": 0, "monitored": true}`, body)
+
+	status, body = do(t, s, http.MethodGet, "/count?key=c", "")
+	require.Equal(t, http.StatusOK, status)
+	require.JSONEq(t, `{"key": "c", "count": 0, "error": 0, "monitored": false}`, body)
+}
+
+func TestServer_Summaries(t *testing.T) {
+	s, err := New()
+	require.NoError(t, err)
+
+	status, body := do(t, s, http.MethodPut, "/summaries/paths", `{"capacity": 5}`)
+	require.Equal(t, http.StatusCreated, status)
+	require.JSONEq(t, `{"name": "paths", "capacity": 5, "hits": 0}`, body)
+
+	status, _ = do(t, s, http.MethodPut, "/summaries/paths", "")
+	require.Equal(t, http.StatusConflict, status)
+
+	status, _ = do(t, s, http.MethodPut, "/summaries/agents", "")
+	require.Equal(t, http.StatusCreated, status)
+
+	status, _ = do(t, s, http.MethodPost, "/hit?summary=paths", `{"key": "/", "weight": 2}`)
+	require.Equal(t, http.StatusOK, status)
+
+	status, body = do(t, s, http.MethodGet, "/summaries", "")
+	require.Equal(t, http.StatusOK, status)
+	require.JSONEq(t, `[{"name": "agents", "capacity": 1000, "hits": 0},
+		{"name": "default", "capacity": 1000, "hits": 0},
+		{"name": "paths", "capacity": 5, "hits": 2}]`, body)
+
+	status, _ = do(t, s, http.MethodDelete, "/summaries/paths", "")
+	require.Equal(t, http.StatusNoContent, status)
+
+	status, _ = do(t, s, http.MethodDelete, "/summaries/paths", "")
+	require.Equal(t, http.StatusNotFound, status)
+
+	status, body = do(t, s, http.MethodGet, "/top?summary=paths", "")
+	require.Equal(t, http.StatusNotFound, status)
+	require.JSONEq(t, `{"error": "unknown summary \"paths\""}`, body)
+}
+
+func TestServer_Snapshot(t *testing.T) {
+	source := hh.NewStreamSummary[string](3)
+	source.HitN("a", 4)
+	source.Hit("b")
+
+	data, err := source.MarshalBinary()
+	require.NoError(t, err)
+
+	s, err := New()
+	require.NoError(t, err)
+
+	recorder := httptest.NewRecorder()
+	s.ServeHTTP(recorder, httptest.NewRequest(http.MethodPut, "/snapshot", bytes.NewReader(data)))
+	require.Equal(t, http.StatusNoContent, recorder.Code)
+
+	status, body := do(t, s, http.MethodGet, "/count?key=a", "")
+	require.Equal(t, http.StatusOK, status)
+	require.JSONEq(t, `{"key": "a", "count": 4, "error": 0, "monitored": true}`, body)
+
+	status, body = do(t, s, http.MethodGet, "/summaries", "")
+	require.Equal(t, http.StatusOK, status)
+	require.JSONEq(t, `[{"name": "default", "capacity": 3, "hits": 5}]`, body)
+
+	status, body = do(t, s, http.MethodGet, "/snapshot", "")
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, data, []byte(body))
+
+	status, body = do(t, s, http.MethodPut, "/snapshot", "not a summary")
+	require.Equal(t, http.StatusBadRequest, status)
+	require.Contains(t, body, hh.ErrCorrupt.Error())
+}
+
+func TestServer_MaxCapacity(t *testing.T) {
+	s, err := New(WithCapacity(2), WithMaxCapacity(2))
+	require.NoError(t, err)
+
+	data, err := hh.NewStreamSummary[string](3).MarshalBinary()
+	require.NoError(t, err)
+
+	recorder := httptest.NewRecorder()
+	s.ServeHTTP(recorder, httptest.NewRequest(http.MethodPut, "/snapshot", bytes.NewReader(data)))
+	require.Equal(t, http.StatusBadRequest, recorder.Code)
+	require.Contains(t, recorder.Body.String(), "maximum capacity")
+
+	status, _ := do(t, s, http.MethodPut, "/summaries/paths", `{"capacity": 3}`)
+	require.Equal(t, http.StatusBadRequest, status)
+
+	status, _ = do(t, s, http.MethodPut, "/summaries/paths", `{"capacity": 2}`)
+	require.Equal(t, http.StatusCreated, status)
+
+	status, body := do(t, s, http.MethodGet, "/summaries", "")
+	require.Equal(t, http.StatusOK, status)
+	require.JSONEq(t, `[{"name": "default", "capacity": 2, "hits": 0}, {"name": "paths", "capacity": 2, "hits": 0}]`, body)
+}
+
+func TestServer_Invalid(t *testing.T) {
+	_, err := New(WithCapacity(0))
+	require.Error(t, err)
+
+	_, err = New(WithCapacity(10), WithMaxCapacity(5))
+	require.Error(t, err)
+
+	s, err := New(WithMaxBodyBytes(26))
+	require.NoError(t, err)
+
+	for _, test := range []struct {
+		method, target, body string
+		status               int
+	}{
+		{http.MethodPost, "/hit", `{"key": `, http.StatusBadRequest},
+		{http.MethodPost, "/hit", `{"key": "a", "weight": 0}`, http.StatusBadRequest},
+		{http.MethodPost, "/hit", `[{"key": "a"}, {"key": "b"}]`, http.StatusRequestEntityTooLarge},
+		{http.MethodGet, "/hit", "", http.StatusMethodNotAllowed},
+		{http.MethodGet, "/top?k=0", "", http.StatusBadRequest},
+		{http.MethodGet, "/top?format=xml", "", http.StatusBadRequest},
+		{http.MethodGet, "/frequent?phi=2", "", http.StatusBadRequest},
+		{http.MethodGet, "/count", "", http.StatusBadRequest},
+		{http.MethodPut, "/summaries/paths", `{"capacity": -1}`, http.StatusBadRequest},
+	} {
+		status, body := do(t, s, test.method, test.target, test.body)
+		require.Equal(t, test.status, status, "%s %s", test.method, test.target)
+
+		if status != http.StatusMethodNotAllowed {
+			var response map[string]string
+			require.NoError(t, json.Unmarshal([]byte(body), &response))
+			require.NotEmpty(t, response["error"])
+		}
+	}
+}
@@ -3,6 +3,7 @@ package heavy_hitters
 import (
 	"cmp"
 	"math"
+	"slices"
 )
 
 // StreamSummary is a data structure used to implement the [SpaceSaving] algorithm.
@@ -11,11 +12,30 @@ import (
 // [SpaceSaving]: https://www.cs.ucsb.edu/sites/default/files/documents/2005-23.pdf
 type StreamSummary[T cmp.Ordered] struct {
 	hits     int
+	capacity int
 	elements map[T]*Node[frequencyCounter[T]]
 	// A list of buckets of counters with the same frequency.
 	// The buckets are used to maintain a sorted data structure even in the face of multiple counters with the same frequency.
 	// The head of the list is the maximum frequency and the tail is the minimum.
 	buckets *List[frequencyBucket[T]]
+	// The order of counters with the same frequency in the results of Top and Frequent.
+	tieBreak TieBreak
+	// Optional callbacks; a nil callback costs nothing on the hot path.
+	onEvict      func(T, Count)
+	topK         int
+	onTopKChange func(entered, left []T)
+	// The most recently observed top-k elements and their set, only maintained when onTopKChange is set.
+	top    []T
+	topSet map[T]struct{}
+	// The count of the k-th element of top when it was observed, or zero while fewer than k elements are monitored.
+	// Elements of the top-k only move up, so any element below this count cannot enter the top-k.
+	kth int
+	// The number of monitored elements that were replaced or dropped.
+	evictions int
+	// The count an element that is not monitored may have been counted up to while unused counters remain.
+	// It is only positive once a full summary is grown by resize, since the new counters may monitor elements that were evicted before.
+	// Only the summaries of a GroupedSummary are resized, and those are never serialized, so the binary formats do not carry it.
+	floor int
 }
 
 // frequencyBucket maintains a list of counts with the same frequency.
@@ -28,59 +48,161 @@ type frequencyBucket[T cmp.Ordered] struct {
 
 // frequencyCounter counts the frequency of an element in a stream along with its error bounds.
 type frequencyCounter[T cmp.Ordered] struct {
-	key    T
-	count  int
-	error  int
-	bucket *Node[frequencyBucket[T]]
+	key   T
+	count int
+	error int
+	// The number of hits seen by the summary when the counter started monitoring the key.
+	arrival int
+	bucket  *Node[frequencyBucket[T]]
 }
 
 // Hit increments the frequency for the given element, then returns an approximation of the current frequency.
 func (s *StreamSummary[T]) Hit(e T) Count {
-	s.hits++
+	return s.HitN(e, 1)
+}
+
+// HitN increments the frequency for the given element by a weight, then returns an approximation of the current frequency.
+// Weights that are not positive are ignored.
+func (s *StreamSummary[T]) HitN(e T, n int) Count {
+	if n <= 0 {
+		count, _ := s.Get(e)
+		return count
+	}
+
+	s.hits = saturatingAdd(s.hits, n)
 
 	node, monitored := s.elements[e]
 
+	var evicted frequencyCounter[T]
+
 	if !monitored {
-		// get the node for element with least hits
+		// use an unused counter, or get the node for element with least hits
 		// ties can be broken arbitrarily
-		node = s.buckets.Tail().Value.counts.Tail()
+		if node = s.unused(); node == nil {
+			node = s.buckets.Tail().Value.counts.Tail()
+		}
 
 		// avoid deleting the element from the elements if e is the zero value.
 		if node.Value.count > 0 {
+			evicted = node.Value
 			delete(s.elements, node.Value.key)
+			s.evictions++
+		} else {
+			node.Value.count = s.floor
 		}
 
 		// replace the min with e
 		node.Value.key = e
 		// the error is the value of min
 		node.Value.error = node.Value.count
+		node.Value.arrival = s.hits
 		s.elements[e] = node
 	}
 
-	s.incrementCounter(node)
+	incrementCounter(node, n)
+
+	if s.onEvict != nil && evicted.count > 0 {
+		s.onEvict(evicted.key, Count{Count: evicted.count, Error: evicted.error})
+	}
+
+	if s.onTopKChange != nil {
+		_, top := s.topSet[e]
+		_, evictedTop := s.topSet[evicted.key]
+
+		// An element that was already in the top-k can only move up, so the set of top-k elements is unchanged,
+		// and so is it when another element stays below the k-th count without evicting an element of the top-k.
+		if !top && (node.Value.count >= s.kth || (evicted.count > 0 && evictedTop)) {
+			s.updateTopK()
+		}
+	}
 
 	return Count{Count: node.Value.count, Error: node.Value.error}
 }
 
-func (s *StreamSummary[T]) incrementCounter(node *Node[frequencyCounter[T]]) {
+// unused returns an unused counter, or nil if every counter monitors an element.
+// Unused counters are kept in a bucket of frequency zero at the tail, and are allocated on demand for decoded summaries.
+func (s *StreamSummary[T]) unused() *Node[frequencyCounter[T]] {
+	if len(s.elements) >= s.capacity {
+		return nil
+	}
+
+	tail := s.buckets.Tail()
+	if tail == nil || tail.Value.count > 0 {
+		tail = s.buckets.PushTail(frequencyBucket[T]{
+			counts: NewList[frequencyCounter[T]](),
+		}).Tail()
+		tail.Value.counts.PushTail(frequencyCounter[T]{bucket: tail})
+	}
+
+	return tail.Value.counts.Tail()
+}
+
+// updateTopK recomputes the top-k elements and notifies the callback of any elements that entered or left.
+func (s *StreamSummary[T]) updateTopK() {
+	previous, previousSet := s.top, s.topSet
+
+	top, _, _ := s.Top(s.topK)
+	s.setTop(top)
+
+	var entered, left []T
+
+	for _, e := range top {
+		if _, found := previousSet[e]; !found {
+			entered = append(entered, e)
+		}
+	}
+
+	for _, e := range previous {
+		if _, found := s.topSet[e]; !found {
+			left = append(left, e)
+		}
+	}
+
+	if len(entered) > 0 || len(left) > 0 {
+		s.onTopKChange(entered, left)
+	}
+}
+
+// setTop records the observed top-k elements along with the count of the k-th element.
+func (s *StreamSummary[T]) setTop(top []T) {
+	s.top, s.topSet, s.kth = top, make(map[T]struct{}, len(top)), 0
+
+	for _, e := range top {
+		s.topSet[e] = struct{}{}
+	}
+
+	if len(top) == s.topK {
+		s.kth = s.elements[top[len(top)-1]].Value.count
+	}
+}
+
+// incrementCounter moves a counter to the bucket of its incremented frequency, in O(1) for unit increments.
+// The counter becomes the tail of its new bucket, so the counters of a bucket are ordered from the least to the most recently incremented.
+func incrementCounter[T cmp.Ordered](node *Node[frequencyCounter[T]], n int) {
 	// the current bucket of the node, before incrementing
 	oldBucket := node.Value.bucket
+	node.Value.count = saturatingAdd(node.Value.count, n)
 
 	// The previous moves towards the head (assuming head-to-tail traversal).
 	// Moving buckets allows us to jump over any other counts with the same frequency.
-	node.Value.bucket = oldBucket.Previous()
-	node.Value.count += 1
+	// A weighted increment can jump over several buckets, up to the last bucket with a smaller frequency.
+	next := oldBucket
+	for next.Previous() != nil && next.Previous().Value.count < node.Value.count {
+		next = next.Previous()
+	}
+
+	node.Value.bucket = next.Previous()
 
 	if node.Value.bucket != nil && node.Value.count == node.Value.bucket.Value.count {
-		// If the new bucket exists (the old bucket was not the head), then add this node to the tail.
+		// If the new bucket exists (the next bucket was not the head), then add this node to the tail.
 		// Also, the new bucket's count has to match the count's incremented frequency.
 		// Only counts of the same frequency can be in the same bucket.
 		node.Value.bucket.Value.counts.PushTailNode(node)
 	} else {
-		// The old bucket was the head or its count was larger than the node's incremented frequency.
+		// The next bucket was the head or its previous bucket's count was larger than the node's incremented frequency.
 		// Create a new bucket to add this node.
-		// The new bucket will either be the head of the list, or be between the old bucket and the old bucket's previous bucket.
-		newBucket := oldBucket.InsertPrevious(frequencyBucket[T]{
+		// The new bucket will either be the head of the list, or be between the next bucket and the next bucket's previous bucket.
+		newBucket := next.InsertPrevious(frequencyBucket[T]{
 			count:  node.Value.count,
 			counts: NewList[frequencyCounter[T]](),
 		})
@@ -100,26 +222,31 @@ func (s *StreamSummary[T]) incrementCounter(node *Node[frequencyCounter[T]]) {
 // The first boolean is true iff the order of the top-k elements is correct and the implementation guarantees they are the actual top-k, irrespective of the errors.
 // The second boolean is true iff the implementation guarantees they are the actual top-k, irrespective of the errors.
 func (s *StreamSummary[T]) Top(k int) ([]T, bool, bool) {
-	topK := make([]T, 0, k)
+	topK := make([]T, 0, min(k, len(s.elements)))
 	order := true
 	guaranteed := false
 	minGuaranteedCount := math.MaxInt
 	previousGuaranteedCount := math.MaxInt
 
+	var counters []*frequencyCounter[T]
+
 OuterLoop:
 	for b := s.buckets.Head(); b != nil; b = b.Next() {
 		if b.Value.count == 0 {
 			continue
 		}
 
-		for c := b.Value.counts.Head(); c != nil; c = c.Next() {
+		// one more counter than needed is required to determine if the top-k are guaranteed.
+		counters = s.tied(b, counters, saturatingAdd(k-len(topK), 1))
+
+		for _, c := range counters {
 			if len(topK) >= k {
-				guaranteed = c.Value.count <= minGuaranteedCount
+				guaranteed = c.count <= minGuaranteedCount
 				break OuterLoop
 			}
 
-			topK = append(topK, c.Value.key)
-			guaranteedCount := c.Value.count - c.Value.error
+			topK = append(topK, c.key)
+			guaranteedCount := c.count - c.error
 			minGuaranteedCount = min(minGuaranteedCount, guaranteedCount)
 			order = order && (guaranteedCount <= previousGuaranteedCount)
 
@@ -138,6 +265,8 @@ func (s *StreamSummary[T]) Frequent(phi float64) ([]T, bool) {
 	frequent := make([]T, 0)
 	guaranteed := true
 
+	var counters []*frequencyCounter[T]
+
 OuterLoop:
 	for b := s.buckets.Head(); b != nil; b = b.Next() {
 		if b.Value.count <= threshold {
@@ -149,20 +278,82 @@ OuterLoop:
 			continue
 		}
 
-		for c := b.Value.counts.Head(); c != nil; c = c.Next() {
-			frequent = append(frequent, c.Value.key)
-			guaranteed = guaranteed && ((c.Value.count - c.Value.error) >= threshold)
+		counters = s.tied(b, counters, b.Value.counts.Len())
+
+		for _, c := range counters {
+			frequent = append(frequent, c.key)
+			guaranteed = guaranteed && ((c.count - c.error) >= threshold)
 		}
 	}
 
 	return frequent, guaranteed
 }
 
+// tied collects the counters of the given bucket into buf in the order dictated by the tie-break policy.
+// Without a tie-break policy, at most limit counters are collected in the order of the bucket.
+// Otherwise, all counters are needed to sort the bucket.
+func (s *StreamSummary[T]) tied(b *Node[frequencyBucket[T]], buf []*frequencyCounter[T], limit int) []*frequencyCounter[T] {
+	buf = buf[:0]
+
+	for c := b.Value.counts.Head(); c != nil; c = c.Next() {
+		if s.tieBreak == TieBreakNone && len(buf) >= limit {
+			break
+		}
+
+		buf = append(buf, &c.Value)
+	}
+
+	if s.tieBreak != TieBreakNone {
+		slices.SortFunc(buf, s.compare)
+	}
+
+	return buf
+}
+
+// compare orders two counters with the same frequency according to the tie-break policy.
+func (s *StreamSummary[T]) compare(a, b *frequencyCounter[T]) int {
+	switch s.tieBreak {
+	case TieBreakKey:
+		return cmp.Compare(a.key, b.key)
+	case TieBreakArrival:
+		return cmp.Compare(a.arrival, b.arrival)
+	case TieBreakError:
+		return cmp.Or(cmp.Compare(a.error, b.error), cmp.Compare(a.key, b.key))
+	default:
+		return 0
+	}
+}
+
 // Hits counts the total number of hits for all elements.
 func (s *StreamSummary[T]) Hits() int {
 	return s.hits
 }
 
+// Capacity is the maximum number of elements monitored by the summary.
+func (s *StreamSummary[T]) Capacity() int {
+	return s.capacity
+}
+
+// Evictions counts the monitored elements that were replaced by other elements, or dropped when merging or resizing the summary.
+func (s *StreamSummary[T]) Evictions() int {
+	return s.evictions
+}
+
+// MinCount is the smallest count of a monitored element, or zero if no element is monitored.
+// Once the summary is full, an element that is not monitored may have been counted up to this count.
+func (s *StreamSummary[T]) MinCount() int {
+	b := s.buckets.Tail()
+	if b != nil && b.Value.count == 0 {
+		b = b.Previous()
+	}
+
+	if b == nil {
+		return 0
+	}
+
+	return b.Value.count
+}
+
 // Get retrieves the approximated frequency for the given element, with a bounds on the error.
 func (s *StreamSummary[T]) Get(e T) (Count, bool) {
 	var count Count
@@ -177,7 +368,44 @@ func (s *StreamSummary[T]) Get(e T) (Count, bool) {
 
 // NewStreamSummary creates a new instance of a stream summary with the given capacity.
 // The error for frequency approximations is guaranteed to be bounded by Hits / capacity.
-func NewStreamSummary[T cmp.Ordered](capacity int) *StreamSummary[T] {
+// NewStreamSummary panics if the capacity or options are invalid; use [New] to handle invalid configurations.
+func NewStreamSummary[T cmp.Ordered](capacity int, opts ...Option[T]) *StreamSummary[T] {
+	s, err := New(append([]Option[T]{WithCapacity[T](capacity)}, opts...)...)
+	if err != nil {
+		panic(err)
+	}
+
+	return s
+}
+
+// New creates a new instance of a stream summary configured by the given options.
+// Exactly one of [WithCapacity], [WithEpsilon] or [WithMemoryBudget] must be given to size the summary.
+// An error wrapping [ErrInvalidOption] is returned if the options are invalid.
+func New[T cmp.Ordered](opts ...Option[T]) (*StreamSummary[T], error) {
+	var o options[T]
+
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	capacity, err := o.validate()
+	if err != nil {
+		return nil, err
+	}
+
+	return &StreamSummary[T]{
+		capacity:     capacity,
+		elements:     make(map[T]*Node[frequencyCounter[T]]),
+		buckets:      newBuckets[T](capacity),
+		tieBreak:     o.tieBreak,
+		onEvict:      o.onEvict,
+		topK:         o.topK,
+		onTopKChange: o.onTopKChange,
+	}, nil
+}
+
+// newBuckets creates a list with a single bucket of the given number of unused counters, whose frequency is zero.
+func newBuckets[T cmp.Ordered](capacity int) *List[frequencyBucket[T]] {
 	buckets := NewList[frequencyBucket[T]]().PushHead(frequencyBucket[T]{
 		counts: NewList[frequencyCounter[T]](),
 	})
@@ -189,8 +417,5 @@ func NewStreamSummary[T cmp.Ordered](capacity int) *StreamSummary[T] {
 		})
 	}
 
-	return &StreamSummary[T]{
-		elements: make(map[T]*Node[frequencyCounter[T]]),
-		buckets:  buckets,
-	}
+	return buckets
 }
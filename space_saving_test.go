@@ -4,6 +4,7 @@ import (
 	"github.com/stretchr/testify/require"
 	"math"
 	"math/rand"
+	"slices"
 	"testing"
 	"time"
 )
@@ -92,6 +93,205 @@ func TestSpaceSaving_ZeroValue(t *testing.T) {
 	require.Equal(t, 0, count1.Error)
 }
 
+func TestSpaceSaving_HitN(t *testing.T) {
+	hh := NewStreamSummary[string](2)
+
+	require.Equal(t, Count{Count: 3}, hh.HitN("a", 3))
+	require.Equal(t, Count{Count: 1}, hh.HitN("b", 1))
+	require.Equal(t, Count{Count: 5}, hh.HitN("b", 4))
+	require.Equal(t, Count{Count: 5}, hh.HitN("b", 0))
+	require.Equal(t, Count{Count: 5, Error: 3}, hh.HitN("c", 2))
+	require.Equal(t, 10, hh.Hits())
+
+	top, _, _ := hh.Top(2)
+	require.Equal(t, []string{"b", "c"}, top)
+}
+
+func TestSpaceSaving_TopLargeK(t *testing.T) {
+	hh := NewStreamSummary[string](4)
+	hh.HitN("a", 2)
+	hh.Hit("b")
+
+	// k only bounds the result, so a huge k must not be allocated up front.
+	top, _, _ := hh.Top(math.MaxInt)
+	require.Equal(t, []string{"a", "b"}, top)
+}
+
+func TestSpaceSaving_HitNSaturates(t *testing.T) {
+	hh := NewStreamSummary[string](1)
+
+	hh.HitN("a", math.MaxInt-1)
+	require.Equal(t, Count{Count: math.MaxInt}, hh.HitN("a", 2))
+	require.Equal(t, Count{Count: math.MaxInt, Error: math.MaxInt}, hh.HitN("b", math.MaxInt))
+	require.Equal(t, math.MaxInt, hh.Hits())
+}
+
+func TestSpaceSaving_HitNBounds(t *testing.T) {
+	rng := rand.New(rand.NewSource(42))
+	generator := rand.NewZipf(rng, 1.08, 2, 1_000)
+	hh := NewStreamSummary[uint64](50)
+	exact := NewNaive[uint64]()
+
+	for i := 0; i < 10_000; i++ {
+		e, weight := generator.Uint64(), 1+rng.Intn(100)
+		hh.HitN(e, weight)
+		exact.HitN(e, weight)
+	}
+
+	require.Equal(t, exact.Hits(), hh.Hits())
+
+	// the buckets must stay sorted in strictly descending order of frequency.
+	for b := hh.buckets.Head(); b.Next() != nil; b = b.Next() {
+		require.Greater(t, b.Value.count, b.Next().Value.count)
+	}
+
+	for e := range hh.elements {
+		count, _ := hh.Get(e)
+		actual, _ := exact.Get(e)
+		require.GreaterOrEqual(t, count.Count, actual.Count)
+		require.LessOrEqual(t, count.Count-count.Error, actual.Count)
+	}
+}
+
+func TestSpaceSaving_OnEvict(t *testing.T) {
+	var keys []string
+	var counts []Count
+
+	hh := NewStreamSummary[string](2, WithOnEvict(func(key string, count Count) {
+		keys = append(keys, key)
+		counts = append(counts, count)
+	}))
+
+	for _, e := range []string{"a", "b", "a", "c", "d"} {
+		hh.Hit(e)
+	}
+
+	require.Equal(t, []string{"b", "c"}, keys)
+	require.Equal(t, []Count{{Count: 1}, {Count: 2, Error: 1}}, counts)
+
+	_, found := hh.Get("b")
+	require.False(t, found)
+
+	count, found := hh.Get("d")
+	require.True(t, found)
+	require.Equal(t, Count{Count: 3, Error: 2}, count)
+}
+
+func TestSpaceSaving_OnTopKChange(t *testing.T) {
+	var entered, left [][]string
+
+	hh := NewStreamSummary[string](2, WithOnTopKChange(1, func(e, l []string) {
+		entered = append(entered, e)
+		left = append(left, l)
+	}))
+
+	for _, e := range []string{"a", "b", "a", "c"} {
+		hh.Hit(e)
+	}
+
+	require.Equal(t, [][]string{{"a"}}, entered)
+	require.Equal(t, [][]string{nil}, left)
+
+	hh.Hit("c")
+
+	require.Equal(t, [][]string{{"a"}, {"c"}}, entered)
+	require.Equal(t, [][]string{nil, {"a"}}, left)
+}
+
+func TestSpaceSaving_OnTopKChangeMatchesTop(t *testing.T) {
+	for _, policy := range []TieBreak{TieBreakNone, TieBreakKey, TieBreakArrival, TieBreakError} {
+		t.Run(policy.String(), func(t *testing.T) {
+			var top []int
+
+			hh := NewStreamSummary[int](8, WithTieBreak[int](policy), WithOnTopKChange(5, func(entered, left []int) {
+				for _, e := range left {
+					top = slices.DeleteFunc(top, func(x int) bool { return x == e })
+				}
+
+				top = append(top, entered...)
+			}))
+
+			generator := rand.NewZipf(rand.New(rand.NewSource(42)), 1.1, 2, 30)
+
+			for i := 0; i < 5_000; i++ {
+				hh.Hit(int(generator.Uint64()))
+
+				expected, _, _ := hh.Top(5)
+				require.ElementsMatch(t, expected, top)
+			}
+		})
+	}
+}
+
+func TestSpaceSaving_TieBreak(t *testing.T) {
+	policies := map[TieBreak][]string{
+		TieBreakNone:    {"a", "b", "c"},
+		TieBreakKey:     {"a", "b", "c"},
+		TieBreakArrival: {"c", "b", "a"},
+		TieBreakError:   {"a", "b", "c"},
+	}
+
+	for policy, expected := range policies {
+		t.Run(policy.String(), func(t *testing.T) {
+			hh, err := New(WithCapacity[string](4), WithTieBreak[string](policy))
+			require.NoError(t, err)
+
+			for _, e := range []string{"c", "b", "a", "a", "b", "c"} {
+				hh.Hit(e)
+			}
+
+			top, _, _ := hh.Top(3)
+			require.Equal(t, expected, top)
+
+			frequent, _ := hh.Frequent(0.1)
+			require.Equal(t, expected, frequent)
+		})
+	}
+}
+
+func TestSpaceSaving_TieBreakError(t *testing.T) {
+	policies := map[TieBreak][]string{
+		TieBreakNone:  {"b", "a"},
+		TieBreakKey:   {"a", "b"},
+		TieBreakError: {"b", "a"},
+	}
+
+	for policy, expected := range policies {
+		t.Run(policy.String(), func(t *testing.T) {
+			hh, err := New(WithCapacity[string](2), WithTieBreak[string](policy))
+			require.NoError(t, err)
+
+			for _, e := range []string{"b", "b", "c", "a"} {
+				hh.Hit(e)
+			}
+
+			top, _, _ := hh.Top(2)
+			require.Equal(t, expected, top)
+		})
+	}
+}
+
+func TestSpaceSaving_Stats(t *testing.T) {
+	hh := NewStreamSummary[string](2)
+	require.Equal(t, 2, hh.Capacity())
+	require.Equal(t, 0, hh.MinCount())
+
+	hh.HitN("a", 3)
+	require.Equal(t, 3, hh.MinCount())
+
+	for _, e := range []string{"b", "c", "d"} {
+		hh.Hit(e)
+	}
+
+	require.Equal(t, 2, hh.Evictions())
+	require.Equal(t, 3, hh.MinCount())
+
+	hh.Hit("e")
+	require.Equal(t, 3, hh.Evictions())
+	require.Equal(t, 3, hh.MinCount())
+	require.Equal(t, 2, hh.Capacity())
+}
+
 func BenchmarkSpaceSaving(b *testing.B) {
 	seed := time.Now().UTC().UnixNano()
 
@@ -103,6 +303,8 @@ func BenchmarkSpaceSaving(b *testing.B) {
 	ss := NewStreamSummary[uint64](100)
 
 	b.Run("Hit", func(b *testing.B) {
+		b.ReportAllocs()
+
 		for i := 0; i < b.N; i++ {
 			ss.Hit(generator.Uint64())
 		}
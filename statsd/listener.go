@@ -0,0 +1,149 @@
+package statsd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	hh "heavy-hitters"
+	"heavy-hitters/report"
+	"net"
+	"net/http"
+	"sync/atomic"
+)
+
+// maxPacketSize is the largest UDP payload, so no packet is truncated.
+const maxPacketSize = 65535
+
+// Listener summarizes the metrics of StatsD packets, which is safe for concurrent use.
+type Listener struct {
+	// Names, Tags and Sources count every metric line by its name, by each of its tags, and by the IP address of the client that sent it.
+	// Sources are keyed by host only, since clients usually send from ephemeral ports that would spread their hits over many keys.
+	Names   *hh.Synchronized[string]
+	Tags    *hh.Synchronized[string]
+	Sources *hh.Synchronized[string]
+
+	packets, metrics, skipped, malformed atomic.Int64
+}
+
+// Stats counts the packets and lines received by a Listener.
+type Stats struct {
+	Packets int
+	// Metrics counts the metric lines, Skipped the service checks and events, and Malformed the lines that failed to parse.
+	Metrics   int
+	Skipped   int
+	Malformed int
+}
+
+// New creates a listener whose summaries have the given capacity.
+func New(capacity int) (*Listener, error) {
+	if capacity <= 0 {
+		return nil, fmt.Errorf("capacity must be positive, got %d", capacity)
+	}
+
+	summary := func() *hh.Synchronized[string] {
+		return hh.NewSynchronized[string](hh.NewStreamSummary[string](capacity, hh.WithTieBreak[string](hh.TieBreakKey)))
+	}
+
+	return &Listener{Names: summary(), Tags: summary(), Sources: summary()}, nil
+}
+
+// Serve reads packets from the connection until it is closed, which returns nil, or fails.
+func (l *Listener) Serve(conn net.PacketConn) error {
+	buf := make([]byte, maxPacketSize)
+
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if n > 0 {
+			l.Handle(buf[:n], host(addr))
+		}
+
+		if errors.Is(err, net.ErrClosed) {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// host returns the IP address of a packet source without its port, or an empty string without a source.
+func host(addr net.Addr) string {
+	switch addr := addr.(type) {
+	case nil:
+		return ""
+	case *net.UDPAddr:
+		return addr.IP.String()
+	}
+
+	if host, _, err := net.SplitHostPort(addr.String()); err == nil {
+		return host
+	}
+
+	return addr.String()
+}
+
+// Handle summarizes the metrics of a packet sent by the given source, such as the IP address of the client, skipping its malformed lines.
+func (l *Listener) Handle(packet []byte, source string) {
+	l.packets.Add(1)
+
+	for _, line := range bytes.Split(packet, []byte("\n")) {
+		line = bytes.TrimSuffix(line, []byte("\r"))
+		if len(line) == 0 {
+			continue
+		}
+
+		m, err := Parse(line)
+
+		switch {
+		case errors.Is(err, errSkipped):
+			l.skipped.Add(1)
+			continue
+		case err != nil:
+			l.malformed.Add(1)
+			continue
+		}
+
+		l.metrics.Add(1)
+		l.Names.Hit(m.Name)
+		l.Sources.Hit(source)
+
+		for _, tag := range m.Tags {
+			l.Tags.Hit(tag)
+		}
+	}
+}
+
+// Stats returns the number of packets and lines received so far.
+func (l *Listener) Stats() Stats {
+	return Stats{
+		Packets:   int(l.packets.Load()),
+		Metrics:   int(l.metrics.Load()),
+		Skipped:   int(l.skipped.Load()),
+		Malformed: int(l.malformed.Load()),
+	}
+}
+
+// Top queries the top-k metric names, tags and sources, as results named "names", "tags" and "sources".
+func (l *Listener) Top(k int) []report.Result {
+	results := make([]report.Result, 0, 3)
+
+	for _, named := range []struct {
+		name    string
+		summary *hh.Synchronized[string]
+	}{{"names", l.Names}, {"tags", l.Tags}, {"sources", l.Sources}} {
+		named.summary.Do(func(summary hh.WeightedHeavyHitters[string]) {
+			result := report.Top[string](summary, k)
+			result.Name = named.name
+			results = append(results, result)
+		})
+	}
+
+	return results
+}
+
+// ServeHTTP responds to GET requests with the top-k metric names, tags and sources.
+// The k query parameter defaults to 10 and is at most report.MaxK, and the format query parameter names a format of the report package, json by default.
+func (l *Listener) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	report.Handler(l.Top, "json").ServeHTTP(w, r)
+}
@@ -0,0 +1,93 @@
+package statsd
+
+import (
+	"github.com/stretchr/testify/require"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestListener(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	l, err := New(10)
+	require.NoError(t, err)
+
+	done := make(chan error)
+
+	go func() {
+		done <- l.Serve(conn)
+	}()
+
+	client, err := net.Dial("udp", conn.LocalAddr().String())
+	require.NoError(t, err)
+	defer client.Close()
+
+	_, err = client.Write([]byte("requests:1|c|#env:prod,path:/a\nrequests:2|c|#env:prod\r\nlatency:x|ms\n\n_sc|check|0\nlatency:3|ms|#env:dev"))
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool { return l.Stats().Packets == 1 }, 5*time.Second, time.Millisecond)
+	require.Equal(t, Stats{Packets: 1, Metrics: 3, Skipped: 1, Malformed: 1}, l.Stats())
+
+	require.NoError(t, conn.Close())
+	require.NoError(t, <-done)
+
+	results := l.Top(2)
+	require.Len(t, results, 3)
+
+	require.Equal(t, "names", results[0].Name)
+	require.Equal(t, 3, results[0].Hits)
+	require.Equal(t, "requests", results[0].Rows[0].Key)
+	require.Equal(t, 2, results[0].Rows[0].Count)
+
+	require.Equal(t, "tags", results[1].Name)
+	require.Equal(t, "env=prod", results[1].Rows[0].Key)
+	require.Equal(t, 2, results[1].Rows[0].Count)
+
+	require.Equal(t, "sources", results[2].Name)
+	require.Equal(t, "127.0.0.1", results[2].Rows[0].Key)
+	require.Equal(t, 3, results[2].Rows[0].Count)
+}
+
+func TestListener_ServeHTTP(t *testing.T) {
+	l, err := New(10)
+	require.NoError(t, err)
+
+	l.Handle([]byte("requests:1|c|#env:prod"), "127.0.0.1")
+
+	recorder := httptest.NewRecorder()
+	l.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/?k=1&format=csv", nil))
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	body, err := io.ReadAll(recorder.Body)
+	require.NoError(t, err)
+	require.Equal(t, `result,rank,key,count,error,lower_bound,share
+names,1,requests,1,0,1,1
+tags,1,env=prod,1,0,1,1
+sources,1,127.0.0.1,1,0,1,1
+`, string(body))
+
+	for _, target := range []string{"/?k=0", "/?format=xml"} {
+		recorder = httptest.NewRecorder()
+		l.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, target, nil))
+		require.Equal(t, http.StatusBadRequest, recorder.Code, target)
+	}
+
+	recorder = httptest.NewRecorder()
+	l.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/", nil))
+	require.Equal(t, http.StatusMethodNotAllowed, recorder.Code)
+
+	_, err = New(0)
+	require.Error(t, err)
+}
+
+func TestHost(t *testing.T) {
+	require.Equal(t, "", host(nil))
+	require.Equal(t, "::1", host(&net.UDPAddr{IP: net.IPv6loopback, Port: 8125}))
+	require.Equal(t, "10.0.0.1", host(&net.TCPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 8125}))
+	require.Equal(t, "/tmp/statsd.sock", host(&net.UnixAddr{Name: "/tmp/statsd.sock", Net: "unixgram"}))
+}
@@ -0,0 +1,99 @@
+// Package statsd listens for StatsD and DogStatsD metrics over UDP and summarizes the top metric names, tags and sources,
+// to find the causes of metric cardinality explosions.
+//
+// Each line of a packet is a metric in the DogStatsD format, of which plain StatsD is a subset:
+//
+//	name:value[:value ...]|type[|@sample_rate][|#tag:value,tag ...][|c:container][|T timestamp]
+//
+// Service checks and events are skipped, and malformed lines are counted without failing the rest of the packet.
+package statsd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrMalformed is returned by Parse for a line that is not a valid metric.
+var ErrMalformed = errors.New("malformed metric")
+
+// errSkipped is returned by Parse for service checks and events, which are valid lines but not metrics.
+var errSkipped = errors.New("not a metric")
+
+// Metric is a parsed metric line.
+type Metric struct {
+	Name string
+	// Type is one of c, g, ms, h, s or d.
+	Type string
+	// Values is the number of values of the line, which packs several values since DogStatsD 1.1.
+	Values int
+	// SampleRate is the sample rate of the metric, or 1 if it is not sampled.
+	SampleRate float64
+	// Tags are the tags of the metric as key=value pairs, or a single key for tags without a value.
+	Tags []string
+}
+
+// types are the metric types of StatsD and DogStatsD.
+var types = map[string]bool{"c": true, "g": true, "ms": true, "h": true, "s": true, "d": true}
+
+// Parse parses a metric line, which must not end with a newline.
+func Parse(line []byte) (Metric, error) {
+	if bytes.HasPrefix(line, []byte("_sc|")) || bytes.HasPrefix(line, []byte("_e{")) {
+		return Metric{}, errSkipped
+	}
+
+	sections := strings.Split(string(line), "|")
+	if len(sections) < 2 {
+		return Metric{}, fmt.Errorf("%w: missing type in %q", ErrMalformed, line)
+	}
+
+	name, values, found := strings.Cut(sections[0], ":")
+	if !found || name == "" || values == "" {
+		return Metric{}, fmt.Errorf("%w: missing name or value in %q", ErrMalformed, line)
+	}
+
+	m := Metric{Name: name, Type: sections[1], Values: strings.Count(values, ":") + 1, SampleRate: 1}
+
+	if !types[m.Type] {
+		return Metric{}, fmt.Errorf("%w: unknown type %q", ErrMalformed, m.Type)
+	}
+
+	// the values of sets are identifiers, while the values of other types are numbers.
+	if m.Type != "s" {
+		for _, value := range strings.Split(values, ":") {
+			if _, err := strconv.ParseFloat(value, 64); err != nil {
+				return Metric{}, fmt.Errorf("%w: invalid value %q", ErrMalformed, value)
+			}
+		}
+	}
+
+	for _, section := range sections[2:] {
+		switch {
+		case strings.HasPrefix(section, "@"):
+			rate, err := strconv.ParseFloat(section[1:], 64)
+			if err != nil || rate <= 0 || rate > 1 {
+				return Metric{}, fmt.Errorf("%w: invalid sample rate %q", ErrMalformed, section)
+			}
+
+			m.SampleRate = rate
+		case strings.HasPrefix(section, "#"):
+			for _, tag := range strings.Split(section[1:], ",") {
+				if tag == "" {
+					continue
+				}
+
+				key, value, found := strings.Cut(tag, ":")
+				if found {
+					tag = key + "=" + value
+				}
+
+				m.Tags = append(m.Tags, tag)
+			}
+		}
+		// other sections, such as container identifiers and timestamps, do not affect the summaries.
+	}
+
+	return m, nil
+}
@@ -0,0 +1,44 @@
+package statsd
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	for line, expected := range map[string]Metric{
+		"requests:1|c":        {Name: "requests", Type: "c", Values: 1, SampleRate: 1},
+		"latency:1.5:2:3|ms":  {Name: "latency", Type: "ms", Values: 3, SampleRate: 1},
+		"users:alice|s|@0.25": {Name: "users", Type: "s", Values: 1, SampleRate: 0.25},
+		"queue.depth:-4|g|#env:prod,canary,,region:eu:west|c:abc|T1700000000": {
+			Name: "queue.depth", Type: "g", Values: 1, SampleRate: 1, Tags: []string{"env=prod", "canary", "region=eu:west"},
+		},
+	} {
+		m, err := Parse([]byte(line))
+		require.NoError(t, err, line)
+		require.Equal(t, expected, m, line)
+	}
+}
+
+func TestParse_Malformed(t *testing.T) {
+	for _, line := range []string{
+		"requests",
+		"requests|c",
+		"requests:1",
+		":1|c",
+		"requests:|c",
+		"requests:1|x",
+		"requests:one|c",
+		"requests:1:|c",
+		"requests:1|c|@2",
+		"requests:1|c|@x",
+	} {
+		_, err := Parse([]byte(line))
+		require.ErrorIs(t, err, ErrMalformed, line)
+	}
+
+	for _, line := range []string{"_sc|check|0", "_e{5,4}:title|text"} {
+		_, err := Parse([]byte(line))
+		require.ErrorIs(t, err, errSkipped, line)
+	}
+}
@@ -0,0 +1,69 @@
+package heavy_hitters
+
+import (
+	"cmp"
+	"sync"
+)
+
+// Synchronized makes a summary safe for concurrent use by guarding every call with a mutex.
+type Synchronized[T cmp.Ordered] struct {
+	mutex   sync.Mutex
+	summary WeightedHeavyHitters[T]
+}
+
+// NewSynchronized guards the summary with a mutex.
+// The summary must not be used directly afterwards, except through [Synchronized.Do].
+func NewSynchronized[T cmp.Ordered](summary WeightedHeavyHitters[T]) *Synchronized[T] {
+	return &Synchronized[T]{summary: summary}
+}
+
+func (s *Synchronized[T]) Hit(e T) Count {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.summary.Hit(e)
+}
+
+func (s *Synchronized[T]) HitN(e T, n int) Count {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.summary.HitN(e, n)
+}
+
+func (s *Synchronized[T]) Hits() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.summary.Hits()
+}
+
+func (s *Synchronized[T]) Get(e T) (Count, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.summary.Get(e)
+}
+
+func (s *Synchronized[T]) Frequent(phi float64) ([]T, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.summary.Frequent(phi)
+}
+
+func (s *Synchronized[T]) Top(k int) ([]T, bool, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.summary.Top(k)
+}
+
+// Do calls fn with the summary while holding the mutex, so several calls observe the same state of the summary.
+// The summary must not be retained after fn returns.
+func (s *Synchronized[T]) Do(fn func(summary WeightedHeavyHitters[T])) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	fn(s.summary)
+}
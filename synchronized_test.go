@@ -0,0 +1,43 @@
+package heavy_hitters
+
+import (
+	"github.com/stretchr/testify/require"
+	"sync"
+	"testing"
+)
+
+func TestSynchronized(t *testing.T) {
+	hh := NewSynchronized[int](NewStreamSummary[int](10))
+
+	var wg sync.WaitGroup
+
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for i := 0; i < 1_000; i++ {
+				hh.Hit(i % 4)
+				hh.HitN(4, 2)
+				hh.Top(3)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	require.Equal(t, 8*3_000, hh.Hits())
+
+	count, found := hh.Get(4)
+	require.True(t, found)
+	require.Equal(t, Count{Count: 16_000}, count)
+
+	hh.Do(func(summary WeightedHeavyHitters[int]) {
+		top, _, _ := summary.Top(1)
+		require.Equal(t, []int{4}, top)
+	})
+
+	frequent, _ := hh.Frequent(0.5)
+	require.Equal(t, []int{4}, frequent)
+}
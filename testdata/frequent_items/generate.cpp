@@ -0,0 +1,42 @@
+// Writes the Apache DataSketches frequent items fixtures used by frequent_items_test.go
+// with the C++ reference implementation, which is header-only:
+//
+//	g++ -std=c++11 -I datasketches-cpp/common/include -I datasketches-cpp/fi/include generate.cpp -o generate && ./generate
+#include <cstdint>
+#include <fstream>
+#include <string>
+
+#include <frequent_items_sketch.hpp>
+
+using datasketches::frequent_items_sketch;
+
+template<typename T>
+static void write(const char* name, const frequent_items_sketch<T>& sketch) {
+  std::ofstream out(name, std::ios::binary);
+  sketch.serialize(out);
+}
+
+int main() {
+  // a map of 2^4 slots holds at most 12 items.
+  frequent_items_sketch<std::string> empty(4);
+  write("empty.bin", empty);
+
+  frequent_items_sketch<std::string> strings(4);
+  strings.update("apple", 11);
+  strings.update("banana", 6);
+  strings.update("cherry", 3);
+  // the 13th item purges the sketch by the median weight of 1, which becomes its offset
+  // and leaves apple, banana and cherry with weights of 10, 5 and 2.
+  for (int i = 0; i < 10; i++) {
+    strings.update("item-" + std::to_string(i));
+  }
+  write("strings.bin", strings);
+
+  // a map of 2^3 slots holds at most 6 items.
+  frequent_items_sketch<int64_t> longs(3);
+  longs.update(42, 7);
+  longs.update(-1, 3);
+  write("longs.bin", longs);
+
+  return 0;
+}
@@ -0,0 +1,36 @@
+package heavy_hitters
+
+// TieBreak selects how elements with the same frequency are ordered in the results of Top and Frequent.
+type TieBreak int
+
+const (
+	// TieBreakNone leaves elements with the same frequency in an implementation defined order.
+	TieBreakNone TieBreak = iota
+	// TieBreakKey orders elements with the same frequency by ascending key using [cmp.Compare].
+	TieBreakKey
+	// TieBreakArrival orders elements with the same frequency by the order in which they were first counted.
+	TieBreakArrival
+	// TieBreakError orders elements with the same frequency by ascending error, then by ascending key.
+	TieBreakError
+)
+
+// String returns the name of the tie-break policy.
+func (t TieBreak) String() string {
+	switch t {
+	case TieBreakNone:
+		return "none"
+	case TieBreakKey:
+		return "key"
+	case TieBreakArrival:
+		return "arrival"
+	case TieBreakError:
+		return "error"
+	default:
+		return "unknown"
+	}
+}
+
+// valid is a predicate that tests if the tie-break policy is one of the known policies.
+func (t TieBreak) valid() bool {
+	return t >= TieBreakNone && t <= TieBreakError
+}